@@ -4,13 +4,21 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/dasler-fw/bookcrossing/internal/authz"
 	"github.com/dasler-fw/bookcrossing/internal/config"
+	"github.com/dasler-fw/bookcrossing/internal/idempotency"
+	"github.com/dasler-fw/bookcrossing/internal/jwtutil"
+	"github.com/dasler-fw/bookcrossing/internal/mailer"
 	"github.com/dasler-fw/bookcrossing/internal/middleware"
 	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/oidc"
 	"github.com/dasler-fw/bookcrossing/internal/repository"
 	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/dasler-fw/bookcrossing/internal/tokenstore"
 	"github.com/dasler-fw/bookcrossing/internal/transport"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -21,6 +29,17 @@ func main() {
 
 	config.SetEnv(log)
 
+	jwtKeys, err := jwtutil.LoadKeysFromEnv()
+	if err != nil {
+		log.Error("failed to load jwt keys", "error", err)
+		os.Exit(1)
+	}
+	jwtutil.UseKeys(jwtKeys)
+	if jwtKeys.Algorithm() == "HS256" {
+		log.Warn("access tokens are signed with HS256 and SUPER_SECRET_KEY, set JWT_SIGNING_KEY to sign with a published key")
+	}
+	log.Info("jwt keys loaded", "alg", jwtKeys.Algorithm(), "kids", jwtKeys.KeyIDs())
+
 	rdb := redis.NewClient(&redis.Options{
 		Addr: os.Getenv("REDIS_ADDR"),
 	})
@@ -36,17 +55,49 @@ func main() {
 
 	db := config.Connect(log)
 
+	if err := repository.MigrateEmailVerification(db); err != nil {
+		log.Error("failed to migrate email verification", "error", err)
+		os.Exit(1)
+	}
+
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.Book{},
 		&models.Genre{},
 		&models.Exchange{},
+		&models.ExchangeOffer{},
+		&models.ExchangeItem{},
+		&models.ExchangeEvent{},
+		&models.ExchangeShipment{},
 		&models.Review{},
+		&models.WishlistItem{},
+		&models.ExchangeCycle{},
+		&models.ExchangeCycleLeg{},
+		&models.ExchangeMessage{},
+		&models.Meetup{},
+		&models.MeetupSlot{},
+		&models.MeetupReminder{},
+		&models.Dispute{},
+		&models.DisputeEvidence{},
+		&models.DisputeEvent{},
+		&models.IdempotencyKey{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.PasswordResetToken{},
+		&models.UserIdentity{},
+		&models.TwoFactor{},
+		&models.RecoveryCode{},
+		&models.PersonalAccessToken{},
 	); err != nil {
 		log.Error("failed to migrate database", "error", err)
 		os.Exit(1)
 	}
 
+	if err := repository.MigrateExchangeIndexes(db); err != nil {
+		log.Error("failed to create exchange indexes", "error", err)
+		os.Exit(1)
+	}
+
 	log.Info("migrations completed")
 
 	reviewRepo := repository.NewReviewRepository(db, log)
@@ -54,25 +105,200 @@ func main() {
 	bookRepo := repository.NewBookRepository(db, log)
 	userRepo := repository.NewUserRepository(db, log)
 	genreRepo := repository.NewGenreRepository(db, log)
+	wishlistRepo := repository.NewWishlistRepository(db, log)
+	exchangeCycleRepo := repository.NewExchangeCycleRepository(db, log)
+	exchangeMessageRepo := repository.NewExchangeMessageRepository(db, log)
+	meetupRepo := repository.NewMeetupRepository(db, log)
+	disputeRepo := repository.NewDisputeRepository(db, log)
+	shipmentRepo := repository.NewShipmentRepository(db, log)
+
+	authorizer := authz.NewAuthorizer()
+
+	exchangeService := services.NewExchangeService(exchangeRepo, bookRepo, userRepo, authorizer, log)
+	exchangeMessageService := services.NewExchangeMessageService(exchangeRepo, exchangeMessageRepo, authorizer, log)
+	reviewService := services.NewReviewService(reviewRepo, authorizer)
+	bookService := services.NewServiceBook(bookRepo, authorizer, log, rdb)
+	tokenStore := tokenstore.NewFallbackStore(tokenstore.NewRedisStore(rdb), tokenstore.NewDBStore(db), log)
+	middleware.UseTokenDenylist(tokenStore)
+	personalAccessTokenService := services.NewPersonalAccessTokenService(repository.NewPersonalAccessTokenRepository(db, log), userRepo, tokenStore, log)
+	middleware.UsePersonalAccessTokens(personalAccessTokenService)
+
+	refreshTTL, _ := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL"))
+	authService := services.NewAuthService(userRepo, tokenStore, refreshTTL, log)
+
+	var mail mailer.Mailer
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		smtpPort, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		mail = mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     smtpPort,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		})
+	case "memory":
+		mail = mailer.NewMemoryMailer()
+	default:
+		mail = mailer.NewFileMailer(os.Getenv("MAIL_DROP_DIR"), os.Getenv("MAIL_FROM"))
+	}
+
+	verificationSecret := os.Getenv("EMAIL_VERIFICATION_SECRET")
+	if verificationSecret == "" {
+		verificationSecret = os.Getenv("SUPER_SECRET_KEY")
+	}
+	verificationTTL, _ := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_TTL"))
+	resendCooldown, _ := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_RESEND_COOLDOWN"))
+	emailVerificationService, err := services.NewEmailVerificationService(userRepo, mail, rdb, services.EmailVerificationConfig{
+		BaseURL:        os.Getenv("APP_BASE_URL"),
+		Secret:         []byte(verificationSecret),
+		TTL:            verificationTTL,
+		ResendCooldown: resendCooldown,
+	}, log)
+	if err != nil {
+		log.Error("failed to set up email verification, set EMAIL_VERIFICATION_SECRET", "error", err)
+		os.Exit(1)
+	}
+
+	resetURL := os.Getenv("PASSWORD_RESET_URL")
+	if resetURL == "" && os.Getenv("APP_BASE_URL") != "" {
+		resetURL = strings.TrimRight(os.Getenv("APP_BASE_URL"), "/") + "/reset-password"
+	}
+	resetTTL, _ := time.ParseDuration(os.Getenv("PASSWORD_RESET_TTL"))
+	passwordResetService := services.NewPasswordResetService(userRepo, repository.NewPasswordResetRepository(db, log), authService, mail, rdb, services.PasswordResetConfig{
+		ResetURL: resetURL,
+		TTL:      resetTTL,
+	}, log)
 
-	exchangeService := services.NewExchangeService(exchangeRepo, bookRepo, log)
-	reviewService := services.NewReviewService(reviewRepo)
-	bookService := services.NewServiceBook(bookRepo, log, rdb)
-	userService := services.NewServiceUser(db, userRepo, bookRepo, log, rdb)
+	var oidcProviders []*oidc.Provider
+	for _, cfg := range config.OIDCProviders() {
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			log.Error("oidc provider is missing issuer or client id, skipping", "provider", cfg.Name)
+			continue
+		}
+		oidcProviders = append(oidcProviders, oidc.NewProvider(cfg, nil))
+	}
+
+	unlockURL := os.Getenv("LOGIN_UNLOCK_URL")
+	if unlockURL == "" && os.Getenv("APP_BASE_URL") != "" {
+		unlockURL = strings.TrimRight(os.Getenv("APP_BASE_URL"), "/") + "/users/login/unlock"
+	}
+	maxAccountFailures, _ := strconv.Atoi(os.Getenv("LOGIN_MAX_ACCOUNT_FAILURES"))
+	maxIPFailures, _ := strconv.Atoi(os.Getenv("LOGIN_MAX_IP_FAILURES"))
+	loginDelayAfter, _ := strconv.Atoi(os.Getenv("LOGIN_DELAY_AFTER"))
+	loginWindow, _ := time.ParseDuration(os.Getenv("LOGIN_FAILURE_WINDOW"))
+	lockoutDuration, _ := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_DURATION"))
+	loginBaseDelay, _ := time.ParseDuration(os.Getenv("LOGIN_BASE_DELAY"))
+	loginMaxDelay, _ := time.ParseDuration(os.Getenv("LOGIN_MAX_DELAY"))
+	loginGuard := services.NewLoginGuard(rdb, mail, services.LoginGuardConfig{
+		Disabled:           os.Getenv("LOGIN_GUARD_DISABLED") == "true",
+		MaxAccountFailures: maxAccountFailures,
+		MaxIPFailures:      maxIPFailures,
+		Window:             loginWindow,
+		LockoutDuration:    lockoutDuration,
+		DelayAfter:         loginDelayAfter,
+		BaseDelay:          loginBaseDelay,
+		MaxDelay:           loginMaxDelay,
+		UnlockURL:          unlockURL,
+	}, log)
+
+	twoFactorKey := os.Getenv("TWO_FACTOR_ENCRYPTION_KEY")
+	if twoFactorKey == "" {
+		twoFactorKey = os.Getenv("SUPER_SECRET_KEY")
+	}
+	mfaChallengeTTL, _ := time.ParseDuration(os.Getenv("MFA_CHALLENGE_TTL"))
+	twoFactorService, err := services.NewTwoFactorService(repository.NewTwoFactorRepository(db, log), userRepo, authService, loginGuard, rdb, services.TwoFactorConfig{
+		Issuer:        os.Getenv("TOTP_ISSUER"),
+		EncryptionKey: []byte(twoFactorKey),
+		ChallengeTTL:  mfaChallengeTTL,
+	}, log)
+	if err != nil {
+		log.Error("failed to set up two-factor authentication", "error", err)
+		os.Exit(1)
+	}
+	oidcService := services.NewOIDCService(oidcProviders, repository.NewIdentityRepository(db, log), userRepo, authService, twoFactorService, rdb, log)
+
+	userService := services.NewServiceUser(db, userRepo, bookRepo, exchangeRepo, authService, emailVerificationService, twoFactorService, loginGuard, authorizer, log, rdb)
 	genreService := services.NewGenreService(genreRepo)
+	wishlistService := services.NewWishlistService(wishlistRepo, bookRepo)
+	shipmentService := services.NewShipmentService(exchangeRepo, shipmentRepo, userRepo, authorizer, log)
+	disputeService := services.NewDisputeService(disputeRepo, exchangeRepo, authorizer, os.Getenv("DISPUTE_EVIDENCE_DIR"), log)
+
+	adminService := services.NewAdminService(db, log)
+
+	maxCycleLength, _ := strconv.Atoi(os.Getenv("MATCHER_MAX_CYCLE_LENGTH"))
+	exchangeCycleService := services.NewExchangeCycleService(exchangeCycleRepo, maxCycleLength, log)
+
+	matcherInterval, err := time.ParseDuration(os.Getenv("MATCHER_INTERVAL"))
+	if err != nil {
+		matcherInterval = 10 * time.Minute
+	}
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	exchangeCycleService.StartMatcher(jobsCtx, matcherInterval)
+
+	reminderBefore, err := time.ParseDuration(os.Getenv("MEETUP_REMINDER_BEFORE"))
+	if err != nil {
+		reminderBefore = 2 * time.Hour
+	}
+	meetupService := services.NewMeetupService(exchangeRepo, meetupRepo, userRepo, authorizer, mail, reminderBefore, log)
+	meetupService.StartReminderDispatcher(jobsCtx, time.Minute)
+
+	pendingTTL, _ := time.ParseDuration(os.Getenv("EXCHANGE_PENDING_TTL"))
+	followUpAfter, _ := time.ParseDuration(os.Getenv("EXCHANGE_FOLLOW_UP_AFTER"))
+	autoConfirmAfter, _ := time.ParseDuration(os.Getenv("EXCHANGE_AUTO_CONFIRM_AFTER"))
+	expiryInterval, _ := time.ParseDuration(os.Getenv("EXCHANGE_EXPIRY_INTERVAL"))
+	services.NewExchangeExpiryJob(exchangeRepo, rdb, services.ExchangeExpiryConfig{
+		PendingTTL:       pendingTTL,
+		FollowUpAfter:    followUpAfter,
+		AutoConfirmAfter: autoConfirmAfter,
+		Interval:         expiryInterval,
+	}, log).Start(jobsCtx)
 
 	httpServer := gin.New()
+	// without trusted proxies the client IP used for login throttling and
+	// idempotency keys is the peer address, not a spoofable header
+	var trustedProxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if err := httpServer.SetTrustedProxies(trustedProxies); err != nil {
+		log.Error("invalid TRUSTED_PROXIES", "error", err)
+		os.Exit(1)
+	}
 	httpServer.Use(gin.Recovery())
 	httpServer.Use(middleware.RequestLogger(log))
 
+	idempotencyStore := idempotency.NewFallbackStore(idempotency.NewRedisStore(rdb), idempotency.NewDBStore(db), log)
+	idempotencyTTL, _ := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL"))
+
 	transport.RegisterRoutes(
 		httpServer,
 		log,
 		bookService,
 		exchangeService,
+		exchangeCycleService,
+		disputeService,
+		exchangeMessageService,
+		meetupService,
+		shipmentService,
 		genreService,
 		reviewService,
 		userService,
+		emailVerificationService,
+		loginGuard,
+		authService,
+		passwordResetService,
+		oidcService,
+		twoFactorService,
+		personalAccessTokenService,
+		adminService,
+		wishlistService,
+		middleware.Idempotency(idempotencyStore, idempotencyTTL, log),
 	)
 
 	port := os.Getenv("PORT")
@@ -0,0 +1,88 @@
+// Command createadmin bootstraps the first administrator. It creates the
+// user, or promotes an existing user with the same email, and gives it the
+// admin role. Later admins and moderators are managed through
+// PUT /admin/users/:id/role.
+//
+//	go run ./cmd/createadmin -email admin@example.com -name Admin
+//
+// The password is read from ADMIN_PASSWORD and is only used for new users.
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/dasler-fw/bookcrossing/internal/config"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func main() {
+	email := flag.String("email", "", "admin email")
+	name := flag.String("name", "Admin", "admin name for a new user")
+	flag.Parse()
+
+	log := config.InitLogger()
+	config.SetEnv(log)
+
+	if *email == "" {
+		log.Error("-email is required")
+		os.Exit(2)
+	}
+
+	db := config.Connect(log)
+
+	if err := repository.MigrateEmailVerification(db); err != nil {
+		log.Error("failed to migrate email verification", "error", err)
+		os.Exit(1)
+	}
+
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		log.Error("failed to migrate users", "error", err)
+		os.Exit(1)
+	}
+
+	var user models.User
+	err := db.Where("email = ?", *email).First(&user).Error
+	switch {
+	case err == nil:
+		if err := db.Model(&user).Updates(map[string]any{"role": models.RoleAdmin, "email_verified": true}).Error; err != nil {
+			log.Error("failed to promote user", "error", err)
+			os.Exit(1)
+		}
+		log.Info("user promoted to admin", "id", user.ID, "email", user.Email)
+
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		password := os.Getenv("ADMIN_PASSWORD")
+		if len(password) < 8 {
+			log.Error("ADMIN_PASSWORD must be at least 8 characters to create a new admin")
+			os.Exit(2)
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Error("failed to hash password", "error", err)
+			os.Exit(1)
+		}
+
+		user = models.User{
+			Name:          *name,
+			Email:         *email,
+			PasswordHash:  string(hash),
+			Role:          models.RoleAdmin,
+			EmailVerified: true,
+		}
+		if err := db.Create(&user).Error; err != nil {
+			log.Error("failed to create admin", "error", err)
+			os.Exit(1)
+		}
+		log.Info("admin created", "id", user.ID, "email", user.Email)
+
+	default:
+		log.Error("failed to look up user", "error", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,184 @@
+// Package authz decides whether an actor may perform an action on a
+// resource. Services call it instead of comparing owner IDs themselves, so
+// every ownership rule lives in one place and every denial wraps
+// dto.ErrForbidden.
+package authz
+
+import (
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+)
+
+// Actor is the authenticated user performing the request.
+type Actor struct {
+	UserID uint
+	Role   string
+}
+
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+
+	// ActionReadExchanges reads the exchange history of a user.
+	ActionReadExchanges Action = "read_exchanges"
+
+	// ActionParticipate covers the exchange side features: messages,
+	// meetups, shipping and disputes.
+	ActionParticipate Action = "participate"
+	ActionAccept      Action = "accept"
+	ActionCounter     Action = "counter"
+	ActionDecline     Action = "decline"
+	ActionCancel      Action = "cancel"
+	ActionComplete    Action = "complete"
+)
+
+type Authorizer interface {
+	// Can returns nil when actor may perform action on resource,
+	// dto.ErrUnauthorized for anonymous actors and an error wrapping
+	// dto.ErrForbidden otherwise. Unknown resources and actions are denied.
+	Can(actor Actor, action Action, resource any) error
+}
+
+type authorizer struct{}
+
+func NewAuthorizer() Authorizer {
+	return authorizer{}
+}
+
+func (authorizer) Can(actor Actor, action Action, resource any) error {
+	if actor.UserID == 0 {
+		return dto.ErrUnauthorized
+	}
+
+	switch r := resource.(type) {
+	case *models.User:
+		return userPolicy(actor, action, r)
+	case *models.Book:
+		return bookPolicy(actor, action, r)
+	case *models.Exchange:
+		return exchangePolicy(actor, action, r)
+	case *models.Review:
+		return reviewPolicy(actor, action, r)
+	default:
+		return dto.ErrForbidden
+	}
+}
+
+func userPolicy(actor Actor, action Action, user *models.User) error {
+	switch action {
+	case ActionRead:
+		// profiles are public to signed in users
+		return nil
+	case ActionUpdate:
+		if actor.UserID == user.ID || isAdmin(actor) {
+			return nil
+		}
+	case ActionReadExchanges:
+		if actor.UserID == user.ID || isStaff(actor) {
+			return nil
+		}
+	case ActionDelete:
+		if isAdmin(actor) {
+			return nil
+		}
+	}
+	return dto.ErrUserForbidden
+}
+
+func bookPolicy(actor Actor, action Action, book *models.Book) error {
+	switch action {
+	case ActionRead:
+		return nil
+	case ActionUpdate:
+		if actor.UserID == book.UserID || isAdmin(actor) {
+			return nil
+		}
+	case ActionDelete:
+		if actor.UserID == book.UserID || isStaff(actor) {
+			return nil
+		}
+	}
+	return dto.ErrBookForbidden
+}
+
+func exchangePolicy(actor Actor, action Action, exchange *models.Exchange) error {
+	participant := actor.UserID == exchange.InitiatorID || actor.UserID == exchange.RecipientID
+
+	switch action {
+	case ActionRead:
+		if participant || isStaff(actor) {
+			return nil
+		}
+		return dto.ErrExchangeForbidden
+	case ActionParticipate:
+		if participant {
+			return nil
+		}
+		return dto.ErrExchangeForbidden
+	case ActionAccept:
+		if participant && latestProposerID(exchange) != actor.UserID {
+			return nil
+		}
+		return dto.ErrExchangeNotCounterparty
+	case ActionCounter:
+		if participant && latestProposerID(exchange) != actor.UserID {
+			return nil
+		}
+		return dto.ErrExchangeNotYourTurn
+	case ActionDecline:
+		if actor.UserID == exchange.RecipientID {
+			return nil
+		}
+		return dto.ErrExchangeNotDecliner
+	case ActionCancel:
+		if actor.UserID == exchange.InitiatorID {
+			return nil
+		}
+		return dto.ErrExchangeNotInitiator
+	case ActionComplete:
+		if participant {
+			return nil
+		}
+		return dto.ErrExchangeNotParticipant
+	}
+	return dto.ErrExchangeForbidden
+}
+
+func reviewPolicy(actor Actor, action Action, review *models.Review) error {
+	switch action {
+	case ActionRead:
+		return nil
+	case ActionCreate:
+		if review.TargetUserID != actor.UserID {
+			return nil
+		}
+		return dto.ErrSelfReviewForbidden
+	case ActionDelete:
+		if actor.UserID == review.AuthorID || isStaff(actor) {
+			return nil
+		}
+	}
+	return dto.ErrReviewDeleteForbidden
+}
+
+// latestProposerID returns the author of the current offer. Exchanges created
+// before counter-offers existed have no proposer and belong to the initiator.
+func latestProposerID(exchange *models.Exchange) uint {
+	if exchange.ProposerID == 0 {
+		return exchange.InitiatorID
+	}
+	return exchange.ProposerID
+}
+
+func isAdmin(actor Actor) bool {
+	return actor.Role == models.RoleAdmin
+}
+
+// isStaff reports whether the actor moderates content.
+func isStaff(actor Actor) bool {
+	return actor.Role == models.RoleModerator || actor.Role == models.RoleAdmin
+}
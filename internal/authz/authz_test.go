@@ -0,0 +1,141 @@
+package authz
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	owner     = Actor{UserID: 1, Role: models.RoleUser}
+	stranger  = Actor{UserID: 2, Role: models.RoleUser}
+	moderator = Actor{UserID: 3, Role: models.RoleModerator}
+	admin     = Actor{UserID: 4, Role: models.RoleAdmin}
+	anonymous = Actor{}
+)
+
+type policyCase struct {
+	name     string
+	actor    Actor
+	action   Action
+	resource any
+	want     error
+}
+
+func runPolicyCases(t *testing.T, cases []policyCase) {
+	t.Helper()
+
+	a := NewAuthorizer()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := a.Can(tc.actor, tc.action, tc.resource)
+			if tc.want == nil {
+				if err != nil {
+					t.Fatalf("expected access, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+			if tc.want != dto.ErrUnauthorized && !errors.Is(err, dto.ErrForbidden) {
+				t.Fatalf("denial %v does not wrap ErrForbidden", err)
+			}
+		})
+	}
+}
+
+func TestUserPolicy(t *testing.T) {
+	user := &models.User{Model: gorm.Model{ID: owner.UserID}}
+
+	runPolicyCases(t, []policyCase{
+		{"anonymous read", anonymous, ActionRead, user, dto.ErrUnauthorized},
+		{"stranger reads profile", stranger, ActionRead, user, nil},
+		{"self update", owner, ActionUpdate, user, nil},
+		{"stranger update", stranger, ActionUpdate, user, dto.ErrUserForbidden},
+		{"moderator update", moderator, ActionUpdate, user, dto.ErrUserForbidden},
+		{"admin update", admin, ActionUpdate, user, nil},
+		{"self reads exchanges", owner, ActionReadExchanges, user, nil},
+		{"stranger reads exchanges", stranger, ActionReadExchanges, user, dto.ErrUserForbidden},
+		{"moderator reads exchanges", moderator, ActionReadExchanges, user, nil},
+		{"self delete", owner, ActionDelete, user, dto.ErrUserForbidden},
+		{"moderator delete", moderator, ActionDelete, user, dto.ErrUserForbidden},
+		{"admin delete", admin, ActionDelete, user, nil},
+		{"unknown action", admin, ActionAccept, user, dto.ErrUserForbidden},
+	})
+}
+
+func TestBookPolicy(t *testing.T) {
+	book := &models.Book{UserID: owner.UserID}
+
+	runPolicyCases(t, []policyCase{
+		{"stranger read", stranger, ActionRead, book, nil},
+		{"owner update", owner, ActionUpdate, book, nil},
+		{"stranger update", stranger, ActionUpdate, book, dto.ErrBookForbidden},
+		{"moderator update", moderator, ActionUpdate, book, dto.ErrBookForbidden},
+		{"admin update", admin, ActionUpdate, book, nil},
+		{"owner delete", owner, ActionDelete, book, nil},
+		{"stranger delete", stranger, ActionDelete, book, dto.ErrBookForbidden},
+		{"moderator delete", moderator, ActionDelete, book, nil},
+		{"anonymous delete", anonymous, ActionDelete, book, dto.ErrUnauthorized},
+	})
+}
+
+func TestExchangePolicy(t *testing.T) {
+	initiator := Actor{UserID: 10, Role: models.RoleUser}
+	recipient := Actor{UserID: 11, Role: models.RoleUser}
+
+	fresh := &models.Exchange{InitiatorID: initiator.UserID, RecipientID: recipient.UserID}
+	countered := &models.Exchange{InitiatorID: initiator.UserID, RecipientID: recipient.UserID, ProposerID: recipient.UserID}
+
+	runPolicyCases(t, []policyCase{
+		{"initiator read", initiator, ActionRead, fresh, nil},
+		{"recipient read", recipient, ActionRead, fresh, nil},
+		{"stranger read", stranger, ActionRead, fresh, dto.ErrExchangeForbidden},
+		{"moderator read", moderator, ActionRead, fresh, nil},
+		{"admin read", admin, ActionRead, fresh, nil},
+		{"recipient participates", recipient, ActionParticipate, fresh, nil},
+		{"moderator participates", moderator, ActionParticipate, fresh, dto.ErrExchangeForbidden},
+		{"recipient accepts initiator offer", recipient, ActionAccept, fresh, nil},
+		{"initiator accepts own offer", initiator, ActionAccept, fresh, dto.ErrExchangeNotCounterparty},
+		{"initiator accepts counter", initiator, ActionAccept, countered, nil},
+		{"recipient accepts own counter", recipient, ActionAccept, countered, dto.ErrExchangeNotCounterparty},
+		{"stranger accepts", stranger, ActionAccept, fresh, dto.ErrExchangeNotCounterparty},
+		{"recipient counters", recipient, ActionCounter, fresh, nil},
+		{"initiator counters own offer", initiator, ActionCounter, fresh, dto.ErrExchangeNotYourTurn},
+		{"recipient declines", recipient, ActionDecline, fresh, nil},
+		{"initiator declines", initiator, ActionDecline, fresh, dto.ErrExchangeNotDecliner},
+		{"initiator cancels", initiator, ActionCancel, fresh, nil},
+		{"recipient cancels", recipient, ActionCancel, fresh, dto.ErrExchangeNotInitiator},
+		{"admin cancels", admin, ActionCancel, fresh, dto.ErrExchangeNotInitiator},
+		{"initiator completes", initiator, ActionComplete, fresh, nil},
+		{"recipient completes", recipient, ActionComplete, fresh, nil},
+		{"stranger completes", stranger, ActionComplete, fresh, dto.ErrExchangeNotParticipant},
+		{"unknown action", initiator, ActionDelete, fresh, dto.ErrExchangeForbidden},
+	})
+}
+
+func TestReviewPolicy(t *testing.T) {
+	review := &models.Review{AuthorID: owner.UserID, TargetUserID: stranger.UserID}
+
+	runPolicyCases(t, []policyCase{
+		{"stranger read", stranger, ActionRead, review, nil},
+		{"author creates", owner, ActionCreate, review, nil},
+		{"self review", stranger, ActionCreate, review, dto.ErrSelfReviewForbidden},
+		{"author deletes", owner, ActionDelete, review, nil},
+		{"target deletes", stranger, ActionDelete, review, dto.ErrReviewDeleteForbidden},
+		{"moderator deletes", moderator, ActionDelete, review, nil},
+		{"admin deletes", admin, ActionDelete, review, nil},
+		{"anonymous deletes", anonymous, ActionDelete, review, dto.ErrUnauthorized},
+	})
+}
+
+func TestUnknownResourceIsDenied(t *testing.T) {
+	runPolicyCases(t, []policyCase{
+		{"genre", admin, ActionDelete, &models.Genre{}, dto.ErrForbidden},
+		{"nil", admin, ActionRead, nil, dto.ErrForbidden},
+	})
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"github.com/dasler-fw/bookcrossing/internal/oidc"
+)
+
+// OIDCProviders reads the providers listed in OIDC_PROVIDERS. Each name is
+// configured by OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL
+// and _SCOPES; the redirect URL defaults to
+// APP_BASE_URL/auth/oidc/<name>/callback.
+func OIDCProviders() []oidc.Config {
+	var configs []oidc.Config
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		redirectURL := os.Getenv(prefix + "REDIRECT_URL")
+		if redirectURL == "" {
+			redirectURL = strings.TrimRight(os.Getenv("APP_BASE_URL"), "/") + "/auth/oidc/" + name + "/callback"
+		}
+
+		configs = append(configs, oidc.Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  redirectURL,
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		})
+	}
+
+	return configs
+}
@@ -0,0 +1,8 @@
+package dto
+
+type AdminStatsResponse struct {
+	UsersCount        int64            `json:"users_count"`
+	BooksByStatus     map[string]int64 `json:"books_by_status"`
+	ExchangesByStatus map[string]int64 `json:"exchanges_by_status"`
+	OpenDisputes      int64            `json:"open_disputes"`
+}
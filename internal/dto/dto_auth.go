@@ -0,0 +1,69 @@
+package dto
+
+// TokenResponse is returned by register, login and refresh. Token is the
+// short-lived access token; ExpiresIn is its lifetime in seconds.
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// LoginResponse holds either the tokens or, when the account has two-factor
+// authentication enabled, the intermediate MFAToken to send to
+// POST /users/login/2fa together with a code.
+type LoginResponse struct {
+	*TokenResponse
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+	MFAExpiresIn int64  `json:"mfa_expires_in,omitempty"`
+}
+
+type TwoFactorLoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	// Code is a TOTP code or a recovery code.
+	Code string `json:"code"`
+	// ClientIP is set by the handler for brute-force protection.
+	ClientIP string `json:"-"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+}
+
+type TwoFactorEnableRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorConfirmRequest re-authenticates the user for disabling 2FA or
+// regenerating recovery codes.
+type TwoFactorConfirmRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled           bool  `json:"enabled"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
@@ -0,0 +1,75 @@
+package dto
+
+import "time"
+
+type OpenDisputeRequest struct {
+	ExchangeID  uint   `json:"exchange_id"`
+	Reason      string `json:"reason"`
+	Description string `json:"description"`
+}
+
+// ResolveDisputeRequest closes a dispute. The reputation deltas are only
+// accepted together with the adjust_reputation resolution.
+type ResolveDisputeRequest struct {
+	Resolution               string `json:"resolution"`
+	Note                     string `json:"note"`
+	InitiatorReputationDelta int    `json:"initiator_reputation_delta"`
+	RecipientReputationDelta int    `json:"recipient_reputation_delta"`
+}
+
+type DisputeQueueQuery struct {
+	Status string `form:"status"`
+	Page   int    `form:"page"`
+	Limit  int    `form:"limit"`
+}
+
+type DisputeEvidenceResponse struct {
+	ID          uint      `json:"id"`
+	UploaderID  uint      `json:"uploader_id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type DisputeEventResponse struct {
+	ID        uint      `json:"id"`
+	ActorID   uint      `json:"actor_id"`
+	Action    string    `json:"action"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DisputeResponse struct {
+	ID             uint                      `json:"id"`
+	ExchangeID     uint                      `json:"exchange_id"`
+	OpenedByID     uint                      `json:"opened_by_id"`
+	Reason         string                    `json:"reason"`
+	Description    string                    `json:"description"`
+	Status         string                    `json:"status"`
+	ModeratorID    *uint                     `json:"moderator_id,omitempty"`
+	Resolution     string                    `json:"resolution,omitempty"`
+	ResolutionNote string                    `json:"resolution_note,omitempty"`
+	ResolvedAt     *time.Time                `json:"resolved_at,omitempty"`
+	Evidence       []DisputeEvidenceResponse `json:"evidence"`
+	Events         []DisputeEventResponse    `json:"events"`
+	CreatedAt      time.Time                 `json:"created_at"`
+}
+
+type DisputeListResponse struct {
+	Data       []DisputeResponse `json:"data"`
+	Page       int               `json:"page"`
+	Limit      int               `json:"limit"`
+	Total      int               `json:"total"`
+	TotalPages int               `json:"total_pages"`
+}
+
+const (
+	MinDisputeDescriptionLength = 10
+	MaxDisputeDescriptionLength = 2000
+	MaxDisputeNoteLength        = 1000
+	MaxDisputeEvidenceFiles     = 10
+	MaxDisputeEvidenceSize      = 5 << 20
+	DefaultDisputeLimit         = 20
+	MaxDisputeLimit             = 100
+)
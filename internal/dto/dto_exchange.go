@@ -2,21 +2,115 @@ package dto
 
 import "time"
 
+// CreateExchangeRequest accepts either a single book per side or a bundle
+// in the *_book_ids lists; both forms can be combined. DeliveryMethod is
+// in_person (the default) or postal.
 type CreateExchangeRequest struct {
-	InitiatorID     uint `json:"initiator_id"`
-	RecipientID     uint `json:"recipient_id"`
+	RecipientID      uint   `json:"recipient_id"`
+	InitiatorBookID  uint   `json:"initiator_book_id"`
+	RecipientBookID  uint   `json:"recipient_book_id"`
+	InitiatorBookIDs []uint `json:"initiator_book_ids"`
+	RecipientBookIDs []uint `json:"recipient_book_ids"`
+	DeliveryMethod   string `json:"delivery_method"`
+}
+
+const MaxExchangeItemsPerSide = 10
+
+type DeclineExchangeRequest struct {
+	Reason string `json:"reason"`
+}
+
+const MaxDeclineReasonLength = 500
+
+// CounterExchangeRequest proposes other books for a pending exchange.
+// A zero book ID keeps the book from the current offer.
+type CounterExchangeRequest struct {
 	InitiatorBookID uint `json:"initiator_book_id"`
 	RecipientBookID uint `json:"recipient_book_id"`
 }
 
+type ExchangeOfferResponse struct {
+	ID              uint      `json:"id"`
+	ProposerID      uint      `json:"proposer_id"`
+	InitiatorBookID uint      `json:"initiator_book_id"`
+	RecipientBookID uint      `json:"recipient_book_id"`
+	PreviousOfferID *uint     `json:"previous_offer_id,omitempty"`
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
 type ExchangeResponse struct {
-	ID              uint       `json:"id"`
-	InitiatorID     uint       `json:"initiator_id"`
-	RecipientID     uint       `json:"recipient_id"`
-	InitiatorBookID uint       `json:"initiator_book_id"`
-	RecipientBookID uint       `json:"recipient_book_id"`
-	Status          string     `json:"status"`
-	CompletedAt     *time.Time `json:"completed_at,omitempty"`
-	CreatedAt       time.Time  `json:"created_at"`
-	UpdatedAt       time.Time  `json:"updated_at"`
-}
\ No newline at end of file
+	ID                   uint       `json:"id"`
+	InitiatorID          uint       `json:"initiator_id"`
+	RecipientID          uint       `json:"recipient_id"`
+	InitiatorBookID      uint       `json:"initiator_book_id"`
+	RecipientBookID      uint       `json:"recipient_book_id"`
+	InitiatorBooks       []uint     `json:"initiator_book_ids"`
+	RecipientBooks       []uint     `json:"recipient_book_ids"`
+	ProposerID           uint       `json:"proposer_id"`
+	DeliveryMethod       string     `json:"delivery_method"`
+	Status               string     `json:"status"`
+	DeclineReason        string     `json:"decline_reason,omitempty"`
+	CancelReason         string     `json:"cancel_reason,omitempty"`
+	FollowUpAt           *time.Time `json:"follow_up_at,omitempty"`
+	CompletedAt          *time.Time `json:"completed_at,omitempty"`
+	InitiatorConfirmedAt *time.Time `json:"initiator_confirmed_at"`
+	RecipientConfirmedAt *time.Time `json:"recipient_confirmed_at"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+type ExchangeDetailResponse struct {
+	ExchangeResponse
+	Offers []ExchangeOfferResponse `json:"offers"`
+}
+
+// ExchangeListQuery filters and pages exchange listings. Pagination is
+// keyset based: Cursor is the NextCursor of the previous page.
+type ExchangeListQuery struct {
+	ParticipantID uint      `form:"participant_id"`
+	Role          string    `form:"role"`
+	Statuses      []string  `form:"status"`
+	BookID        uint      `form:"book_id"`
+	CreatedFrom   time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo     time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	UpdatedFrom   time.Time `form:"updated_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	UpdatedTo     time.Time `form:"updated_to" time_format:"2006-01-02T15:04:05Z07:00"`
+
+	// sort_by: created_at | updated_at
+	// sort_order: asc | desc
+	SortBy    string `form:"sort_by"`
+	SortOrder string `form:"sort_order"`
+
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+}
+
+type ExchangeListResponse struct {
+	Data       []ExchangeResponse `json:"data"`
+	Limit      int                `json:"limit"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+const (
+	DefaultExchangeListLimit = 20
+	MaxExchangeListLimit     = 100
+)
+
+// ExchangeEventMeta describes who changes the status of an exchange and why.
+// It is written to the audit log together with the change.
+type ExchangeEventMeta struct {
+	ActorID   uint
+	RequestID string
+	Reason    string
+}
+
+type ExchangeEventResponse struct {
+	ID         uint      `json:"id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    uint      `json:"actor_id"`
+	Reason     string    `json:"reason,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
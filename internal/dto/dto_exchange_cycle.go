@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+type ExchangeCycleLegResponse struct {
+	ID          uint       `json:"id"`
+	Position    int        `json:"position"`
+	GiverID     uint       `json:"giver_id"`
+	ReceiverID  uint       `json:"receiver_id"`
+	BookID      uint       `json:"book_id"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+type ExchangeCycleResponse struct {
+	ID          uint                       `json:"id"`
+	Status      string                     `json:"status"`
+	Legs        []ExchangeCycleLegResponse `json:"legs"`
+	CompletedAt *time.Time                 `json:"completed_at,omitempty"`
+	CreatedAt   time.Time                  `json:"created_at"`
+}
+
+const (
+	DefaultMaxCycleLength = 4
+	MinCycleLength        = 2
+	MaxCyclesPerRun       = 100
+	// CycleProposalCooldown keeps the matcher from proposing a declined or
+	// cancelled cycle again with the same participants and books.
+	CycleProposalCooldown = 30 * 24 * time.Hour
+)
@@ -0,0 +1,34 @@
+package dto
+
+import "time"
+
+type CreateExchangeMessageRequest struct {
+	Text string `json:"text"`
+}
+
+type ExchangeMessageListQuery struct {
+	Page  int `form:"page"`
+	Limit int `form:"limit"`
+}
+
+type ExchangeMessageResponse struct {
+	ID        uint       `json:"id"`
+	SenderID  uint       `json:"sender_id"`
+	Text      string     `json:"text"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type ExchangeMessageListResponse struct {
+	Data       []ExchangeMessageResponse `json:"data"`
+	Page       int                       `json:"page"`
+	Limit      int                       `json:"limit"`
+	Total      int                       `json:"total"`
+	TotalPages int                       `json:"total_pages"`
+}
+
+const (
+	MaxExchangeMessageLength = 1000
+	DefaultMessageLimit      = 50
+	MaxMessageLimit          = 200
+)
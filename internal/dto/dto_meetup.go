@@ -0,0 +1,39 @@
+package dto
+
+import "time"
+
+type MeetupSlotRequest struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+type CreateMeetupRequest struct {
+	Place string              `json:"place"`
+	Slots []MeetupSlotRequest `json:"slots"`
+}
+
+type ConfirmMeetupRequest struct {
+	SlotID uint `json:"slot_id"`
+}
+
+type MeetupSlotResponse struct {
+	ID       uint      `json:"id"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+type MeetupResponse struct {
+	ID              uint                 `json:"id"`
+	ExchangeID      uint                 `json:"exchange_id"`
+	ProposerID      uint                 `json:"proposer_id"`
+	Place           string               `json:"place"`
+	Status          string               `json:"status"`
+	ConfirmedSlotID *uint                `json:"confirmed_slot_id,omitempty"`
+	Slots           []MeetupSlotResponse `json:"slots"`
+	CreatedAt       time.Time            `json:"created_at"`
+}
+
+const (
+	MaxMeetupSlots       = 5
+	MaxMeetupPlaceLength = 255
+)
@@ -0,0 +1,40 @@
+package dto
+
+import "time"
+
+type ShipExchangeRequest struct {
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+}
+
+type ShippingAddressResponse struct {
+	UserID  uint   `json:"user_id"`
+	Name    string `json:"name"`
+	City    string `json:"city"`
+	Address string `json:"address"`
+}
+
+type ShipmentResponse struct {
+	ID             uint       `json:"id"`
+	Side           string     `json:"side"`
+	SenderID       uint       `json:"sender_id"`
+	ReceiverID     uint       `json:"receiver_id"`
+	Status         string     `json:"status"`
+	Carrier        string     `json:"carrier,omitempty"`
+	TrackingNumber string     `json:"tracking_number,omitempty"`
+	ShippedAt      *time.Time `json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// ShippingResponse is only available to participants of an accepted postal
+// exchange. Address is where the caller has to send their books.
+type ShippingResponse struct {
+	ExchangeID uint                    `json:"exchange_id"`
+	Address    ShippingAddressResponse `json:"address"`
+	Shipments  []ShipmentResponse      `json:"shipments"`
+}
+
+const (
+	MaxCarrierLength        = 64
+	MaxTrackingNumberLength = 64
+)
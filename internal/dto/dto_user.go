@@ -19,6 +19,12 @@ type UserUpdateRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// ClientIP is set by the handler for brute-force protection.
+	ClientIP string `json:"-"`
+}
+
+type UserRoleRequest struct {
+	Role string `json:"role"`
 }
 
 type UserProfileResponse struct {
@@ -27,4 +33,5 @@ type UserProfileResponse struct {
 	City                     string `json:"city"`
 	BooksCount               int64  `json:"books_count"`
 	SuccessfulExchangesCount int64  `json:"successful_exchanges_count"`
+	Reputation               int    `json:"reputation"`
 }
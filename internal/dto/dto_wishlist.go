@@ -0,0 +1,13 @@
+package dto
+
+import "time"
+
+type AddWishlistItemRequest struct {
+	BookID uint `json:"book_id"`
+}
+
+type WishlistItemResponse struct {
+	ID        uint         `json:"id"`
+	Book      BookResponse `json:"book"`
+	CreatedAt time.Time    `json:"created_at"`
+}
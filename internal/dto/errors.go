@@ -1,6 +1,14 @@
 package dto
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrForbidden is wrapped by every authorization error so handlers can map
+// all of them to 403 with one check.
+var ErrForbidden = errors.New("forbidden")
 
 var (
 	// Book repository errors
@@ -8,13 +16,99 @@ var (
 	ErrBookGetFailed    = errors.New("error getting book from db")
 	ErrBookUpdateFailed = errors.New("error updating book in db")
 	ErrBookDeleteFailed = errors.New("error deleting book in db")
+	ErrBookConflict     = errors.New("book was changed by another request")
 
 	// Exchange repository errors
 	ErrExchangeCreateFailed   = errors.New("error create exchange in db")
 	ErrExchangeUpdateFailed   = errors.New("error update exchange in db")
 	ErrExchangeCancelFailed   = errors.New("error cancel exchange in db")
+	ErrExchangeDeclineFailed  = errors.New("error decline exchange in db")
+	ErrExchangeCounterFailed  = errors.New("error counter exchange in db")
+	ErrExchangeOffersFailed   = errors.New("error get exchange offers in db")
 	ErrExchangeCompleteFailed = errors.New("error complete exchange in db")
 	ErrExchangeGetFailed      = errors.New("error get exchange in db")
+	ErrExchangeNotFound       = errors.New("exchange not found")
+	ErrExchangeListFailed     = errors.New("error list exchanges in db")
+	ErrExchangeEventsFailed   = errors.New("error get exchange events in db")
+	ErrExchangeInvalidCursor  = errors.New("invalid exchange list cursor")
+	ErrExchangeInvalidFilter  = errors.New("invalid exchange list filter")
+
+	// Wishlist errors
+	ErrWishlistGetFailed    = errors.New("failed to get wishlist")
+	ErrWishlistAddFailed    = errors.New("failed to add book to wishlist")
+	ErrWishlistDeleteFailed = errors.New("failed to delete wishlist item")
+	ErrWishlistItemNotFound = errors.New("wishlist item not found")
+	ErrWishlistOwnBook      = errors.New("cannot add your own book to the wishlist")
+	ErrWishlistDuplicate    = errors.New("book is already in the wishlist")
+
+	// Exchange cycle errors
+	ErrCycleGetFailed      = errors.New("error get exchange cycle in db")
+	ErrCycleCreateFailed   = errors.New("error create exchange cycle in db")
+	ErrCycleUpdateFailed   = errors.New("error update exchange cycle in db")
+	ErrCycleNotFound       = errors.New("exchange cycle not found")
+	ErrCycleNotParticipant = errors.New("user does not participate in the exchange cycle")
+	ErrCycleNotProposed    = errors.New("exchange cycle is not proposed")
+	ErrCycleNotAccepted    = errors.New("exchange cycle is not accepted")
+	ErrCycleAlreadyDone    = errors.New("exchange cycle step is already done")
+	ErrCycleLegNotFound    = errors.New("exchange cycle leg not found")
+	ErrCycleNotReceiver    = errors.New("only the receiver can complete the leg")
+	ErrCycleBookTaken      = errors.New("a book of the exchange cycle is no longer available")
+
+	// Exchange message errors
+	ErrMessageCreateFailed = errors.New("failed to send exchange message")
+	ErrMessageGetFailed    = errors.New("failed to get exchange messages")
+	ErrMessageTextRequired = errors.New("message text is required")
+	ErrMessageTextLength   = errors.New("message text must be at most 1000 characters")
+	ErrMessageThreadFrozen = errors.New("message thread is closed for this exchange")
+
+	// Meetup errors
+	ErrMeetupCreateFailed   = errors.New("failed to create meetup")
+	ErrMeetupGetFailed      = errors.New("failed to get meetup")
+	ErrMeetupConfirmFailed  = errors.New("failed to confirm meetup")
+	ErrMeetupNotFound       = errors.New("meetup not found")
+	ErrMeetupNotConfirmed   = errors.New("exchange has no confirmed meetup")
+	ErrMeetupPlaceRequired  = errors.New("meetup place is required")
+	ErrMeetupPlaceLength    = errors.New("meetup place must be at most 255 characters")
+	ErrMeetupSlotsCount     = errors.New("meetup needs between 1 and 5 time slots")
+	ErrMeetupSlotInvalid    = errors.New("meetup slot must start in the future and end after it starts")
+	ErrMeetupSlotNotFound   = errors.New("meetup slot not found")
+	ErrMeetupNotProposed    = errors.New("meetup is not proposed")
+	ErrMeetupSelfConfirm    = errors.New("only the other participant can confirm the meetup")
+	ErrMeetupReminderFailed = errors.New("failed to process meetup reminders")
+
+	// Shipment errors
+	ErrShipmentGetFailed      = errors.New("failed to get exchange shipments")
+	ErrShipmentUpdateFailed   = errors.New("failed to update exchange shipment")
+	ErrShipmentNotFound       = errors.New("exchange shipment not found")
+	ErrExchangeNotPostal      = errors.New("exchange is not a postal exchange")
+	ErrShipmentCarrier        = errors.New("carrier must be between 1 and 64 characters")
+	ErrShipmentTracking       = errors.New("tracking number must be between 1 and 64 characters")
+	ErrShipmentAlreadyShipped = errors.New("books of this side are already shipped")
+	ErrShipmentNotShipped     = errors.New("books of this side are not shipped yet")
+	ErrShipmentDelivered      = errors.New("shipment is already delivered")
+	ErrShippingAddressMissing = errors.New("participant has no shipping address")
+
+	// Dispute errors
+	ErrDisputeCreateFailed      = errors.New("failed to open dispute")
+	ErrDisputeGetFailed         = errors.New("failed to get dispute")
+	ErrDisputeUpdateFailed      = errors.New("failed to update dispute")
+	ErrDisputeNotFound          = errors.New("dispute not found")
+	ErrDisputeEvidenceNotFound  = errors.New("dispute evidence not found")
+	ErrDisputeAlreadyOpen       = errors.New("exchange already has an open dispute")
+	ErrDisputeExchangeStatus    = errors.New("disputes can only be opened on accepted or completed exchanges")
+	ErrDisputeInvalidReason     = errors.New("dispute reason must be not_delivered, wrong_condition or other")
+	ErrDisputeDescription       = errors.New("dispute description must be between 10 and 2000 characters")
+	ErrDisputeClosed            = errors.New("dispute is already resolved")
+	ErrDisputeNotClaimed        = errors.New("dispute must be claimed by this moderator first")
+	ErrDisputeAlreadyClaimed    = errors.New("dispute is already claimed by a moderator")
+	ErrDisputeInvalidResolution = errors.New("resolution must be reverse_transfer, adjust_reputation or no_action")
+	ErrDisputeReputationDelta   = errors.New("reputation adjustments are only allowed with adjust_reputation")
+	ErrDisputeNotReversible     = errors.New("only completed exchanges can be reversed")
+	ErrDisputeBooksMoved        = errors.New("books of the exchange changed hands since it was completed")
+	ErrDisputeTooManyEvidence   = errors.New("too many evidence files for this dispute")
+	ErrDisputeEvidenceSize      = errors.New("evidence file is too large")
+	ErrDisputeEvidenceType      = errors.New("evidence must be a jpeg, png or pdf file")
+	ErrDisputeEvidenceFailed    = errors.New("failed to store dispute evidence")
 
 	// Genre repository errors
 	ErrNotFound     = errors.New("resource not found")
@@ -33,7 +127,7 @@ var (
 	ErrUserGetFailed    = errors.New("failed to get user")
 
 	// Book Service errors
-	ErrBookForbidden    = errors.New("forbidden")
+	ErrBookForbidden    = fmt.Errorf("%w: only the owner can change the book", ErrForbidden)
 	ErrBookInExchange   = errors.New("book is involved in exchange")
 	ErrInvalidBookInput = errors.New("invalid book input")
 	ErrAISummaryFailed  = errors.New("failed to generate ai summary")
@@ -46,12 +140,30 @@ var (
 	ErrRecipientNotOwner   = errors.New("recipient does not own the book")
 	ErrUnavailable         = errors.New("initiator book is unavailable")
 	ErrRUnavailable        = errors.New("recipient book is unavailable")
+	ErrExchangeSameUser    = errors.New("initiator and recipient book cannot be the same user")
+
+	// Exchange actor errors
+	ErrUnauthorized             = errors.New("unauthorized")
+	ErrExchangeNotCounterparty  = fmt.Errorf("%w: only the counterparty of the latest offer can accept the exchange", ErrForbidden)
+	ErrExchangeNotParticipant   = fmt.Errorf("%w: only exchange participants can complete the exchange", ErrForbidden)
+	ErrExchangeNotInitiator     = fmt.Errorf("%w: only the initiator can cancel the exchange", ErrForbidden)
+	ErrExchangeNotDecliner      = fmt.Errorf("%w: only the recipient can decline the exchange", ErrForbidden)
+	ErrDeclineReasonLength      = errors.New("decline reason must be at most 500 characters")
+	ErrExchangeNotYourTurn      = fmt.Errorf("%w: only the counterparty of the latest offer can counter it", ErrForbidden)
+	ErrExchangeCounterSame      = errors.New("counter offer must change at least one book")
+	ErrExchangeForbidden        = fmt.Errorf("%w: not a participant of the exchange", ErrForbidden)
+	ErrExchangeNoBooks          = errors.New("each side of the exchange needs at least one book")
+	ErrExchangeTooManyItems     = errors.New("too many books on one side of the exchange")
+	ErrExchangeAlreadyConfirmed = errors.New("handover is already confirmed by this participant")
+	ErrExchangeCounterBundle    = errors.New("counter-offers can only replace a side with a single book")
+	ErrExchangeDeliveryMethod   = errors.New("delivery method must be in_person or postal")
+	ErrExchangePostalComplete   = errors.New("postal exchanges are completed by confirming the deliveries")
 
 	ErrReviewTextRequired    = errors.New("review text is required")
 	ErrReviewTextLength      = errors.New("review text must be between 10 and 150 characters")
 	ErrInvalidRating         = errors.New("rating must be between 1 and 5")
-	ErrSelfReviewForbidden   = errors.New("cannot leave review to yourself")
-	ErrReviewDeleteForbidden = errors.New("you are not allowed to delete this review")
+	ErrSelfReviewForbidden   = fmt.Errorf("%w: cannot leave review to yourself", ErrForbidden)
+	ErrReviewDeleteForbidden = fmt.Errorf("%w: you are not allowed to delete this review", ErrForbidden)
 
 	ErrEmailAlreadyUsed        = errors.New("email already in use")
 	ErrInvalidCredentials      = errors.New("invalid credentials")
@@ -61,4 +173,80 @@ var (
 	ErrUserListFailed          = errors.New("failed to list users")
 	ErrUserProfileStatsFailed  = errors.New("failed to calculate user profile stats")
 	ErrUserPasswordHashFailed  = errors.New("failed to hash password")
+	ErrUserInvalidRole         = errors.New("role must be user, moderator or admin")
+	ErrUserForbidden           = fmt.Errorf("%w: not allowed to access this user", ErrForbidden)
+	ErrInvalidEmail            = errors.New("invalid email address")
+
+	ErrEmailNotVerified         = fmt.Errorf("%w: confirm your email before creating exchanges", ErrForbidden)
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+	ErrVerificationTokenInvalid = errors.New("verification link is invalid or expired")
+	ErrVerificationRateLimited  = errors.New("verification email was sent recently, try again later")
+	ErrVerificationSendFailed   = errors.New("failed to send verification email")
+
+	ErrPasswordResetTokenInvalid = errors.New("reset token is invalid or expired")
+	ErrPasswordResetFailed       = errors.New("failed to reset password")
+	ErrPasswordTooShort          = errors.New("password must be at least 8 characters")
+	ErrAdminStatsFailed          = errors.New("failed to calculate statistics")
+
+	ErrOIDCProviderUnknown   = errors.New("unknown login provider")
+	ErrOIDCStateInvalid      = errors.New("login request is invalid or expired, start again")
+	ErrOIDCLoginFailed       = errors.New("failed to sign in with the provider")
+	ErrOIDCEmailNotVerified  = fmt.Errorf("%w: the provider did not confirm your email", ErrForbidden)
+	ErrOIDCAccountUnverified = errors.New("an account with this email exists but its email is not confirmed, confirm it or log in with the password first")
+
+	ErrTwoFactorAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp         = errors.New("start two-factor setup first")
+	ErrTwoFactorCodeInvalid      = errors.New("invalid authentication code")
+	ErrTwoFactorChallengeInvalid = errors.New("login challenge is invalid or expired, log in again")
+	ErrTwoFactorUnavailable      = errors.New("two-factor login is temporarily unavailable")
+	ErrTwoFactorFailed           = errors.New("failed to update two-factor authentication")
+
+	ErrLoginThrottled     = errors.New("too many failed login attempts, try again later")
+	ErrAccountLocked      = errors.New("account is temporarily locked after too many failed login attempts, check your email to unlock it")
+	ErrUnlockTokenInvalid = errors.New("unlock link is invalid or expired")
+
+	ErrPersonalAccessTokenNameRequired  = errors.New("token name is required and must be at most 100 characters")
+	ErrPersonalAccessTokenInvalidScope  = errors.New("unknown or missing token scope")
+	ErrPersonalAccessTokenInvalidExpiry = errors.New("expires_in_days must be between 1 and 365")
+	ErrPersonalAccessTokenLimit         = errors.New("too many personal access tokens, revoke unused ones first")
+	ErrPersonalAccessTokenNotFound      = errors.New("personal access token not found")
+	ErrPersonalAccessTokenInvalid       = errors.New("personal access token is invalid or expired")
+	ErrPersonalAccessTokenFailed        = errors.New("failed to process personal access token")
+
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, all sessions of this login were revoked")
+	ErrTokenIssueFailed    = errors.New("failed to issue tokens")
+	ErrTokenRevokeFailed   = errors.New("failed to revoke tokens")
 )
+
+// BookConflictError is returned when a book could not be reserved because a
+// concurrent request changed it first. It matches ErrBookConflict as well as
+// the side specific error in Err.
+type BookConflictError struct {
+	BookIDs []uint
+	Err     error
+}
+
+func (e *BookConflictError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BookConflictError) Unwrap() []error {
+	return []error{e.Err, ErrBookConflict}
+}
+
+// LoginThrottledError is returned when a login attempt is refused before the
+// password is checked. It matches ErrLoginThrottled or ErrAccountLocked.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *LoginThrottledError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return e.Err
+}
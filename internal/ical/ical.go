@@ -0,0 +1,94 @@
+package ical
+
+import (
+	"strings"
+	"time"
+)
+
+// Event is a single VEVENT of an iCalendar file (RFC 5545).
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Location    string
+	Created     time.Time
+}
+
+const (
+	prodID     = "-//bookcrossing//meetups//EN"
+	timeLayout = "20060102T150405Z"
+	lineLimit  = 75
+)
+
+// Marshal renders the events as a VCALENDAR object with CRLF line endings
+// and folded content lines.
+func Marshal(events ...Event) []byte {
+	var b strings.Builder
+
+	writeLine(&b, "BEGIN:VCALENDAR")
+	writeLine(&b, "VERSION:2.0")
+	writeLine(&b, "PRODID:"+prodID)
+	writeLine(&b, "CALSCALE:GREGORIAN")
+	writeLine(&b, "METHOD:PUBLISH")
+
+	stamp := time.Now().UTC().Format(timeLayout)
+	for _, e := range events {
+		writeLine(&b, "BEGIN:VEVENT")
+		writeLine(&b, "UID:"+escapeText(e.UID))
+		writeLine(&b, "DTSTAMP:"+stamp)
+		if !e.Created.IsZero() {
+			writeLine(&b, "CREATED:"+e.Created.UTC().Format(timeLayout))
+		}
+		writeLine(&b, "DTSTART:"+e.Start.UTC().Format(timeLayout))
+		writeLine(&b, "DTEND:"+e.End.UTC().Format(timeLayout))
+		writeLine(&b, "SUMMARY:"+escapeText(e.Summary))
+		if e.Description != "" {
+			writeLine(&b, "DESCRIPTION:"+escapeText(e.Description))
+		}
+		if e.Location != "" {
+			writeLine(&b, "LOCATION:"+escapeText(e.Location))
+		}
+		writeLine(&b, "END:VEVENT")
+	}
+
+	writeLine(&b, "END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// escapeText escapes a TEXT value (RFC 5545, section 3.3.11).
+func escapeText(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	)
+	return r.Replace(s)
+}
+
+// writeLine folds lines longer than 75 octets without splitting UTF-8
+// sequences (RFC 5545, section 3.1).
+func writeLine(b *strings.Builder, line string) {
+	limit := lineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// continuation lines start with a space that counts toward the limit
+		limit = lineLimit - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
@@ -0,0 +1,234 @@
+// Package idempotency stores responses of mutating requests so that retries
+// with the same Idempotency-Key are answered without running the request again.
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Record is a reserved key. Done is false while the first request is still
+// being processed.
+type Record struct {
+	RequestHash string `json:"request_hash"`
+	Done        bool   `json:"done"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+type Store interface {
+	// Reserve creates a pending record for key unless one exists. It returns
+	// the existing record and false when the key is already taken.
+	Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*Record, bool, error)
+	// Complete replaces the pending record with the final response.
+	Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error
+	// Release drops a pending record so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+const redisPrefix = "idempotency:"
+
+type RedisStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisStore(rdb *redis.Client) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+func (s *RedisStore) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*Record, bool, error) {
+	pending, err := json.Marshal(Record{RequestHash: requestHash})
+	if err != nil {
+		return nil, false, err
+	}
+
+	ok, err := s.rdb.SetNX(ctx, redisPrefix+key, pending, ttl).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if ok {
+		return nil, true, nil
+	}
+
+	raw, err := s.rdb.Get(ctx, redisPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// expired between SETNX and GET
+		return s.Reserve(ctx, key, requestHash, ttl)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var rec Record
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return nil, false, err
+	}
+	return &rec, false, nil
+}
+
+func (s *RedisStore) Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, redisPrefix+key, raw, ttl).Err()
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return s.rdb.Del(ctx, redisPrefix+key).Err()
+}
+
+type DBStore struct {
+	db *gorm.DB
+}
+
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*Record, bool, error) {
+	db := s.db.WithContext(ctx)
+	now := time.Now()
+
+	// an expired record frees the key
+	if err := db.Where("key = ? AND expires_at <= ?", key, now).Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	row := models.IdempotencyKey{Key: key, RequestHash: requestHash, ExpiresAt: now.Add(ttl)}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return nil, true, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := db.Where("key = ?", key).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	return &Record{
+		RequestHash: existing.RequestHash,
+		Done:        existing.Done,
+		Status:      existing.Status,
+		ContentType: existing.ContentType,
+		Body:        existing.Body,
+	}, false, nil
+}
+
+// Complete upserts the record: when the key was reserved in another store,
+// e.g. before Redis failed, there is no pending row to update.
+func (s *DBStore) Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	row := models.IdempotencyKey{
+		Key:         key,
+		RequestHash: rec.RequestHash,
+		Done:        true,
+		Status:      rec.Status,
+		ContentType: rec.ContentType,
+		Body:        rec.Body,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"done", "status", "content_type", "body", "expires_at"}),
+	}).Create(&row).Error
+}
+
+// Completed returns the stored response for key, or nil when there is none.
+func (s *DBStore) Completed(ctx context.Context, key string) (*Record, error) {
+	var row models.IdempotencyKey
+	err := s.db.WithContext(ctx).Where("key = ? AND done = ? AND expires_at > ?", key, true, time.Now()).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Record{
+		RequestHash: row.RequestHash,
+		Done:        row.Done,
+		Status:      row.Status,
+		ContentType: row.ContentType,
+		Body:        row.Body,
+	}, nil
+}
+
+func (s *DBStore) Release(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("key = ?", key).Delete(&models.IdempotencyKey{}).Error
+}
+
+// FallbackStore uses the primary store and switches to the fallback for a
+// call when the primary fails, e.g. while Redis is down.
+type FallbackStore struct {
+	primary  Store
+	fallback Store
+	log      *slog.Logger
+}
+
+func NewFallbackStore(primary, fallback Store, log *slog.Logger) *FallbackStore {
+	return &FallbackStore{primary: primary, fallback: fallback, log: log}
+}
+
+// completedLookup is implemented by stores that can return a finished
+// response without reserving the key, like DBStore.
+type completedLookup interface {
+	Completed(ctx context.Context, key string) (*Record, error)
+}
+
+// Reserve also looks for a response the fallback completed while the primary
+// failed, so a retry is answered from it instead of running the request again.
+func (s *FallbackStore) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*Record, bool, error) {
+	rec, created, err := s.primary.Reserve(ctx, key, requestHash, ttl)
+	if err != nil {
+		s.log.Warn("idempotency primary store failed, using fallback", "error", err)
+		return s.fallback.Reserve(ctx, key, requestHash, ttl)
+	}
+	if !created && rec.Done {
+		return rec, false, nil
+	}
+
+	lookup, ok := s.fallback.(completedLookup)
+	if !ok {
+		return rec, created, nil
+	}
+	done, err := lookup.Completed(ctx, key)
+	if err != nil {
+		s.log.Warn("idempotency fallback lookup failed", "error", err)
+		return rec, created, nil
+	}
+	if done == nil {
+		return rec, created, nil
+	}
+	if created {
+		if err := s.primary.Release(ctx, key); err != nil {
+			s.log.Warn("idempotency primary release failed", "error", err)
+		}
+	}
+	return done, false, nil
+}
+
+func (s *FallbackStore) Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	if err := s.primary.Complete(ctx, key, rec, ttl); err != nil {
+		s.log.Warn("idempotency primary store failed, using fallback", "error", err)
+		return s.fallback.Complete(ctx, key, rec, ttl)
+	}
+	return nil
+}
+
+func (s *FallbackStore) Release(ctx context.Context, key string) error {
+	if err := s.primary.Release(ctx, key); err != nil {
+		s.log.Warn("idempotency primary store failed, using fallback", "error", err)
+		return s.fallback.Release(ctx, key)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	mu         sync.Mutex
+	records    map[string]Record
+	failWrites bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: map[string]Record{}}
+}
+
+func (s *memoryStore) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.records[key]; ok {
+		return &rec, false, nil
+	}
+	s.records[key] = Record{RequestHash: requestHash}
+	return nil, true, nil
+}
+
+func (s *memoryStore) Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failWrites {
+		return errors.New("connection refused")
+	}
+	s.records[key] = rec
+	return nil
+}
+
+func (s *memoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// lookupStore adds Completed, like DBStore.
+type lookupStore struct{ *memoryStore }
+
+func (s lookupStore) Completed(ctx context.Context, key string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.records[key]; ok && rec.Done {
+		return &rec, nil
+	}
+	return nil, nil
+}
+
+func TestFallbackStoreKeepsResponseCompletedInFallback(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	primary, fallback := newMemoryStore(), lookupStore{newMemoryStore()}
+	store := NewFallbackStore(primary, fallback, log)
+
+	if _, created, err := store.Reserve(ctx, "key", "hash", time.Minute); err != nil || !created {
+		t.Fatalf("reserve = %v, %v", created, err)
+	}
+
+	// the primary fails after the reservation, the response only reaches
+	// the fallback
+	primary.failWrites = true
+	done := Record{RequestHash: "hash", Done: true, Status: 201, Body: []byte(`{"id":1}`)}
+	if err := store.Complete(ctx, "key", done, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	primary.failWrites = false
+
+	// the pending reservation in the primary expired
+	if err := primary.Release(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, created, err := store.Reserve(ctx, "key", "hash", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || rec == nil || !rec.Done || rec.Status != 201 {
+		t.Fatalf("retry was not answered from the fallback: %+v, created %v", rec, created)
+	}
+	if _, ok := primary.records["key"]; ok {
+		t.Fatal("primary keeps a pending reservation for a completed request")
+	}
+}
@@ -0,0 +1,322 @@
+package jwtutil
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrNoSigningKey = errors.New("jwt: no signing key configured, set JWT_SIGNING_KEY or SUPER_SECRET_KEY")
+
+// KeySet holds the key new tokens are signed with and every key tokens are
+// still verified with. Rotating means pointing JWT_SIGNING_KEY at a new file
+// and moving the old one to JWT_VERIFY_KEYS until its tokens have expired.
+type KeySet struct {
+	signing *key
+	keys    map[string]*key
+}
+
+type key struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// JWK is a public key as published in the JWKS document (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+var (
+	current  atomic.Pointer[KeySet]
+	loadOnce sync.Once
+	loadErr  error
+)
+
+// UseKeys installs the key set used by GenerateToken and ParseToken.
+func UseKeys(ks *KeySet) {
+	current.Store(ks)
+}
+
+// keySet returns the installed key set, loading it from the environment on
+// first use when UseKeys was not called.
+func keySet() (*KeySet, error) {
+	if ks := current.Load(); ks != nil {
+		return ks, nil
+	}
+
+	loadOnce.Do(func() {
+		var ks *KeySet
+		ks, loadErr = LoadKeysFromEnv()
+		if loadErr == nil {
+			current.CompareAndSwap(nil, ks)
+		}
+	})
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	return current.Load(), nil
+}
+
+// LoadKeysFromEnv builds the key set from JWT_SIGNING_KEY, a PEM encoded
+// RSA (RS256) or Ed25519 (EdDSA) private key, and JWT_VERIFY_KEYS, a comma
+// separated list of PEM files with older public or private keys. Without
+// JWT_SIGNING_KEY tokens are signed with HS256 and SUPER_SECRET_KEY, which
+// must not be empty.
+func LoadKeysFromEnv() (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*key{}}
+
+	if path := strings.TrimSpace(os.Getenv("JWT_SIGNING_KEY")); path != "" {
+		k, err := loadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if k.private == nil {
+			return nil, fmt.Errorf("jwt: %s holds no private key", path)
+		}
+		ks.signing = k
+		ks.keys[k.kid] = k
+
+		for _, path := range strings.Split(os.Getenv("JWT_VERIFY_KEYS"), ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+			k, err := loadKeyFile(path)
+			if err != nil {
+				return nil, err
+			}
+			ks.keys[k.kid] = k
+		}
+		return ks, nil
+	}
+
+	secret := os.Getenv("SUPER_SECRET_KEY")
+	if secret == "" {
+		return nil, ErrNoSigningKey
+	}
+	ks.signing = &key{method: jwt.SigningMethodHS256, private: []byte(secret), public: []byte(secret)}
+	return ks, nil
+}
+
+// Algorithm is the alg new tokens are signed with.
+func (ks *KeySet) Algorithm() string {
+	return ks.signing.method.Alg()
+}
+
+// KeyIDs lists the kids accepted for verification.
+func (ks *KeySet) KeyIDs() []string {
+	ids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		ids = append(ids, kid)
+	}
+	return ids
+}
+
+// PublicJWKS returns the public keys of the installed key set. It is empty
+// when tokens are signed with HS256.
+func PublicJWKS() (JWKS, error) {
+	ks, err := keySet()
+	if err != nil {
+		return JWKS{}, err
+	}
+
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, k := range ks.keys {
+		jwk, err := publicJWK(k)
+		if err != nil {
+			return JWKS{}, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	if ks.signing.kid != "" {
+		token.Header["kid"] = ks.signing.kid
+	}
+	return token.SignedString(ks.signing.private)
+}
+
+// verificationKey pins the algorithm to the key, so a token cannot pick a
+// weaker algorithm or use a public key as an HMAC secret.
+func (ks *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	if ks.signing.method == jwt.SigningMethodHS256 {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return ks.signing.public, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("key %q does not sign with %s", kid, token.Method.Alg())
+	}
+	return k.public, nil
+}
+
+func (ks *KeySet) methods() []string {
+	seen := map[string]bool{ks.signing.method.Alg(): true}
+	for _, k := range ks.keys {
+		seen[k.method.Alg()] = true
+	}
+
+	methods := make([]string, 0, len(seen))
+	for alg := range seen {
+		methods = append(methods, alg)
+	}
+	return methods
+}
+
+func loadKeyFile(path string) (*key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: read key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("jwt: %s is not PEM encoded", path)
+	}
+
+	k, err := parseKey(block)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: %s: %w", path, err)
+	}
+	return k, nil
+}
+
+func parseKey(block *pem.Block) (*key, error) {
+	var (
+		private crypto.PrivateKey
+		public  crypto.PublicKey
+		err     error
+	)
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k := &key{private: private, public: public}
+	switch p := private.(type) {
+	case *rsa.PrivateKey:
+		k.public = &p.PublicKey
+	case ed25519.PrivateKey:
+		k.public = p.Public()
+	case nil:
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+
+	switch p := k.public.(type) {
+	case *rsa.PublicKey:
+		if p.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must have at least 2048 bits")
+		}
+		k.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		k.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", k.public)
+	}
+
+	jwk, err := publicJWK(k)
+	if err != nil {
+		return nil, err
+	}
+	k.kid = thumbprint(jwk)
+
+	return k, nil
+}
+
+func publicJWK(k *key) (JWK, error) {
+	switch p := k.public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: k.kid,
+			Use: "sig",
+			Alg: k.method.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(p.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: k.kid,
+			Use: "sig",
+			Alg: k.method.Alg(),
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(p),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("jwt: no JWK form for %T", k.public)
+	}
+}
+
+// thumbprint is the RFC 7638 JWK thumbprint, used as kid so the same key
+// always gets the same id without extra configuration.
+func thumbprint(jwk JWK) string {
+	var members any
+	if jwk.Kty == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	b, _ := json.Marshal(members)
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
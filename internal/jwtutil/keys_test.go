@@ -0,0 +1,182 @@
+package jwtutil
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func ed25519KeyFile(t *testing.T) (string, ed25519.PrivateKey) {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, "PRIVATE KEY", der), priv
+}
+
+func rsaKeyFile(t *testing.T) string {
+	t.Helper()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(priv))
+}
+
+func TestLoadKeysRequiresAKey(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "")
+	t.Setenv("SUPER_SECRET_KEY", "")
+
+	if _, err := LoadKeysFromEnv(); !errors.Is(err, ErrNoSigningKey) {
+		t.Fatalf("got %v, want ErrNoSigningKey", err)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	edPath, _ := ed25519KeyFile(t)
+	rsaPath := rsaKeyFile(t)
+
+	tests := []struct {
+		name string
+		key  string
+		alg  string
+	}{
+		{"ed25519", edPath, "EdDSA"},
+		{"rsa", rsaPath, "RS256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SIGNING_KEY", tt.key)
+			t.Setenv("JWT_VERIFY_KEYS", "")
+
+			ks, err := LoadKeysFromEnv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ks.Algorithm() != tt.alg {
+				t.Fatalf("alg = %s, want %s", ks.Algorithm(), tt.alg)
+			}
+			UseKeys(ks)
+
+			token, err := GenerateToken(7, "user")
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := ParseToken(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UserID != 7 {
+				t.Fatalf("user id = %d", claims.UserID)
+			}
+
+			set, err := PublicJWKS()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(set.Keys) != 1 || set.Keys[0].Kid != ks.signing.kid || set.Keys[0].Alg != tt.alg {
+				t.Fatalf("unexpected jwks %+v", set)
+			}
+		})
+	}
+}
+
+func TestRotationKeepsOldKeysValid(t *testing.T) {
+	oldPath, _ := ed25519KeyFile(t)
+	newPath, _ := ed25519KeyFile(t)
+
+	t.Setenv("JWT_SIGNING_KEY", oldPath)
+	t.Setenv("JWT_VERIFY_KEYS", "")
+	oldKeys, err := LoadKeysFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	UseKeys(oldKeys)
+	oldToken, err := GenerateToken(1, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("JWT_SIGNING_KEY", newPath)
+	t.Setenv("JWT_VERIFY_KEYS", oldPath)
+	rotated, err := LoadKeysFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	UseKeys(rotated)
+
+	if _, err := ParseToken(oldToken); err != nil {
+		t.Fatalf("token of the retired key rejected: %v", err)
+	}
+
+	t.Setenv("JWT_VERIFY_KEYS", "")
+	dropped, err := LoadKeysFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	UseKeys(dropped)
+
+	if _, err := ParseToken(oldToken); err == nil {
+		t.Fatal("token of a removed key accepted")
+	}
+}
+
+func TestRejectsAlgorithmConfusion(t *testing.T) {
+	path, priv := ed25519KeyFile(t)
+	t.Setenv("JWT_SIGNING_KEY", path)
+	t.Setenv("JWT_VERIFY_KEYS", "")
+
+	ks, err := LoadKeysFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	UseKeys(ks)
+
+	claims := Claims{UserID: 1, RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}}
+
+	// an HS256 token keyed with the published public key
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hmacToken.Header["kid"] = ks.signing.kid
+	forged, err := hmacToken.SignedString([]byte(priv.Public().(ed25519.PublicKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(forged); err == nil {
+		t.Fatal("HS256 token accepted by an EdDSA key set")
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(unsigned); err == nil {
+		t.Fatal("unsigned token accepted")
+	}
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/idempotency"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyHeader    = "Idempotency-Key"
+	idempotencyReplayed  = "Idempotent-Replayed"
+	maxIdempotencyKeyLen = 255
+	// a pending key expires quickly so a crashed request does not block retries
+	idempotencyPendingTTL = time.Minute
+	idempotencyStoreTime  = 2 * time.Second
+)
+
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency replays the stored response when a request is retried with the
+// same Idempotency-Key by the same user on the same route. Reusing a key with
+// a different payload is rejected with 422. Requests without the header pass
+// through unchanged, and so does everything when store is nil. It must run
+// after JWTAuth on authenticated routes; on anonymous routes such as
+// registration keys are scoped to the client IP, so one client cannot be
+// served another client's stored response.
+func Idempotency(store idempotency.Store, ttl time.Duration, log *slog.Logger) gin.HandlerFunc {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if store == nil || key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLen {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		route := c.Request.Method + " " + c.FullPath()
+		storeKey := hashParts(idempotencyScope(c), route, key)
+		requestHash := hashParts(route, string(body))
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), idempotencyStoreTime)
+		rec, created, err := store.Reserve(ctx, storeKey, requestHash, idempotencyPendingTTL)
+		cancel()
+		if err != nil {
+			log.Error("idempotency reserve failed", "error", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "idempotency store unavailable"})
+			return
+		}
+
+		if !created {
+			switch {
+			case rec.RequestHash != requestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different payload"})
+			case !rec.Done:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still in progress"})
+			default:
+				c.Header(idempotencyReplayed, "true")
+				c.Data(rec.Status, rec.ContentType, rec.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		writer := &idempotencyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			// the handler panicked: free the key for a retry
+			ctx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTime)
+			defer cancel()
+			if err := store.Release(ctx, storeKey); err != nil {
+				log.Error("idempotency release failed", "error", err)
+			}
+		}()
+
+		c.Next()
+
+		ctx, cancel = context.WithTimeout(context.Background(), idempotencyStoreTime)
+		defer cancel()
+
+		// server errors are not stored so the client can retry them
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+
+		completed = true
+		if err := store.Complete(ctx, storeKey, idempotency.Record{
+			RequestHash: requestHash,
+			Done:        true,
+			Status:      status,
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}, ttl); err != nil {
+			log.Error("idempotency complete failed", "error", err)
+		}
+	}
+}
+
+func idempotencyScope(c *gin.Context) string {
+	if userID := c.GetUint("user_id"); userID != 0 {
+		return "user:" + strconv.FormatUint(uint64(userID), 10)
+	}
+	return "anon:" + c.ClientIP()
+}
+
+func hashParts(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/idempotency"
+	"github.com/gin-gonic/gin"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]idempotency.Record
+}
+
+func (s *memoryIdempotencyStore) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*idempotency.Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.records[key]; ok {
+		return &rec, false, nil
+	}
+	s.records[key] = idempotency.Record{RequestHash: requestHash}
+	return nil, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, key string, rec idempotency.Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = rec
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func TestIdempotencyAnonymousScopedByClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	router := gin.New()
+	router.POST("/users/register",
+		Idempotency(&memoryIdempotencyStore{records: map[string]idempotency.Record{}}, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil))),
+		func(c *gin.Context) {
+			calls++
+			c.JSON(http.StatusCreated, gin.H{"client": c.ClientIP()})
+		})
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users/register", strings.NewReader(`{"email":"a@example.com"}`))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Idempotency-Key", "same-key")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	send("192.0.2.1:1000")
+	if w := send("192.0.2.1:1001"); w.Header().Get(idempotencyReplayed) != "true" {
+		t.Fatal("retry from the same client was not replayed")
+	}
+	if w := send("198.51.100.7:1000"); w.Header().Get(idempotencyReplayed) != "" || !strings.Contains(w.Body.String(), "198.51.100.7") {
+		t.Fatalf("another client got a replayed response: %s", w.Body.String())
+	}
+	if calls != 2 {
+		t.Fatalf("handler ran %d times, want 2", calls)
+	}
+}
@@ -1,14 +1,51 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/jwtutil"
+	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/gin-gonic/gin"
 )
 
-func JWTAuth() gin.HandlerFunc {
+// TokenDenylist reports whether an access token was revoked before it expired,
+// either on its own or together with every other token of the user.
+type TokenDenylist interface {
+	JTIDenied(ctx context.Context, jti string) (bool, error)
+	UserRevokedAt(ctx context.Context, userID uint) (time.Time, error)
+}
+
+var denylist TokenDenylist
+
+// AccessTokenVerifier checks personal access tokens. It returns the owner,
+// the owner's role and the scopes granted to the token.
+type AccessTokenVerifier interface {
+	VerifyAccessToken(ctx context.Context, token string) (uint, string, []string, error)
+}
+
+var accessTokens AccessTokenVerifier
+
+// UseTokenDenylist makes JWTAuth reject tokens whose jti is in d. It is set
+// once at startup; without it revoked tokens stay valid until they expire.
+func UseTokenDenylist(d TokenDenylist) {
+	denylist = d
+}
+
+// UsePersonalAccessTokens makes JWTAuth accept personal access tokens on
+// routes that name their scopes. It is set once at startup.
+func UsePersonalAccessTokens(v AccessTokenVerifier) {
+	accessTokens = v
+}
+
+// JWTAuth authenticates the request with a JWT or, when scopes are given, a
+// personal access token that was granted all of them. Routes without scopes
+// only accept JWTs, so a leaked script token cannot manage the account.
+func JWTAuth(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
 		if auth == "" {
@@ -22,14 +59,128 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
+		if strings.HasPrefix(parts[1], models.PersonalAccessTokenPrefix) {
+			personalAccessTokenAuth(c, parts[1], scopes)
+			return
+		}
+
 		claims, err := jwtutil.ParseToken(parts[1])
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			return
 		}
 
+		if denylist != nil {
+			denied, err := tokenDenied(c.Request.Context(), claims)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "token check unavailable"})
+				return
+			}
+			if denied {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
+				return
+			}
+		}
+
+		role := claims.Role
+		if role == "" {
+			role = models.RoleUser
+		}
+
 		c.Set("user_id", claims.UserID)
+		c.Set("role", role)
+		c.Set("jti", claims.ID)
+		if claims.ExpiresAt != nil {
+			c.Set("token_expires_at", claims.ExpiresAt.Time)
+		}
 
 		c.Next()
 	}
 }
+
+func tokenDenied(ctx context.Context, claims *jwtutil.Claims) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+
+	if claims.ID != "" {
+		denied, err := denylist.JTIDenied(ctx, claims.ID)
+		if err != nil || denied {
+			return denied, err
+		}
+	}
+
+	revokedAt, err := denylist.UserRevokedAt(ctx, claims.UserID)
+	if err != nil || revokedAt.IsZero() {
+		return false, err
+	}
+	// iat has second precision, so compare against the cutoff's second
+	return claims.IssuedAt == nil || claims.IssuedAt.Time.Before(revokedAt.Truncate(time.Second)), nil
+}
+
+func personalAccessTokenAuth(c *gin.Context, token string, scopes []string) {
+	if len(scopes) == 0 || accessTokens == nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "personal access tokens are not accepted here"})
+		return
+	}
+
+	userID, role, granted, err := accessTokens.VerifyAccessToken(c.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, dto.ErrPersonalAccessTokenInvalid) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "token check unavailable"})
+		return
+	}
+
+	for _, scope := range scopes {
+		if !HasScope(granted, scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "token is missing scope " + scope})
+			return
+		}
+	}
+
+	if role == "" {
+		role = models.RoleUser
+	}
+
+	c.Set("user_id", userID)
+	c.Set("role", role)
+	c.Set("scopes", granted)
+
+	c.Next()
+}
+
+// RequireScope narrows a route in a group that JWTAuth guards with a broader
+// scope, e.g. a write endpoint among reads. Requests with a JWT pass.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get("scopes")
+		if !ok {
+			c.Next()
+			return
+		}
+
+		granted, _ := value.([]string)
+		for _, scope := range scopes {
+			if !HasScope(granted, scope) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "token is missing scope " + scope})
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// HasScope reports whether granted covers scope. A write scope includes the
+// read scope of the same resource.
+func HasScope(granted []string, scope string) bool {
+	resource, action, _ := strings.Cut(scope, ":")
+	for _, g := range granted {
+		if g == scope || (action == "read" && g == resource+":write") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+type fakeAccessTokens map[string][]string
+
+func (f fakeAccessTokens) VerifyAccessToken(ctx context.Context, token string) (uint, string, []string, error) {
+	scopes, ok := f[token]
+	if !ok {
+		return 0, "", nil, dto.ErrPersonalAccessTokenInvalid
+	}
+	return 5, models.RoleUser, scopes, nil
+}
+
+func TestPersonalAccessTokenScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	prev := accessTokens
+	UsePersonalAccessTokens(fakeAccessTokens{
+		"bcpat_reader": {models.ScopeExchangesRead},
+		"bcpat_writer": {models.ScopeExchangesWrite},
+	})
+	t.Cleanup(func() { accessTokens = prev })
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	r := gin.New()
+	exchanges := r.Group("/exchanges", JWTAuth(models.ScopeExchangesRead))
+	exchanges.GET("/:id", ok)
+	exchanges.PUT("/:id/accept", RequireScope(models.ScopeExchangesWrite), ok)
+	r.GET("/users/me/tokens", JWTAuth(), ok)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"read scope reads", http.MethodGet, "/exchanges/1", "bcpat_reader", http.StatusOK},
+		{"read scope cannot write", http.MethodPut, "/exchanges/1/accept", "bcpat_reader", http.StatusForbidden},
+		{"write scope includes read", http.MethodGet, "/exchanges/1", "bcpat_writer", http.StatusOK},
+		{"write scope writes", http.MethodPut, "/exchanges/1/accept", "bcpat_writer", http.StatusOK},
+		{"unscoped route rejects tokens", http.MethodGet, "/users/me/tokens", "bcpat_writer", http.StatusForbidden},
+		{"unknown token", http.MethodGet, "/exchanges/1", "bcpat_unknown", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
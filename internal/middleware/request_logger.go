@@ -18,6 +18,7 @@ func RequestLogger(log *slog.Logger) gin.HandlerFunc {
 			rid = newRequestID()
 		}
 		c.Writer.Header().Set(requestIDHeader, rid)
+		c.Set("request_id", rid)
 
 		start := time.Now()
 		c.Next()
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// RefreshToken is the database copy of an issued refresh token. Only the
+// SHA-256 hash of the token is stored. Tokens rotated from the same login
+// share a FamilyID.
+type RefreshToken struct {
+	ID        uint   `gorm:"primarykey"`
+	TokenHash string `gorm:"uniqueIndex;size:64"`
+	UserID    uint   `gorm:"index"`
+	FamilyID  string `gorm:"index;size:64"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// RevokedToken denies an access token jti, a whole refresh token family or
+// every token a user got before RevokedAt, until ExpiresAt.
+type RevokedToken struct {
+	ID        uint   `gorm:"primarykey"`
+	Kind      string `gorm:"uniqueIndex:idx_revoked_tokens_kind_value;size:16"`
+	Value     string `gorm:"uniqueIndex:idx_revoked_tokens_kind_value;size:64"`
+	RevokedAt time.Time
+	ExpiresAt time.Time `gorm:"index"`
+}
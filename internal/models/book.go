@@ -10,6 +10,7 @@ type Book struct {
 	AISummary   string `json:"aisummary"`
 	Status      string `json:"status" gorm:"enum:available,reserved"`
 	UserID      uint   `json:"user_id"`
+	Version     uint   `json:"version" gorm:"not null;default:0"`
 
 	User   *User   `json:"user" gorm:"foreignKey:UserID"`
 	Genres []Genre `json:"genres" gorm:"many2many:book_genres"`
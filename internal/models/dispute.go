@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Dispute struct {
+	gorm.Model
+	ExchangeID     uint       `json:"exchange_id" gorm:"index"`
+	OpenedByID     uint       `json:"opened_by_id"`
+	Reason         string     `json:"reason" gorm:"enum:not_delivered,wrong_condition,other"`
+	Description    string     `json:"description"`
+	Status         string     `json:"status" gorm:"index;enum:open,in_review,resolved"`
+	ModeratorID    *uint      `json:"moderator_id,omitempty"`
+	Resolution     string     `json:"resolution,omitempty" gorm:"enum:reverse_transfer,adjust_reputation,no_action"`
+	ResolutionNote string     `json:"resolution_note,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+
+	Evidence []DisputeEvidence `json:"evidence,omitempty" gorm:"foreignKey:DisputeID"`
+	Events   []DisputeEvent    `json:"events,omitempty" gorm:"foreignKey:DisputeID"`
+}
+
+// DisputeEvidence is a file attached to a dispute. Path points to the stored
+// file and is never exposed to clients.
+type DisputeEvidence struct {
+	gorm.Model
+	DisputeID   uint   `json:"dispute_id" gorm:"index"`
+	UploaderID  uint   `json:"uploader_id"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Path        string `json:"-"`
+}
+
+// DisputeEvent is the audit trail of a dispute.
+type DisputeEvent struct {
+	gorm.Model
+	DisputeID uint   `json:"dispute_id" gorm:"index"`
+	ActorID   uint   `json:"actor_id"`
+	Action    string `json:"action" gorm:"enum:opened,evidence_added,claimed,resolved"`
+	Note      string `json:"note,omitempty"`
+}
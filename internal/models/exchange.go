@@ -10,14 +10,45 @@ type Exchange struct {
 	gorm.Model
 	InitiatorID     uint       `json:"initiator_id"`
 	RecipientID     uint       `json:"recipient_id"`
-	InitiatorBookID uint       `json:"initiator_book_id"`
-	RecipientBookID uint       `json:"recipient_book_id"`
-	Status          string     `json:"status" gorm:"enum:pending,accepted,completed,cancelled"`
+	InitiatorBookID uint       `json:"initiator_book_id" gorm:"index"`
+	RecipientBookID uint       `json:"recipient_book_id" gorm:"index"`
+	ProposerID      uint       `json:"proposer_id"`
+	DeliveryMethod  string     `json:"delivery_method" gorm:"not null;default:in_person;enum:in_person,postal"`
+	Status          string     `json:"status" gorm:"enum:pending,accepted,completed,cancelled,declined,reversed"`
+	DeclineReason   string     `json:"decline_reason,omitempty"`
+	CancelReason    string     `json:"cancel_reason,omitempty"`
+	FollowUpAt      *time.Time `json:"follow_up_at,omitempty"`
 	CompletedAt     *time.Time `json:"completed_at"`
 
+	InitiatorConfirmedAt *time.Time `json:"initiator_confirmed_at"`
+	RecipientConfirmedAt *time.Time `json:"recipient_confirmed_at"`
+
 	Initiator *User `json:"initiator" gorm:"foreignKey:InitiatorID"`
 	Recipient *User `json:"recipient" gorm:"foreignKey:RecipientID"`
 
 	InitiatorBook *Book `json:"initiator_book" gorm:"foreignKey:InitiatorBookID"`
 	RecipientBook *Book `json:"recipient_book" gorm:"foreignKey:RecipientBookID"`
+
+	Items     []ExchangeItem     `json:"items,omitempty" gorm:"foreignKey:ExchangeID"`
+	Offers    []ExchangeOffer    `json:"offers,omitempty" gorm:"foreignKey:ExchangeID"`
+	Shipments []ExchangeShipment `json:"shipments,omitempty" gorm:"foreignKey:ExchangeID"`
+}
+
+// BookIDs returns the books on each side of the exchange. Exchanges created
+// before bundles existed have no items and fall back to the single book
+// columns.
+func (e *Exchange) BookIDs() ([]uint, []uint) {
+	if len(e.Items) == 0 {
+		return []uint{e.InitiatorBookID}, []uint{e.RecipientBookID}
+	}
+
+	initiatorBookIDs, recipientBookIDs := []uint{}, []uint{}
+	for _, item := range e.Items {
+		if item.Side == "initiator" {
+			initiatorBookIDs = append(initiatorBookIDs, item.BookID)
+		} else {
+			recipientBookIDs = append(recipientBookIDs, item.BookID)
+		}
+	}
+	return initiatorBookIDs, recipientBookIDs
 }
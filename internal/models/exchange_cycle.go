@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ExchangeCycle is a multi-party exchange proposed by the matcher, where every
+// participant gives one book to the next member of the cycle.
+type ExchangeCycle struct {
+	gorm.Model
+	Status      string     `json:"status" gorm:"enum:proposed,accepted,completed,cancelled"`
+	CompletedAt *time.Time `json:"completed_at"`
+
+	Legs []ExchangeCycleLeg `json:"legs" gorm:"foreignKey:CycleID"`
+}
+
+// ExchangeCycleLeg moves one book from the giver to the receiver.
+type ExchangeCycleLeg struct {
+	gorm.Model
+	CycleID     uint       `json:"cycle_id" gorm:"index"`
+	Position    int        `json:"position"`
+	GiverID     uint       `json:"giver_id" gorm:"index"`
+	ReceiverID  uint       `json:"receiver_id" gorm:"index"`
+	BookID      uint       `json:"book_id" gorm:"index"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+
+	Book *Book `json:"book,omitempty" gorm:"foreignKey:BookID"`
+}
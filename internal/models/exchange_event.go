@@ -0,0 +1,15 @@
+package models
+
+import "gorm.io/gorm"
+
+// ExchangeEvent is an append-only record of a status change of an exchange.
+// ActorID is zero for changes made by background jobs.
+type ExchangeEvent struct {
+	gorm.Model
+	ExchangeID uint   `json:"exchange_id" gorm:"index"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	ActorID    uint   `json:"actor_id"`
+	Reason     string `json:"reason,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+}
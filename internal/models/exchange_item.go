@@ -0,0 +1,13 @@
+package models
+
+import "gorm.io/gorm"
+
+// ExchangeItem is a single book on one side of an exchange.
+type ExchangeItem struct {
+	gorm.Model
+	ExchangeID uint   `json:"exchange_id" gorm:"index"`
+	BookID     uint   `json:"book_id" gorm:"index"`
+	Side       string `json:"side" gorm:"enum:initiator,recipient"`
+
+	Book *Book `json:"book,omitempty" gorm:"foreignKey:BookID"`
+}
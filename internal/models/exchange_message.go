@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ExchangeMessage struct {
+	gorm.Model
+	ExchangeID uint       `json:"exchange_id" gorm:"index"`
+	SenderID   uint       `json:"sender_id"`
+	Text       string     `json:"text"`
+	ReadAt     *time.Time `json:"read_at"`
+
+	Sender *User `json:"sender,omitempty" gorm:"foreignKey:SenderID"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "gorm.io/gorm"
+
+type ExchangeOffer struct {
+	gorm.Model
+	ExchangeID      uint   `json:"exchange_id" gorm:"index"`
+	ProposerID      uint   `json:"proposer_id"`
+	InitiatorBookID uint   `json:"initiator_book_id"`
+	RecipientBookID uint   `json:"recipient_book_id"`
+	PreviousOfferID *uint  `json:"previous_offer_id"`
+	Status          string `json:"status" gorm:"enum:proposed,countered,accepted"`
+
+	Proposer      *User `json:"proposer,omitempty" gorm:"foreignKey:ProposerID"`
+	InitiatorBook *Book `json:"initiator_book,omitempty" gorm:"foreignKey:InitiatorBookID"`
+	RecipientBook *Book `json:"recipient_book,omitempty" gorm:"foreignKey:RecipientBookID"`
+}
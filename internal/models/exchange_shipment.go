@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ExchangeShipment is one leg of a postal exchange: the books of one side on
+// their way to the other participant. Side names the sending side.
+type ExchangeShipment struct {
+	gorm.Model
+	ExchangeID     uint       `json:"exchange_id" gorm:"index"`
+	Side           string     `json:"side" gorm:"enum:initiator,recipient"`
+	SenderID       uint       `json:"sender_id"`
+	ReceiverID     uint       `json:"receiver_id"`
+	Status         string     `json:"status" gorm:"enum:pending,shipped,delivered"`
+	Carrier        string     `json:"carrier,omitempty"`
+	TrackingNumber string     `json:"tracking_number,omitempty"`
+	ShippedAt      *time.Time `json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IdempotencyKey is the database copy of a stored response, used when Redis
+// is unavailable.
+type IdempotencyKey struct {
+	ID          uint      `gorm:"primarykey"`
+	Key         string    `gorm:"uniqueIndex;size:128"`
+	RequestHash string    `gorm:"size:64"`
+	Done        bool      `gorm:"not null;default:false"`
+	Status      int       `gorm:"not null;default:0"`
+	ContentType string    `gorm:"size:255"`
+	Body        []byte    `gorm:"type:bytea"`
+	ExpiresAt   time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
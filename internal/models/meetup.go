@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Meetup is a proposal of where and when the participants of an exchange meet.
+type Meetup struct {
+	gorm.Model
+	ExchangeID      uint   `json:"exchange_id" gorm:"index"`
+	ProposerID      uint   `json:"proposer_id"`
+	Place           string `json:"place"`
+	Status          string `json:"status" gorm:"enum:proposed,confirmed,superseded"`
+	ConfirmedSlotID *uint  `json:"confirmed_slot_id"`
+
+	Slots []MeetupSlot `json:"slots" gorm:"foreignKey:MeetupID"`
+}
+
+type MeetupSlot struct {
+	gorm.Model
+	MeetupID uint      `json:"meetup_id" gorm:"index"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// MeetupReminder is a queued reminder about a confirmed meetup. A failed send
+// moves RemindAt to the next retry; FailedAt is set once it is given up.
+type MeetupReminder struct {
+	gorm.Model
+	MeetupID uint       `json:"meetup_id" gorm:"index"`
+	UserID   uint       `json:"user_id"`
+	RemindAt time.Time  `json:"remind_at" gorm:"index"`
+	SentAt   *time.Time `json:"sent_at"`
+	Attempts int        `json:"attempts" gorm:"not null;default:0"`
+	FailedAt *time.Time `json:"failed_at"`
+}
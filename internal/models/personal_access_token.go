@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// PersonalAccessTokenPrefix starts every personal access token, which is how
+// they are told apart from JWTs.
+const PersonalAccessTokenPrefix = "bcpat_"
+
+const (
+	ScopeBooksWrite     = "books:write"
+	ScopeExchangesRead  = "exchanges:read"
+	ScopeExchangesWrite = "exchanges:write"
+	ScopeReviewsWrite   = "reviews:write"
+	ScopeWishlistRead   = "wishlist:read"
+	ScopeWishlistWrite  = "wishlist:write"
+)
+
+// Scopes lists every scope a personal access token can be granted.
+var Scopes = []string{
+	ScopeBooksWrite,
+	ScopeExchangesRead,
+	ScopeExchangesWrite,
+	ScopeReviewsWrite,
+	ScopeWishlistRead,
+	ScopeWishlistWrite,
+}
+
+// PersonalAccessToken is a long-lived token for scripts. Only its SHA-256
+// hash is stored; TokenHint holds the last characters so users can tell
+// their tokens apart. Scopes is space separated.
+type PersonalAccessToken struct {
+	ID         uint   `gorm:"primarykey"`
+	UserID     uint   `gorm:"index;not null"`
+	Name       string `gorm:"size:100;not null"`
+	TokenHash  string `gorm:"uniqueIndex;size:64;not null"`
+	TokenHint  string `gorm:"size:8"`
+	Scopes     string `gorm:"not null"`
+	ExpiresAt  time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
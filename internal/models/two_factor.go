@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// TwoFactor is the TOTP enrollment of a user. Secret is encrypted at rest and
+// only counts for login once EnabledAt is set. LastUsedStep is the time step
+// of the last accepted code, so a code cannot be replayed.
+type TwoFactor struct {
+	ID           uint   `gorm:"primarykey"`
+	UserID       uint   `gorm:"uniqueIndex;not null"`
+	Secret       string `gorm:"not null"`
+	EnabledAt    *time.Time
+	LastUsedStep int64 `gorm:"not null;default:0"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// RecoveryCode is a single-use backup code for when the authenticator is
+// lost. Only its SHA-256 hash is stored.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"index;not null"`
+	CodeHash  string `gorm:"size:64;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
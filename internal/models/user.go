@@ -4,11 +4,20 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type User struct {
 	gorm.Model
-	Name         string `json:"name"`
-	Email        string `json:"email" gorm:"uniqueIndex;not null"`
-	PasswordHash string `json:"password_hash"`
-	City         string `json:"city"`
-	Address      string `json:"address"`
+	Name          string `json:"name"`
+	Email         string `json:"email" gorm:"uniqueIndex;not null"`
+	PasswordHash  string `json:"password_hash"`
+	City          string `json:"city"`
+	Address       string `json:"address"`
+	Reputation    int    `json:"reputation" gorm:"not null;default:0"`
+	Role          string `json:"role" gorm:"not null;default:user;enum:user,moderator,admin"`
+	EmailVerified bool   `json:"email_verified" gorm:"not null;default:false"`
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider, identified by the provider's stable subject.
+type UserIdentity struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"index;not null"`
+	Provider  string `gorm:"uniqueIndex:idx_user_identities_provider_subject;size:64;not null"`
+	Subject   string `gorm:"uniqueIndex:idx_user_identities_provider_subject;size:255;not null"`
+	Email     string
+	CreatedAt time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import "gorm.io/gorm"
+
+// WishlistItem is a book another member has listed that the user would like to get.
+type WishlistItem struct {
+	gorm.Model
+	UserID uint `json:"user_id" gorm:"index"`
+	BookID uint `json:"book_id" gorm:"index"`
+
+	Book *Book `json:"book,omitempty" gorm:"foreignKey:BookID"`
+}
@@ -0,0 +1,241 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockServer is a minimal OpenID provider: discovery, JWKS and a token
+// endpoint that checks the PKCE verifier of the single code it knows.
+type mockServer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	issuer    string
+	kid       string
+	code      string
+	challenge string
+	nonce     string
+	audience  string
+	email     string
+}
+
+func newMockServer(t *testing.T) *mockServer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockServer{key: key, kid: "k1", code: "the-code", audience: "client", email: "reader@example.com"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := m.URL
+		if m.issuer != "" {
+			issuer = m.issuer
+		}
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                issuer,
+			AuthorizationEndpoint: m.URL + "/authorize",
+			TokenEndpoint:         m.URL + "/token",
+			JWKSURI:               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": m.kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != m.code || CodeChallenge(r.PostForm.Get("code_verifier")) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": m.idToken(t), "token_type": "Bearer"})
+	})
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockServer) idToken(t *testing.T) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.URL,
+		"sub":            "user-42",
+		"aud":            m.audience,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          m.nonce,
+		"email":          m.email,
+		"email_verified": "true",
+	})
+	token.Header["kid"] = m.kid
+
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (m *mockServer) provider() *Provider {
+	return NewProvider(Config{
+		Name:        "mock",
+		Issuer:      m.URL,
+		ClientID:    "client",
+		RedirectURL: "http://app.local/auth/oidc/mock/callback",
+	}, m.Client())
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	m := newMockServer(t)
+	p := m.provider()
+
+	verifier, err := NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	if !strings.HasPrefix(authURL, m.URL+"/authorize?") || u.Query().Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+	if strings.Contains(authURL, verifier) {
+		t.Fatal("auth url leaks the code verifier")
+	}
+
+	m.challenge = u.Query().Get("code_challenge")
+	m.nonce = "nonce"
+
+	claims, err := p.Exchange(context.Background(), m.code, verifier, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user-42" || claims.Email != m.email || !bool(claims.EmailVerified) {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(m *mockServer)
+		verifier string
+		want     error
+	}{
+		{
+			name:     "wrong verifier",
+			verifier: "other",
+			want:     ErrExchangeFailed,
+		},
+		{
+			name:  "nonce mismatch",
+			setup: func(m *mockServer) { m.nonce = "replayed" },
+			want:  ErrInvalidIDToken,
+		},
+		{
+			name:  "token for another client",
+			setup: func(m *mockServer) { m.audience = "someone-else" },
+			want:  ErrInvalidIDToken,
+		},
+		{
+			name: "unknown signing key",
+			setup: func(m *mockServer) {
+				other, _ := rsa.GenerateKey(rand.Reader, 2048)
+				m.key = other
+				m.kid = "k2"
+			},
+			want: ErrInvalidIDToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockServer(t)
+			p := m.provider()
+
+			verifier, _ := NewVerifier()
+			m.challenge = CodeChallenge(verifier)
+			m.nonce = "nonce"
+
+			// fetch the keys once so a later key change is a rotation
+			if tt.setup != nil {
+				if _, err := p.key(context.Background(), mustMetadata(t, p), m.kid); err != nil {
+					t.Fatal(err)
+				}
+				tt.setup(m)
+			}
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+
+			_, err := p.Exchange(context.Background(), m.code, verifier, "nonce")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	m := newMockServer(t)
+	p := m.provider()
+	meta := mustMetadata(t, p)
+
+	if _, err := p.key(context.Background(), meta, "k1"); err != nil {
+		t.Fatal(err)
+	}
+
+	m.kid = "k2"
+	p.keysFetched = time.Now().Add(-2 * keyRefreshInterval)
+
+	if _, err := p.key(context.Background(), meta, "k2"); err != nil {
+		t.Fatalf("rotated key was not fetched: %v", err)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockServer(t)
+	m.issuer = "https://attacker.example"
+	p := m.provider()
+
+	if _, err := p.AuthCodeURL(context.Background(), "s", "n", "v"); !errors.Is(err, ErrDiscoveryFailed) {
+		t.Fatalf("got %v, want ErrDiscoveryFailed", err)
+	}
+}
+
+func mustMetadata(t *testing.T, p *Provider) *Metadata {
+	t.Helper()
+	meta, err := p.metadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return meta
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BookRepository interface {
@@ -71,7 +72,30 @@ func (r *bookRepository) Update(book *models.Book) error {
 		return dto.ErrBookUpdateFailed
 	}
 
-	return r.db.Save(book).Error
+	// optimistic lock: a reservation that happened after the book was
+	// read must not be overwritten by this update
+	version := book.Version
+	res := r.db.Model(&models.Book{}).
+		Omit(clause.Associations).
+		Where("id = ? AND version = ?", book.ID, version).
+		Updates(map[string]interface{}{
+			"title":       book.Title,
+			"author":      book.Author,
+			"description": book.Description,
+			"ai_summary":  book.AISummary,
+			"status":      book.Status,
+			"user_id":     book.UserID,
+			"version":     version + 1,
+		})
+	if res.Error != nil {
+		r.log.Error("error in Update function book_repository.go", "error", res.Error)
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return dto.ErrBookConflict
+	}
+	book.Version = version + 1
+	return nil
 }
 
 func (r *bookRepository) Delete(id uint) error {
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// recordingDriver answers every statement without a database. Exec reports
+// rowsAffected rows, queries return no rows.
+type recordingDriver struct {
+	mu           sync.Mutex
+	statements   []string
+	rowsAffected int64
+}
+
+func (d *recordingDriver) Connect(context.Context) (driver.Conn, error) {
+	return &recordingConn{d}, nil
+}
+func (d *recordingDriver) Driver() driver.Driver { return nil }
+
+func (d *recordingDriver) record(query string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, query)
+}
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+func (c *recordingConn) Close() error              { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *recordingConn) Commit() error             { return nil }
+func (c *recordingConn) Rollback() error           { return nil }
+
+func (c *recordingConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.d.record(query)
+	return driver.RowsAffected(c.d.rowsAffected), nil
+}
+
+func (c *recordingConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.d.record(query)
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return nil }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+func openRecordingDB(t *testing.T, rowsAffected int64) (*gorm.DB, *recordingDriver) {
+	t.Helper()
+
+	d := &recordingDriver{rowsAffected: rowsAffected}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(d)}), &gorm.Config{
+		Logger:                 gormlogger.Default.LogMode(gormlogger.Silent),
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, d
+}
+
+func staleBook() *models.Book {
+	book := &models.Book{
+		Title:   "Dune",
+		Status:  "available",
+		UserID:  1,
+		Version: 3,
+		User:    &models.User{Name: "owner"},
+		Genres:  []models.Genre{{Name: "sci-fi"}},
+	}
+	book.ID = 7
+	return book
+}
+
+func TestBookUpdateStaleVersion(t *testing.T) {
+	db, d := openRecordingDB(t, 0)
+	repo := NewBookRepository(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	book := staleBook()
+	if err := repo.Update(book); !errors.Is(err, dto.ErrBookConflict) {
+		t.Fatalf("got %v, want ErrBookConflict", err)
+	}
+	if book.Version != 3 {
+		t.Fatalf("version changed to %d on conflict", book.Version)
+	}
+
+	if len(d.statements) != 1 {
+		t.Fatalf("expected a single UPDATE, got %q", d.statements)
+	}
+	stmt := d.statements[0]
+	if !strings.HasPrefix(stmt, "UPDATE") || !strings.Contains(stmt, "version = $") {
+		t.Fatalf("update is not guarded by the version: %s", stmt)
+	}
+}
+
+func TestBookUpdateBumpsVersion(t *testing.T) {
+	db, d := openRecordingDB(t, 1)
+	repo := NewBookRepository(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	book := staleBook()
+	if err := repo.Update(book); err != nil {
+		t.Fatal(err)
+	}
+	if book.Version != 4 {
+		t.Fatalf("version = %d, want 4", book.Version)
+	}
+	for _, stmt := range d.statements {
+		if !strings.HasPrefix(stmt, "UPDATE \"books\"") {
+			t.Fatalf("unexpected statement %s", stmt)
+		}
+	}
+}
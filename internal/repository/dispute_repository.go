@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DisputeResolution is everything a moderator decided about a dispute.
+type DisputeResolution struct {
+	ModeratorID              uint
+	Resolution               string
+	Note                     string
+	InitiatorReputationDelta int
+	RecipientReputationDelta int
+	RequestID                string
+}
+
+type DisputeRepository interface {
+	Create(dispute *models.Dispute) error
+	GetByID(id uint) (*models.Dispute, error)
+	HasActive(exchangeID uint) (bool, error)
+	AddEvidence(dispute *models.Dispute, evidence *models.DisputeEvidence) error
+	ListQueue(statuses []string, limit, offset int) ([]models.Dispute, int64, error)
+	Claim(dispute *models.Dispute, moderatorID uint) error
+	Resolve(dispute *models.Dispute, exchange *models.Exchange, res DisputeResolution) error
+}
+
+type disputeRepository struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+func NewDisputeRepository(db *gorm.DB, log *slog.Logger) DisputeRepository {
+	return &disputeRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *disputeRepository) Create(dispute *models.Dispute) error {
+	if dispute == nil {
+		r.log.Error("error in Create function dispute_repository.go")
+		return dto.ErrDisputeCreateFailed
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(dispute).Error; err != nil {
+			r.log.Error("error in Create function dispute_repository.go", "error", err)
+			return dto.ErrDisputeCreateFailed
+		}
+		if err := recordDisputeEvent(tx, dispute, dispute.OpenedByID, "opened", dispute.Reason); err != nil {
+			r.log.Error("error in Create function dispute_repository.go", "error", err)
+			return dto.ErrDisputeCreateFailed
+		}
+		return nil
+	})
+}
+
+func (r *disputeRepository) GetByID(id uint) (*models.Dispute, error) {
+	var dispute models.Dispute
+	if err := r.db.
+		Preload("Evidence", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		First(&dispute, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, dto.ErrDisputeNotFound
+		}
+		r.log.Error("error in GetByID function dispute_repository.go", "error", err)
+		return nil, dto.ErrDisputeGetFailed
+	}
+	return &dispute, nil
+}
+
+func (r *disputeRepository) HasActive(exchangeID uint) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.Dispute{}).
+		Where("exchange_id = ? AND status IN ?", exchangeID, []string{"open", "in_review"}).
+		Count(&count).Error; err != nil {
+		r.log.Error("error in HasActive function dispute_repository.go", "error", err)
+		return false, dto.ErrDisputeGetFailed
+	}
+	return count > 0, nil
+}
+
+func (r *disputeRepository) AddEvidence(dispute *models.Dispute, evidence *models.DisputeEvidence) error {
+	if dispute == nil || evidence == nil {
+		r.log.Error("error in AddEvidence function dispute_repository.go")
+		return dto.ErrDisputeUpdateFailed
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		evidence.DisputeID = dispute.ID
+		if err := tx.Create(evidence).Error; err != nil {
+			r.log.Error("error in AddEvidence function dispute_repository.go", "error", err)
+			return dto.ErrDisputeUpdateFailed
+		}
+		if err := recordDisputeEvent(tx, dispute, evidence.UploaderID, "evidence_added", evidence.FileName); err != nil {
+			r.log.Error("error in AddEvidence function dispute_repository.go", "error", err)
+			return dto.ErrDisputeUpdateFailed
+		}
+		return nil
+	})
+}
+
+// ListQueue returns disputes in the given statuses, oldest first, so
+// moderators work through them in the order they were opened.
+func (r *disputeRepository) ListQueue(statuses []string, limit, offset int) ([]models.Dispute, int64, error) {
+	q := r.db.Model(&models.Dispute{}).Where("status IN ?", statuses)
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		r.log.Error("error in ListQueue function dispute_repository.go", "error", err)
+		return nil, 0, dto.ErrDisputeGetFailed
+	}
+
+	var disputes []models.Dispute
+	if err := q.Order("created_at ASC, id ASC").Limit(limit).Offset(offset).Find(&disputes).Error; err != nil {
+		r.log.Error("error in ListQueue function dispute_repository.go", "error", err)
+		return nil, 0, dto.ErrDisputeGetFailed
+	}
+	return disputes, total, nil
+}
+
+// Claim assigns an open dispute to the moderator. A dispute that another
+// moderator claimed in the meantime fails with dto.ErrDisputeAlreadyClaimed.
+func (r *disputeRepository) Claim(dispute *models.Dispute, moderatorID uint) error {
+	if dispute == nil {
+		r.log.Error("error in Claim function dispute_repository.go")
+		return dto.ErrDisputeUpdateFailed
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Dispute{}).
+			Where("id = ? AND status = ?", dispute.ID, "open").
+			Updates(map[string]interface{}{
+				"status":       "in_review",
+				"moderator_id": moderatorID,
+			})
+		if res.Error != nil {
+			r.log.Error("error in Claim function dispute_repository.go", "error", res.Error)
+			return dto.ErrDisputeUpdateFailed
+		}
+		if res.RowsAffected == 0 {
+			return dto.ErrDisputeAlreadyClaimed
+		}
+
+		dispute.Status = "in_review"
+		dispute.ModeratorID = &moderatorID
+		if err := recordDisputeEvent(tx, dispute, moderatorID, "claimed", ""); err != nil {
+			r.log.Error("error in Claim function dispute_repository.go", "error", err)
+			return dto.ErrDisputeUpdateFailed
+		}
+		return nil
+	})
+}
+
+// Resolve applies the moderator decision in one transaction: the reversal of
+// the ownership transfer or the reputation adjustments, the dispute status and
+// the audit entries of both the dispute and the exchange.
+//
+// The status change is conditional on the dispute still being in review by
+// the moderator and runs first, so of two concurrent resolves only one applies
+// its decision.
+func (r *disputeRepository) Resolve(dispute *models.Dispute, exchange *models.Exchange, res DisputeResolution) error {
+	if dispute == nil || exchange == nil {
+		r.log.Error("error in Resolve function dispute_repository.go")
+		return dto.ErrDisputeUpdateFailed
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		updated := tx.Model(&models.Dispute{}).
+			Where("id = ? AND status = ? AND moderator_id = ?", dispute.ID, "in_review", res.ModeratorID).
+			Updates(map[string]interface{}{
+				"status":          "resolved",
+				"resolution":      res.Resolution,
+				"resolution_note": res.Note,
+				"resolved_at":     now,
+			})
+		if updated.Error != nil {
+			r.log.Error("error in Resolve function dispute_repository.go", "error", updated.Error)
+			return dto.ErrDisputeUpdateFailed
+		}
+		if updated.RowsAffected == 0 {
+			return dto.ErrDisputeNotClaimed
+		}
+
+		switch res.Resolution {
+		case "reverse_transfer":
+			if err := reverseExchangeTransfer(tx, exchange, dto.ExchangeEventMeta{
+				ActorID:   res.ModeratorID,
+				RequestID: res.RequestID,
+				Reason:    "dispute resolution",
+			}); err != nil {
+				r.log.Error("error in Resolve function dispute_repository.go", "error", err)
+				return err
+			}
+		case "adjust_reputation":
+			if err := adjustReputation(tx, exchange.InitiatorID, res.InitiatorReputationDelta); err != nil {
+				r.log.Error("error in Resolve function dispute_repository.go", "error", err)
+				return err
+			}
+			if err := adjustReputation(tx, exchange.RecipientID, res.RecipientReputationDelta); err != nil {
+				r.log.Error("error in Resolve function dispute_repository.go", "error", err)
+				return err
+			}
+		}
+
+		dispute.Status = "resolved"
+		dispute.Resolution = res.Resolution
+		dispute.ResolutionNote = res.Note
+		dispute.ResolvedAt = &now
+		if err := recordDisputeEvent(tx, dispute, res.ModeratorID, "resolved", res.Resolution); err != nil {
+			r.log.Error("error in Resolve function dispute_repository.go", "error", err)
+			return dto.ErrDisputeUpdateFailed
+		}
+		return nil
+	})
+}
+
+// reverseExchangeTransfer gives every book of a completed exchange back to its
+// previous owner. It fails when a book was transferred or reserved again since.
+func reverseExchangeTransfer(tx *gorm.DB, exchange *models.Exchange, meta dto.ExchangeEventMeta) error {
+	initiatorBookIDs, recipientBookIDs, err := exchangeBookIDs(tx, exchange)
+	if err != nil {
+		return err
+	}
+
+	if err := returnBooks(tx, initiatorBookIDs, exchange.RecipientID, exchange.InitiatorID); err != nil {
+		return err
+	}
+	if err := returnBooks(tx, recipientBookIDs, exchange.InitiatorID, exchange.RecipientID); err != nil {
+		return err
+	}
+
+	from := exchange.Status
+	exchange.Status = "reversed"
+	if err := tx.Omit(clause.Associations).Save(exchange).Error; err != nil {
+		return err
+	}
+
+	return recordExchangeEvent(tx, exchange, from, meta)
+}
+
+func returnBooks(tx *gorm.DB, bookIDs []uint, holderID, ownerID uint) error {
+	if len(bookIDs) == 0 {
+		return nil
+	}
+
+	res := tx.Model(&models.Book{}).
+		Where("id IN ? AND user_id = ? AND status = ?", bookIDs, holderID, "available").
+		Updates(map[string]interface{}{
+			"user_id": ownerID,
+			"version": gorm.Expr("version + 1"),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected != int64(len(bookIDs)) {
+		return dto.ErrDisputeBooksMoved
+	}
+	return nil
+}
+
+func adjustReputation(tx *gorm.DB, userID uint, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	return tx.Model(&models.User{}).Where("id = ?", userID).
+		Update("reputation", gorm.Expr("reputation + ?", delta)).Error
+}
+
+func recordDisputeEvent(tx *gorm.DB, dispute *models.Dispute, actorID uint, action, note string) error {
+	event := models.DisputeEvent{
+		DisputeID: dispute.ID,
+		ActorID:   actorID,
+		Action:    action,
+		Note:      note,
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		return err
+	}
+	dispute.Events = append(dispute.Events, event)
+	return nil
+}
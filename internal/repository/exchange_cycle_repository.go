@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WishEdge says that the wisher wants an available book owned by the owner.
+type WishEdge struct {
+	WisherID uint
+	OwnerID  uint
+	BookID   uint
+}
+
+type ExchangeCycleRepository interface {
+	ListWishEdges() ([]WishEdge, error)
+	CreateCycles(cycles []models.ExchangeCycle) error
+	GetByID(id uint) (*models.ExchangeCycle, error)
+	ListByUser(userID uint, statuses []string) ([]models.ExchangeCycle, error)
+	ListCancelledSince(since time.Time) ([]models.ExchangeCycle, error)
+	AcceptLeg(cycle *models.ExchangeCycle, giverID uint) error
+	CompleteLeg(cycle *models.ExchangeCycle, legID uint) error
+	Cancel(cycle *models.ExchangeCycle) error
+}
+
+type exchangeCycleRepository struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+func NewExchangeCycleRepository(db *gorm.DB, log *slog.Logger) ExchangeCycleRepository {
+	return &exchangeCycleRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// ListWishEdges returns wishes for available books that are not already part
+// of an open exchange cycle.
+func (r *exchangeCycleRepository) ListWishEdges() ([]WishEdge, error) {
+	openCycleBooks := r.db.Model(&models.ExchangeCycleLeg{}).
+		Select("exchange_cycle_legs.book_id").
+		Joins("JOIN exchange_cycles c ON c.id = exchange_cycle_legs.cycle_id").
+		Where("c.status IN ? AND c.deleted_at IS NULL", []string{"proposed", "accepted"})
+
+	var edges []WishEdge
+	if err := r.db.Model(&models.WishlistItem{}).
+		Select("wishlist_items.user_id AS wisher_id, b.user_id AS owner_id, b.id AS book_id").
+		Joins("JOIN books b ON b.id = wishlist_items.book_id AND b.deleted_at IS NULL").
+		Where("b.status = ? AND b.user_id <> wishlist_items.user_id", "available").
+		Where("b.id NOT IN (?)", openCycleBooks).
+		Order("wishlist_items.user_id, b.user_id, b.id").
+		Scan(&edges).Error; err != nil {
+		r.log.Error("error in ListWishEdges function exchange_cycle_repository.go", "error", err)
+		return nil, dto.ErrCycleGetFailed
+	}
+
+	return edges, nil
+}
+
+func (r *exchangeCycleRepository) CreateCycles(cycles []models.ExchangeCycle) error {
+	if len(cycles) == 0 {
+		return nil
+	}
+
+	if err := r.db.Create(&cycles).Error; err != nil {
+		r.log.Error("error in CreateCycles function exchange_cycle_repository.go", "error", err)
+		return dto.ErrCycleCreateFailed
+	}
+	return nil
+}
+
+func (r *exchangeCycleRepository) GetByID(id uint) (*models.ExchangeCycle, error) {
+	var cycle models.ExchangeCycle
+	if err := r.db.Preload("Legs", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).First(&cycle, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, dto.ErrCycleNotFound
+		}
+		r.log.Error("error in GetByID function exchange_cycle_repository.go", "error", err)
+		return nil, dto.ErrCycleGetFailed
+	}
+	return &cycle, nil
+}
+
+func (r *exchangeCycleRepository) ListByUser(userID uint, statuses []string) ([]models.ExchangeCycle, error) {
+	participant := r.db.Model(&models.ExchangeCycleLeg{}).
+		Select("cycle_id").
+		Where("giver_id = ? OR receiver_id = ?", userID, userID)
+
+	q := r.db.Model(&models.ExchangeCycle{}).Where("id IN (?)", participant)
+	if len(statuses) > 0 {
+		q = q.Where("status IN ?", statuses)
+	}
+
+	var cycles []models.ExchangeCycle
+	if err := q.Preload("Legs", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).Order("created_at DESC").Find(&cycles).Error; err != nil {
+		r.log.Error("error in ListByUser function exchange_cycle_repository.go", "error", err)
+		return nil, dto.ErrCycleGetFailed
+	}
+	return cycles, nil
+}
+
+// ListCancelledSince returns the cycles cancelled after since, with their legs.
+func (r *exchangeCycleRepository) ListCancelledSince(since time.Time) ([]models.ExchangeCycle, error) {
+	var cycles []models.ExchangeCycle
+	if err := r.db.Preload("Legs").
+		Where("status = ? AND updated_at >= ?", "cancelled", since).
+		Find(&cycles).Error; err != nil {
+		r.log.Error("error in ListCancelledSince function exchange_cycle_repository.go", "error", err)
+		return nil, dto.ErrCycleGetFailed
+	}
+	return cycles, nil
+}
+
+// AcceptLeg records the giver's acceptance. Once every participant has
+// accepted, all books of the cycle are reserved in the same transaction.
+//
+// The cycle row is locked and its legs are read again under the lock, so the
+// last two acceptances cannot both see the other one pending. When a book was
+// taken in the meantime the cycle can never complete and is cancelled.
+func (r *exchangeCycleRepository) AcceptLeg(cycle *models.ExchangeCycle, giverID uint) error {
+	if cycle == nil {
+		r.log.Error("error in AcceptLeg function exchange_cycle_repository.go")
+		return dto.ErrCycleUpdateFailed
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var locked models.ExchangeCycle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, cycle.ID).Error; err != nil {
+			r.log.Error("error in AcceptLeg function exchange_cycle_repository.go", "error", err)
+			return err
+		}
+		if locked.Status != "proposed" {
+			return dto.ErrCycleNotProposed
+		}
+
+		var legs []models.ExchangeCycleLeg
+		if err := tx.Where("cycle_id = ?", cycle.ID).Order("position ASC").Find(&legs).Error; err != nil {
+			r.log.Error("error in AcceptLeg function exchange_cycle_repository.go", "error", err)
+			return err
+		}
+
+		now := time.Now()
+		pending := 0
+		bookIDs := make([]uint, 0, len(legs))
+		for i := range legs {
+			leg := &legs[i]
+			if leg.GiverID == giverID {
+				if leg.AcceptedAt != nil {
+					return dto.ErrCycleAlreadyDone
+				}
+				leg.AcceptedAt = &now
+				if err := tx.Model(leg).Update("accepted_at", now).Error; err != nil {
+					r.log.Error("error in AcceptLeg function exchange_cycle_repository.go", "error", err)
+					return err
+				}
+			}
+			if leg.AcceptedAt == nil {
+				pending++
+			}
+			bookIDs = append(bookIDs, leg.BookID)
+		}
+
+		cycle.Legs = legs
+		if pending > 0 {
+			return nil
+		}
+
+		if err := reserveBooks(tx, bookIDs, dto.ErrCycleBookTaken); err != nil {
+			r.log.Error("error in AcceptLeg function exchange_cycle_repository.go", "error", err)
+			return err
+		}
+
+		cycle.Status = "accepted"
+		if err := tx.Model(&models.ExchangeCycle{}).Where("id = ?", cycle.ID).Update("status", "accepted").Error; err != nil {
+			r.log.Error("error in AcceptLeg function exchange_cycle_repository.go", "error", err)
+			return err
+		}
+		return nil
+	})
+	if errors.Is(err, dto.ErrCycleBookTaken) {
+		if cancelErr := r.db.Model(&models.ExchangeCycle{}).
+			Where("id = ? AND status = ?", cycle.ID, "proposed").
+			Update("status", "cancelled").Error; cancelErr != nil {
+			r.log.Error("error in AcceptLeg function exchange_cycle_repository.go", "error", cancelErr)
+		} else {
+			cycle.Status = "cancelled"
+		}
+	}
+	return err
+}
+
+// CompleteLeg hands the book of one leg over to its receiver. The cycle is
+// completed together with its last leg.
+func (r *exchangeCycleRepository) CompleteLeg(cycle *models.ExchangeCycle, legID uint) error {
+	if cycle == nil {
+		r.log.Error("error in CompleteLeg function exchange_cycle_repository.go")
+		return dto.ErrCycleUpdateFailed
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		open := 0
+		for i := range cycle.Legs {
+			leg := &cycle.Legs[i]
+			if leg.ID == legID {
+				if err := tx.Model(&models.Book{}).Where("id = ?", leg.BookID).Updates(map[string]interface{}{
+					"status":  "available",
+					"user_id": leg.ReceiverID,
+					"version": gorm.Expr("version + 1"),
+				}).Error; err != nil {
+					r.log.Error("error in CompleteLeg function exchange_cycle_repository.go", "error", err)
+					return err
+				}
+
+				leg.CompletedAt = &now
+				if err := tx.Model(leg).Update("completed_at", now).Error; err != nil {
+					r.log.Error("error in CompleteLeg function exchange_cycle_repository.go", "error", err)
+					return err
+				}
+			}
+			if leg.CompletedAt == nil {
+				open++
+			}
+		}
+
+		if open > 0 {
+			return nil
+		}
+
+		cycle.Status = "completed"
+		cycle.CompletedAt = &now
+		if err := tx.Omit(clause.Associations).Save(cycle).Error; err != nil {
+			r.log.Error("error in CompleteLeg function exchange_cycle_repository.go", "error", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// Cancel drops a cycle. Books are only released when the cycle had already
+// reserved them, and only those of legs that were not handed over yet.
+//
+// The cycle row is locked and must still be in the status the caller checked,
+// so a decline racing the last acceptance, or a cancel racing the last
+// handover, fails instead of releasing books of the wrong state.
+func (r *exchangeCycleRepository) Cancel(cycle *models.ExchangeCycle) error {
+	if cycle == nil {
+		r.log.Error("error in Cancel function exchange_cycle_repository.go")
+		return dto.ErrCycleUpdateFailed
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var locked models.ExchangeCycle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, cycle.ID).Error; err != nil {
+			r.log.Error("error in Cancel function exchange_cycle_repository.go", "error", err)
+			return err
+		}
+		if locked.Status != cycle.Status {
+			if cycle.Status == "accepted" {
+				return dto.ErrCycleNotAccepted
+			}
+			return dto.ErrCycleNotProposed
+		}
+
+		if locked.Status == "accepted" {
+			var legs []models.ExchangeCycleLeg
+			if err := tx.Where("cycle_id = ?", cycle.ID).Order("position ASC").Find(&legs).Error; err != nil {
+				r.log.Error("error in Cancel function exchange_cycle_repository.go", "error", err)
+				return err
+			}
+			cycle.Legs = legs
+
+			bookIDs := make([]uint, 0, len(legs))
+			for _, leg := range legs {
+				if leg.CompletedAt == nil {
+					bookIDs = append(bookIDs, leg.BookID)
+				}
+			}
+			if len(bookIDs) > 0 {
+				if err := tx.Model(&models.Book{}).Where("id IN ?", bookIDs).Updates(map[string]interface{}{
+					"status":  "available",
+					"version": gorm.Expr("version + 1"),
+				}).Error; err != nil {
+					r.log.Error("error in Cancel function exchange_cycle_repository.go", "error", err)
+					return err
+				}
+			}
+		}
+
+		cycle.Status = "cancelled"
+		if err := tx.Model(&locked).Update("status", "cancelled").Error; err != nil {
+			r.log.Error("error in Cancel function exchange_cycle_repository.go", "error", err)
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+)
+
+type ExchangeMessageRepository interface {
+	Create(message *models.ExchangeMessage) error
+	ListByExchange(exchangeID uint, limit, offset int) ([]models.ExchangeMessage, int64, error)
+	MarkRead(exchangeID uint, readerID uint, ids []uint) error
+}
+
+type exchangeMessageRepository struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+func NewExchangeMessageRepository(db *gorm.DB, log *slog.Logger) ExchangeMessageRepository {
+	return &exchangeMessageRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *exchangeMessageRepository) Create(message *models.ExchangeMessage) error {
+	if message == nil {
+		r.log.Error("error in Create function exchange_message_repository.go")
+		return dto.ErrMessageCreateFailed
+	}
+
+	if err := r.db.Create(message).Error; err != nil {
+		r.log.Error("error in Create function exchange_message_repository.go", "error", err)
+		return dto.ErrMessageCreateFailed
+	}
+	return nil
+}
+
+func (r *exchangeMessageRepository) ListByExchange(exchangeID uint, limit, offset int) ([]models.ExchangeMessage, int64, error) {
+	db := r.db.Model(&models.ExchangeMessage{}).Where("exchange_id = ?", exchangeID)
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		r.log.Error("error in ListByExchange function exchange_message_repository.go", "error", err)
+		return nil, 0, dto.ErrMessageGetFailed
+	}
+
+	var messages []models.ExchangeMessage
+	if err := db.Session(&gorm.Session{}).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&messages).Error; err != nil {
+		r.log.Error("error in ListByExchange function exchange_message_repository.go", "error", err)
+		return nil, 0, dto.ErrMessageGetFailed
+	}
+
+	return messages, total, nil
+}
+
+// MarkRead sets the read receipt on the given messages of the exchange that
+// the reader received and has not read yet.
+func (r *exchangeMessageRepository) MarkRead(exchangeID uint, readerID uint, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := r.db.Model(&models.ExchangeMessage{}).
+		Where("exchange_id = ? AND sender_id <> ? AND read_at IS NULL AND id IN ?", exchangeID, readerID, ids).
+		Update("read_at", time.Now()).Error; err != nil {
+		r.log.Error("error in MarkRead function exchange_message_repository.go", "error", err)
+		return dto.ErrMessageGetFailed
+	}
+	return nil
+}
@@ -1,22 +1,36 @@
 package repository
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ExchangeRepository interface {
-	CreateExchange(req *models.Exchange) error
-	CompleteExchange(req *models.Exchange) error
-	CancelExchange(req *models.Exchange) error
+	CreateExchange(req *models.Exchange, meta dto.ExchangeEventMeta) error
+	CompleteExchange(req *models.Exchange, meta dto.ExchangeEventMeta) error
+	ConfirmHandover(req *models.Exchange, side string, at time.Time, meta dto.ExchangeEventMeta) error
+	ListHalfConfirmed(before time.Time, limit int) ([]models.Exchange, error)
+	CancelExchange(req *models.Exchange, meta dto.ExchangeEventMeta) error
+	DeclineExchange(req *models.Exchange, meta dto.ExchangeEventMeta) error
+	CounterExchange(req *models.Exchange, offer *models.ExchangeOffer) error
+	AcceptExchange(req *models.Exchange, meta dto.ExchangeEventMeta) error
+	ListEvents(exchangeID uint) ([]models.ExchangeEvent, error)
 	Update(req *models.Exchange) error
 	GetByID(id uint) (*models.Exchange, error)
-	GetAll() ([]models.Exchange, error)
+	GetOffers(exchangeID uint) ([]models.ExchangeOffer, error)
+	List(query dto.ExchangeListQuery) ([]models.Exchange, string, error)
+	ListStale(status string, before time.Time, limit int) ([]models.Exchange, error)
+	FlagForFollowUp(ids []uint, at time.Time) error
 }
 
 type exchangeRepository struct {
@@ -31,63 +45,319 @@ func NewExchangeRepository(db *gorm.DB, log *slog.Logger) ExchangeRepository {
 	}
 }
 
-func (r *exchangeRepository) CancelExchange(req *models.Exchange) error {
+// CancelExchange cancels a pending exchange and releases its books. The status
+// is checked again in the UPDATE, so an exchange accepted after req was read
+// fails with dto.ErrExchangeNotPending instead of being cancelled.
+func (r *exchangeRepository) CancelExchange(req *models.Exchange, meta dto.ExchangeEventMeta) error {
 	if req == nil {
 		r.log.Error("error in CancelExchange function exchange_repository.go")
 		return dto.ErrExchangeCancelFailed
 	}
 
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Model(&models.Book{}).Where("id = ?", req.InitiatorBookID).Update("status", "available").Error; err != nil {
-			return err
+		res := tx.Model(&models.Exchange{}).
+			Where("id = ? AND status = ?", req.ID, "pending").
+			Updates(map[string]interface{}{
+				"status":        "cancelled",
+				"cancel_reason": meta.Reason,
+				"completed_at":  nil,
+			})
+		if res.Error != nil {
+			r.log.Error("error in CancelExchange function exchange_repository.go", "error", res.Error)
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return dto.ErrExchangeNotPending
 		}
-		if err := tx.Model(&models.Book{}).Where("id = ?", req.RecipientBookID).Update("status", "available").Error; err != nil {
+
+		if err := releaseExchangeBooks(tx, req); err != nil {
 			return err
 		}
 
+		from := req.Status
 		req.Status = "cancelled"
+		req.CancelReason = meta.Reason
 		req.CompletedAt = nil
-		if err := tx.Save(req).Error; err != nil {
+		if err := recordExchangeEvent(tx, req, from, meta); err != nil {
 			r.log.Error("error in CancelExchange function exchange_repository.go", "error", err)
 			return err
 		}
 		return nil
 	})
 }
-func (r *exchangeRepository) CompleteExchange(req *models.Exchange) error {
+
+// DeclineExchange declines a pending exchange and releases its books, with
+// the same status check in the UPDATE as CancelExchange.
+func (r *exchangeRepository) DeclineExchange(req *models.Exchange, meta dto.ExchangeEventMeta) error {
 	if req == nil {
-		r.log.Error("error in CompleteExchange function exchange_repository.go")
-		return dto.ErrExchangeCompleteFailed
+		r.log.Error("error in DeclineExchange function exchange_repository.go")
+		return dto.ErrExchangeDeclineFailed
 	}
 
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		if req.CompletedAt == nil {
-			completedAt := time.Now()
-			req.CompletedAt = &completedAt
+		res := tx.Model(&models.Exchange{}).
+			Where("id = ? AND status = ?", req.ID, "pending").
+			Updates(map[string]interface{}{
+				"status":         "declined",
+				"decline_reason": meta.Reason,
+				"completed_at":   nil,
+			})
+		if res.Error != nil {
+			r.log.Error("error in DeclineExchange function exchange_repository.go", "error", res.Error)
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return dto.ErrExchangeNotPending
 		}
 
-		if err := tx.Model(&models.Book{}).Where("id = ?", req.InitiatorBookID).Updates(map[string]interface{}{
-			"status":  "available",
-			"user_id": req.RecipientID,
-		}).Error; err != nil {
+		if err := releaseExchangeBooks(tx, req); err != nil {
 			return err
 		}
 
-		if err := tx.Model(&models.Book{}).Where("id = ?", req.RecipientBookID).Updates(map[string]interface{}{
-			"status":  "available",
-			"user_id": req.InitiatorID,
-		}).Error; err != nil {
+		from := req.Status
+		req.Status = "declined"
+		req.DeclineReason = meta.Reason
+		req.CompletedAt = nil
+		if err := recordExchangeEvent(tx, req, from, meta); err != nil {
+			r.log.Error("error in DeclineExchange function exchange_repository.go", "error", err)
 			return err
 		}
+		return nil
+	})
+}
 
-		if err := tx.Save(req).Error; err != nil {
-			return err
+// recordExchangeEvent appends the status change of req to the audit log.
+// It must run in the transaction that saves the new status.
+func recordExchangeEvent(tx *gorm.DB, req *models.Exchange, from string, meta dto.ExchangeEventMeta) error {
+	return tx.Create(&models.ExchangeEvent{
+		ExchangeID: req.ID,
+		FromStatus: from,
+		ToStatus:   req.Status,
+		ActorID:    meta.ActorID,
+		Reason:     meta.Reason,
+		RequestID:  meta.RequestID,
+	}).Error
+}
+
+func (r *exchangeRepository) ListEvents(exchangeID uint) ([]models.ExchangeEvent, error) {
+	var events []models.ExchangeEvent
+	if err := r.db.Where("exchange_id = ?", exchangeID).Order("id ASC").Find(&events).Error; err != nil {
+		r.log.Error("error in ListEvents function exchange_repository.go", "error", err)
+		return nil, dto.ErrExchangeEventsFailed
+	}
+	return events, nil
+}
+
+// releaseExchangeBooks makes every book of the exchange available again.
+func releaseExchangeBooks(tx *gorm.DB, req *models.Exchange) error {
+	initiatorBookIDs, recipientBookIDs, err := exchangeBookIDs(tx, req)
+	if err != nil {
+		return err
+	}
+
+	bookIDs := append(initiatorBookIDs, recipientBookIDs...)
+	return tx.Model(&models.Book{}).Where("id IN ?", bookIDs).Updates(map[string]interface{}{
+		"status":  "available",
+		"version": gorm.Expr("version + 1"),
+	}).Error
+}
+
+// exchangeBookIDs returns the books on each side of the exchange. Exchanges
+// created before bundles existed have no items and fall back to the single
+// book columns.
+func exchangeBookIDs(tx *gorm.DB, req *models.Exchange) ([]uint, []uint, error) {
+	var items []models.ExchangeItem
+	if err := tx.Where("exchange_id = ?", req.ID).Find(&items).Error; err != nil {
+		return nil, nil, err
+	}
+
+	current := *req
+	current.Items = items
+	initiatorBookIDs, recipientBookIDs := current.BookIDs()
+	return initiatorBookIDs, recipientBookIDs, nil
+}
+
+// reserveBooks marks the books as reserved, failing with a
+// *dto.BookConflictError wrapping unavailable if any of them is not
+// available anymore.
+func reserveBooks(tx *gorm.DB, bookIDs []uint, unavailable error) error {
+	return reserveOwnedBooks(tx, 0, bookIDs, unavailable)
+}
+
+// reserveOwnedBooks is reserveBooks that also requires every book to belong
+// to ownerID; a zero ownerID skips the ownership check.
+//
+// The rows are locked in id order, so concurrent reservations of overlapping
+// books wait for each other instead of deadlocking, and the update is guarded
+// by the version read under the lock.
+func reserveOwnedBooks(tx *gorm.DB, ownerID uint, bookIDs []uint, unavailable error) error {
+	if len(bookIDs) == 0 {
+		return nil
+	}
+
+	var books []models.Book
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", bookIDs).
+		Order("id ASC").
+		Find(&books).Error; err != nil {
+		return err
+	}
+
+	found := make(map[uint]bool, len(books))
+	var conflicts []uint
+	for _, book := range books {
+		found[book.ID] = true
+		if book.Status != "available" || (ownerID != 0 && book.UserID != ownerID) {
+			conflicts = append(conflicts, book.ID)
+		}
+	}
+	for _, id := range bookIDs {
+		if !found[id] {
+			conflicts = append(conflicts, id)
+		}
+	}
+	if len(conflicts) > 0 {
+		return &dto.BookConflictError{BookIDs: conflicts, Err: unavailable}
+	}
+
+	for _, book := range books {
+		res := tx.Model(&models.Book{}).
+			Where("id = ? AND version = ?", book.ID, book.Version).
+			Updates(map[string]interface{}{
+				"status":  "reserved",
+				"version": gorm.Expr("version + 1"),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return &dto.BookConflictError{BookIDs: []uint{book.ID}, Err: unavailable}
 		}
+	}
+	return nil
+}
+func (r *exchangeRepository) CompleteExchange(req *models.Exchange, meta dto.ExchangeEventMeta) error {
+	if req == nil {
+		r.log.Error("error in CompleteExchange function exchange_repository.go")
+		return dto.ErrExchangeCompleteFailed
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return completeExchange(tx, req, meta)
+	})
+}
+
+// ConfirmHandover records that one side received its books. The ownership
+// transfer only runs once both sides have confirmed.
+//
+// The exchange row is locked and read again, so two participants confirming
+// at the same time see each other's confirmation and the later one completes
+// the exchange.
+func (r *exchangeRepository) ConfirmHandover(req *models.Exchange, side string, at time.Time, meta dto.ExchangeEventMeta) error {
+	if req == nil {
+		r.log.Error("error in ConfirmHandover function exchange_repository.go")
+		return dto.ErrExchangeCompleteFailed
+	}
 
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := confirmSide(tx, req, side, at, meta); err != nil {
+			r.log.Error("error in ConfirmHandover function exchange_repository.go", "error", err)
+			return err
+		}
 		return nil
 	})
 }
 
+// confirmSide locks the exchange row, reads it again and records that side
+// received its books, completing the exchange once both sides did. req is
+// updated with the state read under the lock.
+func confirmSide(tx *gorm.DB, req *models.Exchange, side string, at time.Time, meta dto.ExchangeEventMeta) error {
+	var current models.Exchange
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, req.ID).Error; err != nil {
+		return err
+	}
+	if current.Status != "accepted" {
+		return dto.ErrExchangeNotAccepted
+	}
+
+	column, confirmedAt := "recipient_confirmed_at", &current.RecipientConfirmedAt
+	if side == "initiator" {
+		column, confirmedAt = "initiator_confirmed_at", &current.InitiatorConfirmedAt
+	}
+	if *confirmedAt != nil {
+		return dto.ErrExchangeAlreadyConfirmed
+	}
+	*confirmedAt = &at
+
+	if current.InitiatorConfirmedAt == nil || current.RecipientConfirmedAt == nil {
+		if err := tx.Model(&current).Update(column, at).Error; err != nil {
+			return err
+		}
+	} else if err := completeExchange(tx, &current, meta); err != nil {
+		return err
+	}
+
+	req.Status = current.Status
+	req.CompletedAt = current.CompletedAt
+	req.InitiatorConfirmedAt = current.InitiatorConfirmedAt
+	req.RecipientConfirmedAt = current.RecipientConfirmedAt
+	return nil
+}
+
+// completeExchange transfers the ownership of every book of the exchange.
+func completeExchange(tx *gorm.DB, req *models.Exchange, meta dto.ExchangeEventMeta) error {
+	from := req.Status
+	if req.CompletedAt == nil {
+		completedAt := time.Now()
+		req.CompletedAt = &completedAt
+	}
+	req.Status = "completed"
+
+	initiatorBookIDs, recipientBookIDs, err := exchangeBookIDs(tx, req)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Book{}).Where("id IN ?", initiatorBookIDs).Updates(map[string]interface{}{
+		"status":  "available",
+		"user_id": req.RecipientID,
+		"version": gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Book{}).Where("id IN ?", recipientBookIDs).Updates(map[string]interface{}{
+		"status":  "available",
+		"user_id": req.InitiatorID,
+		"version": gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Omit(clause.Associations).Save(req).Error; err != nil {
+		return err
+	}
+
+	return recordExchangeEvent(tx, req, from, meta)
+}
+
+// ListHalfConfirmed returns accepted in-person exchanges where exactly one
+// side confirmed the handover before the given time. Postal exchanges wait
+// for the delivery of both shipments instead.
+func (r *exchangeRepository) ListHalfConfirmed(before time.Time, limit int) ([]models.Exchange, error) {
+	var exchanges []models.Exchange
+	if err := r.db.
+		Where("status = ? AND delivery_method = ?", "accepted", "in_person").
+		Where("(initiator_confirmed_at < ? AND recipient_confirmed_at IS NULL) OR (recipient_confirmed_at < ? AND initiator_confirmed_at IS NULL)", before, before).
+		Order("updated_at ASC").
+		Limit(limit).
+		Find(&exchanges).Error; err != nil {
+		r.log.Error("error in ListHalfConfirmed function exchange_repository.go", "error", err)
+		return nil, dto.ErrExchangeGetFailed
+	}
+	return exchanges, nil
+}
+
 func (r *exchangeRepository) GetByID(id uint) (*models.Exchange, error) {
 	if id == 0 {
 		r.log.Error("error in GetByID function exchange_repository.go")
@@ -95,10 +365,10 @@ func (r *exchangeRepository) GetByID(id uint) (*models.Exchange, error) {
 	}
 
 	var exchange models.Exchange
-	if err := r.db.Where("id = ?", id).First(&exchange).Error; err != nil {
+	if err := r.db.Preload("Items").Where("id = ?", id).First(&exchange).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			r.log.Error("error in GetByID function exchange_repository.go", "error", err)
-			return nil, dto.ErrExchangeGetFailed
+			return nil, dto.ErrExchangeNotFound
 		}
 
 		r.log.Error("error in GetByID function exchange_repository.go", "error", err)
@@ -108,7 +378,7 @@ func (r *exchangeRepository) GetByID(id uint) (*models.Exchange, error) {
 	return &exchange, nil
 }
 
-func (r *exchangeRepository) CreateExchange(req *models.Exchange) error {
+func (r *exchangeRepository) CreateExchange(req *models.Exchange, meta dto.ExchangeEventMeta) error {
 	if req == nil {
 		r.log.Error("error in Create function exchange_repository.go")
 		return dto.ErrExchangeCreateFailed
@@ -119,11 +389,28 @@ func (r *exchangeRepository) CreateExchange(req *models.Exchange) error {
 			r.log.Error("error in CreateExchange function exchange_repository.go", "error", err)
 			return err
 		}
-		if err := tx.Model(&models.Book{}).Where("id = ?", req.InitiatorBookID).Update("status", "reserved").Error; err != nil {
+		offer := &models.ExchangeOffer{
+			ExchangeID:      req.ID,
+			ProposerID:      req.InitiatorID,
+			InitiatorBookID: req.InitiatorBookID,
+			RecipientBookID: req.RecipientBookID,
+			Status:          "proposed",
+		}
+		if err := tx.Create(offer).Error; err != nil {
+			r.log.Error("error in CreateExchange function exchange_repository.go", "error", err)
+			return err
+		}
+		if err := recordExchangeEvent(tx, req, "", meta); err != nil {
 			r.log.Error("error in CreateExchange function exchange_repository.go", "error", err)
 			return err
 		}
-		if err := tx.Model(&models.Book{}).Where("id = ?", req.RecipientBookID).Update("status", "reserved").Error; err != nil {
+
+		initiatorBookIDs, recipientBookIDs := req.BookIDs()
+		if err := reserveOwnedBooks(tx, req.InitiatorID, initiatorBookIDs, dto.ErrUnavailable); err != nil {
+			r.log.Error("error in CreateExchange function exchange_repository.go", "error", err)
+			return err
+		}
+		if err := reserveOwnedBooks(tx, req.RecipientID, recipientBookIDs, dto.ErrRUnavailable); err != nil {
 			r.log.Error("error in CreateExchange function exchange_repository.go", "error", err)
 			return err
 		}
@@ -131,20 +418,323 @@ func (r *exchangeRepository) CreateExchange(req *models.Exchange) error {
 	})
 }
 
+// CounterExchange replaces the current offer of a pending exchange with a new one.
+// Books that leave the offer are released and books that join it are reserved
+// in the same transaction; a book that is no longer available aborts the counter.
+// The exchange row is locked and checked again, so a counter racing a cancel,
+// decline, accept or another counter fails instead of reviving the exchange.
+func (r *exchangeRepository) CounterExchange(req *models.Exchange, offer *models.ExchangeOffer) error {
+	if req == nil || offer == nil {
+		r.log.Error("error in CounterExchange function exchange_repository.go")
+		return dto.ErrExchangeCounterFailed
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Exchange
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, req.ID).Error; err != nil {
+			r.log.Error("error in CounterExchange function exchange_repository.go", "error", err)
+			return err
+		}
+		if current.Status != "pending" {
+			return dto.ErrExchangeNotPending
+		}
+		proposerID := current.ProposerID
+		if proposerID == 0 {
+			proposerID = current.InitiatorID
+		}
+		if proposerID == offer.ProposerID || current.InitiatorBookID != req.InitiatorBookID || current.RecipientBookID != req.RecipientBookID {
+			return dto.ErrExchangeNotYourTurn
+		}
+
+		if err := swapReservedBook(tx, req.ID, req.InitiatorBookID, offer.InitiatorBookID, dto.ErrUnavailable); err != nil {
+			r.log.Error("error in CounterExchange function exchange_repository.go", "error", err)
+			return err
+		}
+		if err := swapReservedBook(tx, req.ID, req.RecipientBookID, offer.RecipientBookID, dto.ErrRUnavailable); err != nil {
+			r.log.Error("error in CounterExchange function exchange_repository.go", "error", err)
+			return err
+		}
+
+		var previous models.ExchangeOffer
+		err := tx.Where("exchange_id = ?", req.ID).Order("id DESC").First(&previous).Error
+		switch {
+		case err == nil:
+			offer.PreviousOfferID = &previous.ID
+			if err := tx.Model(&previous).Update("status", "countered").Error; err != nil {
+				r.log.Error("error in CounterExchange function exchange_repository.go", "error", err)
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+		default:
+			r.log.Error("error in CounterExchange function exchange_repository.go", "error", err)
+			return err
+		}
+
+		offer.ExchangeID = req.ID
+		offer.Status = "proposed"
+		if err := tx.Create(offer).Error; err != nil {
+			r.log.Error("error in CounterExchange function exchange_repository.go", "error", err)
+			return err
+		}
+
+		for i := range req.Items {
+			switch req.Items[i].BookID {
+			case req.InitiatorBookID:
+				req.Items[i].BookID = offer.InitiatorBookID
+			case req.RecipientBookID:
+				req.Items[i].BookID = offer.RecipientBookID
+			}
+		}
+		req.InitiatorBookID = offer.InitiatorBookID
+		req.RecipientBookID = offer.RecipientBookID
+		req.ProposerID = offer.ProposerID
+		if err := tx.Model(&current).Updates(map[string]interface{}{
+			"initiator_book_id": offer.InitiatorBookID,
+			"recipient_book_id": offer.RecipientBookID,
+			"proposer_id":       offer.ProposerID,
+		}).Error; err != nil {
+			r.log.Error("error in CounterExchange function exchange_repository.go", "error", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// swapReservedBook releases oldID and reserves newID when they differ,
+// moving the matching exchange item along with the reservation.
+func swapReservedBook(tx *gorm.DB, exchangeID, oldID, newID uint, unavailable error) error {
+	if oldID == newID {
+		return nil
+	}
+
+	if err := reserveBooks(tx, []uint{newID}, unavailable); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Book{}).Where("id = ?", oldID).Updates(map[string]interface{}{
+		"status":  "available",
+		"version": gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.ExchangeItem{}).
+		Where("exchange_id = ? AND book_id = ?", exchangeID, oldID).
+		Update("book_id", newID).Error
+}
+
+// AcceptExchange accepts the current offer of a pending exchange. The status
+// and the proposer are checked again in the UPDATE, so an exchange that was
+// cancelled, declined, countered or already accepted after req was read fails
+// with dto.ErrExchangeNotPending and no shipments are created.
+func (r *exchangeRepository) AcceptExchange(req *models.Exchange, meta dto.ExchangeEventMeta) error {
+	if req == nil {
+		r.log.Error("error in AcceptExchange function exchange_repository.go")
+		return dto.ErrExchangeUpdateFailed
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Exchange{}).
+			Where("id = ? AND status = ? AND proposer_id = ?", req.ID, "pending", req.ProposerID).
+			Update("status", "accepted")
+		if res.Error != nil {
+			r.log.Error("error in AcceptExchange function exchange_repository.go", "error", res.Error)
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return dto.ErrExchangeNotPending
+		}
+
+		from := req.Status
+		req.Status = "accepted"
+		if err := recordExchangeEvent(tx, req, from, meta); err != nil {
+			r.log.Error("error in AcceptExchange function exchange_repository.go", "error", err)
+			return err
+		}
+		if req.DeliveryMethod == "postal" {
+			if err := createShipments(tx, req); err != nil {
+				r.log.Error("error in AcceptExchange function exchange_repository.go", "error", err)
+				return err
+			}
+		}
+		if err := tx.Model(&models.ExchangeOffer{}).
+			Where("exchange_id = ? AND status = ?", req.ID, "proposed").
+			Update("status", "accepted").Error; err != nil {
+			r.log.Error("error in AcceptExchange function exchange_repository.go", "error", err)
+			return err
+		}
+		return nil
+	})
+}
+
+func (r *exchangeRepository) GetOffers(exchangeID uint) ([]models.ExchangeOffer, error) {
+	var offers []models.ExchangeOffer
+	if err := r.db.Where("exchange_id = ?", exchangeID).Order("id ASC").Find(&offers).Error; err != nil {
+		r.log.Error("error in GetOffers function exchange_repository.go", "error", err)
+		return nil, dto.ErrExchangeOffersFailed
+	}
+	return offers, nil
+}
+
 func (r *exchangeRepository) Update(req *models.Exchange) error {
 	if req == nil {
 		r.log.Error("error in Update function book_repository.go")
 		return dto.ErrExchangeUpdateFailed
 	}
 
-	return r.db.Save(req).Error
+	return r.db.Omit(clause.Associations).Save(req).Error
 }
 
-func (r *exchangeRepository) GetAll() ([]models.Exchange, error) {
+// List returns one page of exchanges matching the query and the cursor of
+// the next page, which is empty on the last page. The query is expected to be
+// validated by the caller.
+func (r *exchangeRepository) List(query dto.ExchangeListQuery) ([]models.Exchange, string, error) {
+	db := r.db.Model(&models.Exchange{})
+
+	if query.ParticipantID != 0 {
+		switch query.Role {
+		case "initiator":
+			db = db.Where("initiator_id = ?", query.ParticipantID)
+		case "recipient":
+			db = db.Where("recipient_id = ?", query.ParticipantID)
+		default:
+			db = db.Where("initiator_id = ? OR recipient_id = ?", query.ParticipantID, query.ParticipantID)
+		}
+	}
+
+	if len(query.Statuses) > 0 {
+		db = db.Where("status IN ?", query.Statuses)
+	}
+
+	if query.BookID != 0 {
+		db = db.Where("initiator_book_id = ? OR recipient_book_id = ? OR id IN (?)", query.BookID, query.BookID,
+			r.db.Model(&models.ExchangeItem{}).Select("exchange_id").Where("book_id = ?", query.BookID))
+	}
+
+	if !query.CreatedFrom.IsZero() {
+		db = db.Where("created_at >= ?", query.CreatedFrom)
+	}
+	if !query.CreatedTo.IsZero() {
+		db = db.Where("created_at < ?", query.CreatedTo)
+	}
+	if !query.UpdatedFrom.IsZero() {
+		db = db.Where("updated_at >= ?", query.UpdatedFrom)
+	}
+	if !query.UpdatedTo.IsZero() {
+		db = db.Where("updated_at < ?", query.UpdatedTo)
+	}
+
+	sortField := "created_at"
+	if query.SortBy == "updated_at" {
+		sortField = "updated_at"
+	}
+	order, cmp := "DESC", "<"
+	if query.SortOrder == "asc" {
+		order, cmp = "ASC", ">"
+	}
+
+	if query.Cursor != "" {
+		at, id, err := decodeExchangeCursor(query.Cursor)
+		if err != nil {
+			return nil, "", dto.ErrExchangeInvalidCursor
+		}
+		db = db.Where(fmt.Sprintf("(%s, id) %s (?, ?)", sortField, cmp), at, id)
+	}
+
 	var exchanges []models.Exchange
-	if err := r.db.Find(&exchanges).Error; err != nil {
-		r.log.Error("error in GetAll function exchange_repository.go", "error", err)
-		return nil, errors.New("error get exchanges from db")
+	if err := db.Preload("Items").
+		Order(fmt.Sprintf("%s %s, id %s", sortField, order, order)).
+		Limit(query.Limit + 1).
+		Find(&exchanges).Error; err != nil {
+		r.log.Error("error in List function exchange_repository.go", "error", err)
+		return nil, "", dto.ErrExchangeListFailed
+	}
+
+	if len(exchanges) <= query.Limit {
+		return exchanges, "", nil
+	}
+
+	exchanges = exchanges[:query.Limit]
+	last := exchanges[len(exchanges)-1]
+	at := last.CreatedAt
+	if sortField == "updated_at" {
+		at = last.UpdatedAt
+	}
+
+	return exchanges, encodeExchangeCursor(at, last.ID), nil
+}
+
+func encodeExchangeCursor(at time.Time, id uint) string {
+	raw := strconv.FormatInt(at.UnixNano(), 10) + ":" + strconv.FormatUint(uint64(id), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeExchangeCursor(cursor string) (time.Time, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, errors.New("malformed cursor")
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	i, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	return time.Unix(0, n), uint(i), nil
+}
+
+// MigrateExchangeIndexes creates the composite indexes behind List and the
+// expiry job. gorm.Model fields cannot carry index tags, so they are created here.
+func MigrateExchangeIndexes(db *gorm.DB) error {
+	statements := []string{
+		"CREATE INDEX IF NOT EXISTS idx_exchanges_initiator_created ON exchanges (initiator_id, created_at, id)",
+		"CREATE INDEX IF NOT EXISTS idx_exchanges_recipient_created ON exchanges (recipient_id, created_at, id)",
+		"CREATE INDEX IF NOT EXISTS idx_exchanges_status_created ON exchanges (status, created_at, id)",
+		"CREATE INDEX IF NOT EXISTS idx_exchanges_status_updated ON exchanges (status, updated_at, id)",
+		"CREATE INDEX IF NOT EXISTS idx_exchanges_created ON exchanges (created_at, id)",
+		"CREATE INDEX IF NOT EXISTS idx_exchanges_updated ON exchanges (updated_at, id)",
+	}
+
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListStale returns exchanges in the given status without any activity since before.
+// Exchanges already flagged for follow-up are skipped.
+func (r *exchangeRepository) ListStale(status string, before time.Time, limit int) ([]models.Exchange, error) {
+	var exchanges []models.Exchange
+	if err := r.db.
+		Where("status = ? AND updated_at < ? AND follow_up_at IS NULL", status, before).
+		Order("updated_at ASC").
+		Limit(limit).
+		Find(&exchanges).Error; err != nil {
+		r.log.Error("error in ListStale function exchange_repository.go", "error", err)
+		return nil, dto.ErrExchangeGetFailed
 	}
 	return exchanges, nil
 }
+
+func (r *exchangeRepository) FlagForFollowUp(ids []uint, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := r.db.Model(&models.Exchange{}).Where("id IN ?", ids).Update("follow_up_at", at).Error; err != nil {
+		r.log.Error("error in FlagForFollowUp function exchange_repository.go", "error", err)
+		return dto.ErrExchangeUpdateFailed
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+)
+
+func pendingExchange() *models.Exchange {
+	exchange := &models.Exchange{
+		InitiatorID:     1,
+		RecipientID:     2,
+		ProposerID:      1,
+		InitiatorBookID: 10,
+		RecipientBookID: 20,
+		Status:          "pending",
+	}
+	exchange.ID = 5
+	return exchange
+}
+
+func TestDeclineExchangeNoLongerPending(t *testing.T) {
+	db, d := openRecordingDB(t, 0)
+	repo := NewExchangeRepository(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if err := repo.DeclineExchange(pendingExchange(), dto.ExchangeEventMeta{ActorID: 2}); !errors.Is(err, dto.ErrExchangeNotPending) {
+		t.Fatalf("got %v, want ErrExchangeNotPending", err)
+	}
+
+	// only the guarded status update ran, no books were released
+	if len(d.statements) != 1 {
+		t.Fatalf("expected a single UPDATE, got %q", d.statements)
+	}
+	if stmt := d.statements[0]; !strings.HasPrefix(stmt, "UPDATE \"exchanges\"") || !strings.Contains(stmt, "status = $") {
+		t.Fatalf("decline is not guarded by the status: %s", stmt)
+	}
+}
+
+func TestAcceptExchangeNoLongerPending(t *testing.T) {
+	db, d := openRecordingDB(t, 0)
+	repo := NewExchangeRepository(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	exchange := pendingExchange()
+	exchange.DeliveryMethod = "postal"
+	if err := repo.AcceptExchange(exchange, dto.ExchangeEventMeta{ActorID: 2}); !errors.Is(err, dto.ErrExchangeNotPending) {
+		t.Fatalf("got %v, want ErrExchangeNotPending", err)
+	}
+
+	// no event and no shipments for an exchange that was not accepted
+	if len(d.statements) != 1 {
+		t.Fatalf("expected a single UPDATE, got %q", d.statements)
+	}
+	if stmt := d.statements[0]; !strings.Contains(stmt, "status = $") || !strings.Contains(stmt, "proposer_id = $") {
+		t.Fatalf("accept is not guarded by the status and proposer: %s", stmt)
+	}
+}
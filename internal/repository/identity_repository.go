@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrIdentityNotFound = errors.New("identity not found")
+
+type IdentityRepository interface {
+	GetByProviderSubject(provider, subject string) (*models.UserIdentity, error)
+	Create(identity *models.UserIdentity) error
+	CreateWithUser(user *models.User, identity *models.UserIdentity) error
+}
+
+type identityRepository struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+func NewIdentityRepository(db *gorm.DB, log *slog.Logger) IdentityRepository {
+	return &identityRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *identityRepository) GetByProviderSubject(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrIdentityNotFound
+		}
+		r.log.Error("error in GetByProviderSubject function identity_repository.go", "error", err)
+		return nil, dto.ErrOIDCLoginFailed
+	}
+	return &identity, nil
+}
+
+func (r *identityRepository) Create(identity *models.UserIdentity) error {
+	if err := r.db.Create(identity).Error; err != nil {
+		r.log.Error("error in Create function identity_repository.go", "error", err)
+		return dto.ErrOIDCLoginFailed
+	}
+	return nil
+}
+
+// CreateWithUser creates a new user together with its first identity.
+func (r *identityRepository) CreateWithUser(user *models.User, identity *models.UserIdentity) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+	if err != nil {
+		r.log.Error("error in CreateWithUser function identity_repository.go", "error", err)
+		return dto.ErrOIDCLoginFailed
+	}
+	return nil
+}
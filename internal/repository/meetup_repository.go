@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MeetupRepository interface {
+	Create(meetup *models.Meetup) error
+	GetByID(id uint) (*models.Meetup, error)
+	ListByExchange(exchangeID uint) ([]models.Meetup, error)
+	GetConfirmed(exchangeID uint) (*models.Meetup, error)
+	Confirm(meetup *models.Meetup, slotID uint, reminders []models.MeetupReminder) error
+	ListDueReminders(now time.Time, limit int) ([]models.MeetupReminder, error)
+	ClaimReminder(id uint, sentAt time.Time) (bool, error)
+	RetryReminder(id uint, retryAt time.Time) error
+	FailReminder(id uint, failedAt time.Time) error
+}
+
+type meetupRepository struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+func NewMeetupRepository(db *gorm.DB, log *slog.Logger) MeetupRepository {
+	return &meetupRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *meetupRepository) Create(meetup *models.Meetup) error {
+	if meetup == nil {
+		r.log.Error("error in Create function meetup_repository.go")
+		return dto.ErrMeetupCreateFailed
+	}
+
+	if err := r.db.Create(meetup).Error; err != nil {
+		r.log.Error("error in Create function meetup_repository.go", "error", err)
+		return dto.ErrMeetupCreateFailed
+	}
+	return nil
+}
+
+func (r *meetupRepository) GetByID(id uint) (*models.Meetup, error) {
+	var meetup models.Meetup
+	if err := r.db.Preload("Slots", orderSlots).First(&meetup, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, dto.ErrMeetupNotFound
+		}
+		r.log.Error("error in GetByID function meetup_repository.go", "error", err)
+		return nil, dto.ErrMeetupGetFailed
+	}
+	return &meetup, nil
+}
+
+func (r *meetupRepository) ListByExchange(exchangeID uint) ([]models.Meetup, error) {
+	var meetups []models.Meetup
+	if err := r.db.Where("exchange_id = ?", exchangeID).
+		Preload("Slots", orderSlots).
+		Order("created_at DESC").
+		Find(&meetups).Error; err != nil {
+		r.log.Error("error in ListByExchange function meetup_repository.go", "error", err)
+		return nil, dto.ErrMeetupGetFailed
+	}
+	return meetups, nil
+}
+
+func (r *meetupRepository) GetConfirmed(exchangeID uint) (*models.Meetup, error) {
+	var meetup models.Meetup
+	if err := r.db.Where("exchange_id = ? AND status = ?", exchangeID, "confirmed").
+		Preload("Slots", orderSlots).
+		Order("updated_at DESC").
+		First(&meetup).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, dto.ErrMeetupNotConfirmed
+		}
+		r.log.Error("error in GetConfirmed function meetup_repository.go", "error", err)
+		return nil, dto.ErrMeetupGetFailed
+	}
+	return &meetup, nil
+}
+
+// Confirm picks a slot of the meetup, supersedes every other meetup of the
+// exchange together with its unsent reminders and queues the new reminders.
+func (r *meetupRepository) Confirm(meetup *models.Meetup, slotID uint, reminders []models.MeetupReminder) error {
+	if meetup == nil {
+		r.log.Error("error in Confirm function meetup_repository.go")
+		return dto.ErrMeetupConfirmFailed
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var supersededIDs []uint
+		if err := tx.Model(&models.Meetup{}).
+			Where("exchange_id = ? AND id <> ? AND status IN ?", meetup.ExchangeID, meetup.ID, []string{"proposed", "confirmed"}).
+			Pluck("id", &supersededIDs).Error; err != nil {
+			return err
+		}
+
+		if len(supersededIDs) > 0 {
+			if err := tx.Model(&models.Meetup{}).Where("id IN ?", supersededIDs).Update("status", "superseded").Error; err != nil {
+				return err
+			}
+			if err := tx.Where("meetup_id IN ? AND sent_at IS NULL", supersededIDs).Delete(&models.MeetupReminder{}).Error; err != nil {
+				return err
+			}
+		}
+
+		meetup.Status = "confirmed"
+		meetup.ConfirmedSlotID = &slotID
+		if err := tx.Omit(clause.Associations).Save(meetup).Error; err != nil {
+			return err
+		}
+
+		if len(reminders) > 0 {
+			if err := tx.Create(&reminders).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.log.Error("error in Confirm function meetup_repository.go", "error", err)
+		return dto.ErrMeetupConfirmFailed
+	}
+	return nil
+}
+
+// ListDueReminders returns reminders that are due and not sent or given up.
+// Reminders of meetups whose exchange is no longer accepted are skipped.
+func (r *meetupRepository) ListDueReminders(now time.Time, limit int) ([]models.MeetupReminder, error) {
+	var reminders []models.MeetupReminder
+	if err := r.db.
+		Joins("JOIN meetups ON meetups.id = meetup_reminders.meetup_id AND meetups.deleted_at IS NULL").
+		Joins("JOIN exchanges ON exchanges.id = meetups.exchange_id AND exchanges.deleted_at IS NULL").
+		Where("meetup_reminders.remind_at <= ? AND meetup_reminders.sent_at IS NULL AND meetup_reminders.failed_at IS NULL", now).
+		Where("exchanges.status = ?", "accepted").
+		Order("meetup_reminders.remind_at ASC").
+		Limit(limit).
+		Find(&reminders).Error; err != nil {
+		r.log.Error("error in ListDueReminders function meetup_repository.go", "error", err)
+		return nil, dto.ErrMeetupReminderFailed
+	}
+	return reminders, nil
+}
+
+// ClaimReminder marks the reminder as sent unless another dispatcher already
+// did. It reports whether the caller won the claim and has to send it.
+func (r *meetupRepository) ClaimReminder(id uint, sentAt time.Time) (bool, error) {
+	res := r.db.Model(&models.MeetupReminder{}).Where("id = ? AND sent_at IS NULL", id).Update("sent_at", sentAt)
+	if res.Error != nil {
+		r.log.Error("error in ClaimReminder function meetup_repository.go", "error", res.Error)
+		return false, dto.ErrMeetupReminderFailed
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// RetryReminder puts a claimed reminder back in the queue after a failed
+// send. It becomes due again at retryAt.
+func (r *meetupRepository) RetryReminder(id uint, retryAt time.Time) error {
+	if err := r.db.Model(&models.MeetupReminder{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"sent_at":   nil,
+		"remind_at": retryAt,
+		"attempts":  gorm.Expr("attempts + 1"),
+	}).Error; err != nil {
+		r.log.Error("error in RetryReminder function meetup_repository.go", "error", err)
+		return dto.ErrMeetupReminderFailed
+	}
+	return nil
+}
+
+// FailReminder gives up a claimed reminder that cannot be sent.
+func (r *meetupRepository) FailReminder(id uint, failedAt time.Time) error {
+	if err := r.db.Model(&models.MeetupReminder{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"sent_at":   nil,
+		"failed_at": failedAt,
+		"attempts":  gorm.Expr("attempts + 1"),
+	}).Error; err != nil {
+		r.log.Error("error in FailReminder function meetup_repository.go", "error", err)
+		return dto.ErrMeetupReminderFailed
+	}
+	return nil
+}
+
+func orderSlots(db *gorm.DB) *gorm.DB {
+	return db.Order("starts_at ASC")
+}
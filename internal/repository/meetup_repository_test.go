@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListDueRemindersSkipsClosedExchanges(t *testing.T) {
+	db, d := openRecordingDB(t, 0)
+	repo := NewMeetupRepository(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if _, err := repo.ListDueReminders(time.Now(), 10); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.statements) != 1 {
+		t.Fatalf("expected a single query, got %q", d.statements)
+	}
+	stmt := d.statements[0]
+	for _, want := range []string{"JOIN exchanges", "exchanges.status = $", "failed_at IS NULL"} {
+		if !strings.Contains(stmt, want) {
+			t.Fatalf("query is missing %q: %s", want, stmt)
+		}
+	}
+}
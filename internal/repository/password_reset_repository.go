@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PasswordResetRepository interface {
+	Create(token *models.PasswordResetToken) error
+	Reset(tokenHash string, passwordHash string, at time.Time) (uint, error)
+}
+
+type passwordResetRepository struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+func NewPasswordResetRepository(db *gorm.DB, log *slog.Logger) PasswordResetRepository {
+	return &passwordResetRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create stores a new token and retires the older unused ones, so only the
+// latest emailed link works.
+func (r *passwordResetRepository) Create(token *models.PasswordResetToken) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := retireResetTokens(tx, token.UserID, time.Now()); err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+	if err != nil {
+		r.log.Error("error in Create function password_reset_repository.go", "error", err)
+		return dto.ErrPasswordResetFailed
+	}
+	return nil
+}
+
+// Reset consumes the token and sets the new password hash in one
+// transaction. It returns the ID of the user whose password changed.
+func (r *passwordResetRepository) Reset(tokenHash string, passwordHash string, at time.Time) (uint, error) {
+	var userID uint
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var token models.PasswordResetToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, at).
+			First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return dto.ErrPasswordResetTokenInvalid
+			}
+			return err
+		}
+
+		// the link proves the user owns the address
+		res := tx.Model(&models.User{}).Where("id = ?", token.UserID).
+			Updates(map[string]any{"password_hash": passwordHash, "email_verified": true})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return dto.ErrPasswordResetTokenInvalid
+		}
+
+		if err := retireResetTokens(tx, token.UserID, at); err != nil {
+			return err
+		}
+
+		userID = token.UserID
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, dto.ErrPasswordResetTokenInvalid) {
+			return 0, err
+		}
+		r.log.Error("error in Reset function password_reset_repository.go", "error", err)
+		return 0, dto.ErrPasswordResetFailed
+	}
+
+	return userID, nil
+}
+
+func retireResetTokens(tx *gorm.DB, userID uint, at time.Time) error {
+	return tx.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", at).Error
+}
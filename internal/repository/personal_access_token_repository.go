@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+)
+
+type PersonalAccessTokenRepository interface {
+	Create(token *models.PersonalAccessToken) error
+	ListByUser(userID uint) ([]models.PersonalAccessToken, error)
+	CountByUser(userID uint) (int64, error)
+	GetByHash(tokenHash string) (*models.PersonalAccessToken, error)
+	Delete(userID, id uint) error
+	TouchLastUsed(id uint, at time.Time, olderThan time.Time) error
+}
+
+type personalAccessTokenRepository struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+func NewPersonalAccessTokenRepository(db *gorm.DB, log *slog.Logger) PersonalAccessTokenRepository {
+	return &personalAccessTokenRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *personalAccessTokenRepository) Create(token *models.PersonalAccessToken) error {
+	if err := r.db.Create(token).Error; err != nil {
+		r.log.Error("error in Create function personal_access_token_repository.go", "error", err)
+		return dto.ErrPersonalAccessTokenFailed
+	}
+	return nil
+}
+
+func (r *personalAccessTokenRepository) ListByUser(userID uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	if err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		r.log.Error("error in ListByUser function personal_access_token_repository.go", "error", err)
+		return nil, dto.ErrPersonalAccessTokenFailed
+	}
+	return tokens, nil
+}
+
+func (r *personalAccessTokenRepository) CountByUser(userID uint) (int64, error) {
+	var n int64
+	if err := r.db.Model(&models.PersonalAccessToken{}).Where("user_id = ?", userID).Count(&n).Error; err != nil {
+		r.log.Error("error in CountByUser function personal_access_token_repository.go", "error", err)
+		return 0, dto.ErrPersonalAccessTokenFailed
+	}
+	return n, nil
+}
+
+func (r *personalAccessTokenRepository) GetByHash(tokenHash string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, dto.ErrPersonalAccessTokenInvalid
+		}
+		r.log.Error("error in GetByHash function personal_access_token_repository.go", "error", err)
+		return nil, dto.ErrPersonalAccessTokenFailed
+	}
+	return &token, nil
+}
+
+// Delete revokes a token. Tokens of other users are reported as not found.
+func (r *personalAccessTokenRepository) Delete(userID, id uint) error {
+	res := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.PersonalAccessToken{})
+	if res.Error != nil {
+		r.log.Error("error in Delete function personal_access_token_repository.go", "error", res.Error)
+		return dto.ErrPersonalAccessTokenFailed
+	}
+	if res.RowsAffected == 0 {
+		return dto.ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+// TouchLastUsed records a use, skipping the write when the token was already
+// marked as used after olderThan.
+func (r *personalAccessTokenRepository) TouchLastUsed(id uint, at time.Time, olderThan time.Time) error {
+	if err := r.db.Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, olderThan).
+		Update("last_used_at", at).Error; err != nil {
+		r.log.Error("error in TouchLastUsed function personal_access_token_repository.go", "error", err)
+		return dto.ErrPersonalAccessTokenFailed
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+)
+
+type ShipmentRepository interface {
+	ListByExchange(exchangeID uint) ([]models.ExchangeShipment, error)
+	Ship(shipment *models.ExchangeShipment, carrier, trackingNumber string, at time.Time) error
+	Deliver(exchange *models.Exchange, shipment *models.ExchangeShipment, at time.Time, meta dto.ExchangeEventMeta) error
+}
+
+type shipmentRepository struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+func NewShipmentRepository(db *gorm.DB, log *slog.Logger) ShipmentRepository {
+	return &shipmentRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *shipmentRepository) ListByExchange(exchangeID uint) ([]models.ExchangeShipment, error) {
+	var shipments []models.ExchangeShipment
+	if err := r.db.Where("exchange_id = ?", exchangeID).Order("id ASC").Find(&shipments).Error; err != nil {
+		r.log.Error("error in ListByExchange function shipment_repository.go", "error", err)
+		return nil, dto.ErrShipmentGetFailed
+	}
+	return shipments, nil
+}
+
+func (r *shipmentRepository) Ship(shipment *models.ExchangeShipment, carrier, trackingNumber string, at time.Time) error {
+	if shipment == nil {
+		r.log.Error("error in Ship function shipment_repository.go")
+		return dto.ErrShipmentUpdateFailed
+	}
+
+	shipment.Status = "shipped"
+	shipment.Carrier = carrier
+	shipment.TrackingNumber = trackingNumber
+	shipment.ShippedAt = &at
+	if err := r.db.Save(shipment).Error; err != nil {
+		r.log.Error("error in Ship function shipment_repository.go", "error", err)
+		return dto.ErrShipmentUpdateFailed
+	}
+	return nil
+}
+
+// Deliver marks the shipment delivered and confirms the handover for its
+// receiver. The exchange completes once both shipments are delivered.
+func (r *shipmentRepository) Deliver(exchange *models.Exchange, shipment *models.ExchangeShipment, at time.Time, meta dto.ExchangeEventMeta) error {
+	if exchange == nil || shipment == nil {
+		r.log.Error("error in Deliver function shipment_repository.go")
+		return dto.ErrShipmentUpdateFailed
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// the status guard keeps a double submit from confirming twice
+		res := tx.Model(shipment).Where("status = ?", "shipped").Updates(map[string]interface{}{
+			"status":       "delivered",
+			"delivered_at": at,
+		})
+		if res.Error != nil {
+			r.log.Error("error in Deliver function shipment_repository.go", "error", res.Error)
+			return dto.ErrShipmentUpdateFailed
+		}
+		if res.RowsAffected == 0 {
+			return dto.ErrShipmentDelivered
+		}
+		shipment.Status = "delivered"
+		shipment.DeliveredAt = &at
+
+		// the initiator's shipment is received by the recipient and vice versa
+		side := "initiator"
+		if shipment.Side == "initiator" {
+			side = "recipient"
+		}
+		if err := confirmSide(tx, exchange, side, at, meta); err != nil {
+			r.log.Error("error in Deliver function shipment_repository.go", "error", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// createShipments opens both legs of a postal exchange when it is accepted.
+func createShipments(tx *gorm.DB, exchange *models.Exchange) error {
+	shipments := []models.ExchangeShipment{
+		{ExchangeID: exchange.ID, Side: "initiator", SenderID: exchange.InitiatorID, ReceiverID: exchange.RecipientID, Status: "pending"},
+		{ExchangeID: exchange.ID, Side: "recipient", SenderID: exchange.RecipientID, ReceiverID: exchange.InitiatorID, Status: "pending"},
+	}
+	return tx.Create(&shipments).Error
+}
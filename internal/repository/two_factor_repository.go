@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrTwoFactorNotFound = errors.New("two-factor authentication is not set up")
+
+type TwoFactorRepository interface {
+	Get(userID uint) (*models.TwoFactor, error)
+	SavePending(tf *models.TwoFactor) error
+	Enable(userID uint, step int64, at time.Time, codeHashes []string) error
+	Delete(userID uint) error
+	UseStep(userID uint, step int64) (bool, error)
+	UseRecoveryCode(userID uint, codeHash string, at time.Time) (bool, error)
+	ReplaceRecoveryCodes(userID uint, codeHashes []string) error
+	CountRecoveryCodes(userID uint) (int64, error)
+}
+
+type twoFactorRepository struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+func NewTwoFactorRepository(db *gorm.DB, log *slog.Logger) TwoFactorRepository {
+	return &twoFactorRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *twoFactorRepository) Get(userID uint) (*models.TwoFactor, error) {
+	var tf models.TwoFactor
+	if err := r.db.Where("user_id = ?", userID).First(&tf).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTwoFactorNotFound
+		}
+		r.log.Error("error in Get function two_factor_repository.go", "error", err)
+		return nil, dto.ErrTwoFactorFailed
+	}
+	return &tf, nil
+}
+
+// SavePending stores a new secret that is not enabled yet, replacing an
+// earlier unfinished enrollment. An enabled enrollment is left untouched.
+func (r *twoFactorRepository) SavePending(tf *models.TwoFactor) error {
+	res := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "last_used_step", "updated_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "two_factors.enabled_at IS NULL"}}},
+	}).Create(tf)
+	if res.Error != nil {
+		r.log.Error("error in SavePending function two_factor_repository.go", "error", res.Error)
+		return dto.ErrTwoFactorFailed
+	}
+	if res.RowsAffected == 0 {
+		return dto.ErrTwoFactorAlreadyEnabled
+	}
+	return nil
+}
+
+// Enable turns on a pending enrollment and stores its first recovery codes.
+func (r *twoFactorRepository) Enable(userID uint, step int64, at time.Time, codeHashes []string) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.TwoFactor{}).
+			Where("user_id = ? AND enabled_at IS NULL", userID).
+			Updates(map[string]any{"enabled_at": at, "last_used_step": step})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return dto.ErrTwoFactorAlreadyEnabled
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+	if err != nil {
+		if errors.Is(err, dto.ErrTwoFactorAlreadyEnabled) {
+			return err
+		}
+		r.log.Error("error in Enable function two_factor_repository.go", "error", err)
+		return dto.ErrTwoFactorFailed
+	}
+	return nil
+}
+
+func (r *twoFactorRepository) Delete(userID uint) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.TwoFactor{}).Error
+	})
+	if err != nil {
+		r.log.Error("error in Delete function two_factor_repository.go", "error", err)
+		return dto.ErrTwoFactorFailed
+	}
+	return nil
+}
+
+// UseStep records an accepted code. It reports false when the step, or a
+// later one, was already used.
+func (r *twoFactorRepository) UseStep(userID uint, step int64) (bool, error) {
+	res := r.db.Model(&models.TwoFactor{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if res.Error != nil {
+		r.log.Error("error in UseStep function two_factor_repository.go", "error", res.Error)
+		return false, dto.ErrTwoFactorFailed
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// UseRecoveryCode marks a code as used. It reports false when the code is
+// unknown or was used before.
+func (r *twoFactorRepository) UseRecoveryCode(userID uint, codeHash string, at time.Time) (bool, error) {
+	res := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", at)
+	if res.Error != nil {
+		r.log.Error("error in UseRecoveryCode function two_factor_repository.go", "error", res.Error)
+		return false, dto.ErrTwoFactorFailed
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *twoFactorRepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	if err := r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	}); err != nil {
+		r.log.Error("error in ReplaceRecoveryCodes function two_factor_repository.go", "error", err)
+		return dto.ErrTwoFactorFailed
+	}
+	return nil
+}
+
+func (r *twoFactorRepository) CountRecoveryCodes(userID uint) (int64, error) {
+	var n int64
+	if err := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&n).Error; err != nil {
+		r.log.Error("error in CountRecoveryCodes function two_factor_repository.go", "error", err)
+		return 0, dto.ErrTwoFactorFailed
+	}
+	return n, nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	codes := make([]models.RecoveryCode, 0, len(codeHashes))
+	for _, h := range codeHashes {
+		codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: h})
+	}
+	return tx.Create(&codes).Error
+}
@@ -96,3 +96,19 @@ func (r *userRepository) Delete(id uint) error {
 	}
 	return nil
 }
+
+// MigrateEmailVerification adds the email_verified column before AutoMigrate
+// does. Accounts that existed before verification shipped are marked as
+// verified, so they keep access to exchanges; new accounts start unverified.
+func MigrateEmailVerification(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&models.User{}) {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified boolean NOT NULL DEFAULT true").Error; err != nil {
+			return err
+		}
+		return tx.Exec("ALTER TABLE users ALTER COLUMN email_verified SET DEFAULT false").Error
+	})
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WishlistRepository interface {
+	Add(item *models.WishlistItem) error
+	GetByID(id uint) (*models.WishlistItem, error)
+	Exists(userID, bookID uint) (bool, error)
+	ListByUser(userID uint) ([]models.WishlistItem, error)
+	Delete(id uint) error
+}
+
+type wishlistRepository struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+func NewWishlistRepository(db *gorm.DB, log *slog.Logger) WishlistRepository {
+	return &wishlistRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *wishlistRepository) Add(item *models.WishlistItem) error {
+	if item == nil {
+		r.log.Error("error in Add function wishlist_repository.go")
+		return dto.ErrWishlistAddFailed
+	}
+
+	// item.Book is only set for the response, the book row must not be written
+	if err := r.db.Omit(clause.Associations).Create(item).Error; err != nil {
+		r.log.Error("error in Add function wishlist_repository.go", "error", err)
+		return dto.ErrWishlistAddFailed
+	}
+	return nil
+}
+
+func (r *wishlistRepository) GetByID(id uint) (*models.WishlistItem, error) {
+	var item models.WishlistItem
+	if err := r.db.First(&item, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, dto.ErrWishlistItemNotFound
+		}
+		r.log.Error("error in GetByID function wishlist_repository.go", "error", err)
+		return nil, dto.ErrWishlistGetFailed
+	}
+	return &item, nil
+}
+
+func (r *wishlistRepository) Exists(userID, bookID uint) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.WishlistItem{}).
+		Where("user_id = ? AND book_id = ?", userID, bookID).
+		Count(&count).Error; err != nil {
+		r.log.Error("error in Exists function wishlist_repository.go", "error", err)
+		return false, dto.ErrWishlistGetFailed
+	}
+	return count > 0, nil
+}
+
+func (r *wishlistRepository) ListByUser(userID uint) ([]models.WishlistItem, error) {
+	var items []models.WishlistItem
+	if err := r.db.
+		Where("user_id = ?", userID).
+		Preload("Book").
+		Preload("Book.User").
+		Preload("Book.Genres").
+		Order("created_at DESC").
+		Find(&items).Error; err != nil {
+		r.log.Error("error in ListByUser function wishlist_repository.go", "error", err)
+		return nil, dto.ErrWishlistGetFailed
+	}
+	return items, nil
+}
+
+func (r *wishlistRepository) Delete(id uint) error {
+	if err := r.db.Delete(&models.WishlistItem{}, id).Error; err != nil {
+		r.log.Error("error in Delete function wishlist_repository.go", "error", err)
+		return dto.ErrWishlistDeleteFailed
+	}
+	return nil
+}
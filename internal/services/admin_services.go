@@ -0,0 +1,64 @@
+package services
+
+import (
+	"log/slog"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+)
+
+type AdminService interface {
+	GetStats() (*dto.AdminStatsResponse, error)
+}
+
+type adminService struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+func NewAdminService(db *gorm.DB, log *slog.Logger) AdminService {
+	return &adminService{db: db, log: log}
+}
+
+type statusCount struct {
+	Status string
+	Count  int64
+}
+
+func (s *adminService) GetStats() (*dto.AdminStatsResponse, error) {
+	stats := &dto.AdminStatsResponse{
+		BooksByStatus:     map[string]int64{},
+		ExchangesByStatus: map[string]int64{},
+	}
+
+	if err := s.db.Model(&models.User{}).Count(&stats.UsersCount).Error; err != nil {
+		s.log.Error("error in GetStats function admin_services.go", "error", err)
+		return nil, dto.ErrAdminStatsFailed
+	}
+
+	var books []statusCount
+	if err := s.db.Model(&models.Book{}).Select("status, count(*) AS count").Group("status").Scan(&books).Error; err != nil {
+		s.log.Error("error in GetStats function admin_services.go", "error", err)
+		return nil, dto.ErrAdminStatsFailed
+	}
+	for _, b := range books {
+		stats.BooksByStatus[b.Status] = b.Count
+	}
+
+	var exchanges []statusCount
+	if err := s.db.Model(&models.Exchange{}).Select("status, count(*) AS count").Group("status").Scan(&exchanges).Error; err != nil {
+		s.log.Error("error in GetStats function admin_services.go", "error", err)
+		return nil, dto.ErrAdminStatsFailed
+	}
+	for _, e := range exchanges {
+		stats.ExchangesByStatus[e.Status] = e.Count
+	}
+
+	if err := s.db.Model(&models.Dispute{}).Where("status <> ?", "resolved").Count(&stats.OpenDisputes).Error; err != nil {
+		s.log.Error("error in GetStats function admin_services.go", "error", err)
+		return nil, dto.ErrAdminStatsFailed
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/jwtutil"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/dasler-fw/bookcrossing/internal/tokenstore"
+)
+
+const tokenStoreTimeout = 2 * time.Second
+
+type AuthService interface {
+	IssueTokens(userID uint, role string) (*dto.TokenResponse, error)
+	Refresh(refreshToken string) (*dto.TokenResponse, error)
+	Logout(userID uint, jti string, expiresAt time.Time, refreshToken string) error
+	RevokeAllSessions(userID uint) error
+}
+
+type authService struct {
+	userRepo   repository.UserRepository
+	store      tokenstore.Store
+	refreshTTL time.Duration
+	log        *slog.Logger
+}
+
+func NewAuthService(userRepo repository.UserRepository, store tokenstore.Store, refreshTTL time.Duration, log *slog.Logger) AuthService {
+	if refreshTTL <= 0 {
+		refreshTTL = 30 * 24 * time.Hour
+	}
+
+	return &authService{
+		userRepo:   userRepo,
+		store:      store,
+		refreshTTL: refreshTTL,
+		log:        log,
+	}
+}
+
+// IssueTokens starts a new refresh token family, i.e. a new login session.
+func (s *authService) IssueTokens(userID uint, role string) (*dto.TokenResponse, error) {
+	family, err := randomToken(16)
+	if err != nil {
+		return nil, dto.ErrTokenIssueFailed
+	}
+	return s.issue(userID, role, family)
+}
+
+// Refresh rotates a refresh token. Every refresh token can be used once;
+// presenting a used one means it leaked, so the whole family is revoked and
+// the legitimate holder has to log in again.
+func (s *authService) Refresh(refreshToken string) (*dto.TokenResponse, error) {
+	if refreshToken == "" {
+		return nil, dto.ErrRefreshTokenInvalid
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tokenStoreTimeout)
+	defer cancel()
+
+	rec, first, err := s.store.ConsumeRefresh(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, tokenstore.ErrNotFound) {
+			return nil, dto.ErrRefreshTokenInvalid
+		}
+		s.log.Error("error in Refresh function auth_services.go", "error", err)
+		return nil, dto.ErrTokenIssueFailed
+	}
+
+	if !first {
+		s.log.Warn("refresh token reuse detected, revoking family", "user_id", rec.UserID, "family_id", rec.FamilyID)
+		if err := s.store.RevokeFamily(ctx, rec.FamilyID, time.Now().Add(s.refreshTTL)); err != nil {
+			s.log.Error("error in Refresh function auth_services.go", "error", err)
+		}
+		return nil, dto.ErrRefreshTokenReused
+	}
+
+	if time.Now().After(rec.ExpiresAt) {
+		return nil, dto.ErrRefreshTokenInvalid
+	}
+
+	revoked, err := s.store.FamilyRevoked(ctx, rec.FamilyID)
+	if err != nil {
+		s.log.Error("error in Refresh function auth_services.go", "error", err)
+		return nil, dto.ErrTokenIssueFailed
+	}
+	if revoked {
+		return nil, dto.ErrRefreshTokenInvalid
+	}
+
+	revokedAt, err := s.store.UserRevokedAt(ctx, rec.UserID)
+	if err != nil {
+		s.log.Error("error in Refresh function auth_services.go", "error", err)
+		return nil, dto.ErrTokenIssueFailed
+	}
+	if !revokedAt.IsZero() && rec.IssuedAt.Before(revokedAt) {
+		return nil, dto.ErrRefreshTokenInvalid
+	}
+
+	// the role is read again so role changes apply on the next refresh
+	user, err := s.userRepo.GetByID(rec.UserID)
+	if err != nil {
+		return nil, dto.ErrRefreshTokenInvalid
+	}
+
+	return s.issue(user.ID, user.Role, rec.FamilyID)
+}
+
+// Logout denies the current access token until it expires and, when the
+// refresh token is given, revokes its family so it cannot be renewed.
+func (s *authService) Logout(userID uint, jti string, expiresAt time.Time, refreshToken string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenStoreTimeout)
+	defer cancel()
+
+	if jti != "" && time.Now().Before(expiresAt) {
+		if err := s.store.DenyJTI(ctx, jti, expiresAt); err != nil {
+			s.log.Error("error in Logout function auth_services.go", "error", err)
+			return dto.ErrTokenRevokeFailed
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	rec, _, err := s.store.ConsumeRefresh(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, tokenstore.ErrNotFound) {
+			return dto.ErrRefreshTokenInvalid
+		}
+		s.log.Error("error in Logout function auth_services.go", "error", err)
+		return dto.ErrTokenRevokeFailed
+	}
+	if rec.UserID != userID {
+		return dto.ErrRefreshTokenInvalid
+	}
+
+	if err := s.store.RevokeFamily(ctx, rec.FamilyID, time.Now().Add(s.refreshTTL)); err != nil {
+		s.log.Error("error in Logout function auth_services.go", "error", err)
+		return dto.ErrTokenRevokeFailed
+	}
+
+	return nil
+}
+
+// RevokeAllSessions invalidates every access and refresh token issued to the
+// user so far, e.g. after a password reset.
+func (s *authService) RevokeAllSessions(userID uint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenStoreTimeout)
+	defer cancel()
+
+	now := time.Now()
+	if err := s.store.RevokeUser(ctx, userID, now, now.Add(s.refreshTTL)); err != nil {
+		s.log.Error("error in RevokeAllSessions function auth_services.go", "error", err, "user_id", userID)
+		return dto.ErrTokenRevokeFailed
+	}
+
+	return nil
+}
+
+func (s *authService) issue(userID uint, role, family string) (*dto.TokenResponse, error) {
+	access, err := jwtutil.GenerateToken(userID, role)
+	if err != nil {
+		s.log.Error("error in issue function auth_services.go", "error", err)
+		return nil, dto.ErrTokenIssueFailed
+	}
+
+	refresh, err := randomToken(32)
+	if err != nil {
+		return nil, dto.ErrTokenIssueFailed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tokenStoreTimeout)
+	defer cancel()
+
+	if err := s.store.SaveRefresh(ctx, hashToken(refresh), tokenstore.RefreshRecord{
+		UserID:    userID,
+		FamilyID:  family,
+		IssuedAt:  time.Now(),
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}); err != nil {
+		s.log.Error("error in issue function auth_services.go", "error", err)
+		return nil, dto.ErrTokenIssueFailed
+	}
+
+	return &dto.TokenResponse{
+		Token:        access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(jwtutil.AccessTokenTTL() / time.Second),
+	}, nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is what the store keeps instead of the refresh token itself.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
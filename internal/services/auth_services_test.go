@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/jwtutil"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/tokenstore"
+	"gorm.io/gorm"
+)
+
+func newTestAuthService(t *testing.T) AuthService {
+	t.Helper()
+
+	t.Setenv("JWT_SIGNING_KEY", "")
+	t.Setenv("SUPER_SECRET_KEY", "test-secret")
+	ks, err := jwtutil.LoadKeysFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtutil.UseKeys(ks)
+
+	user := &models.User{Model: gorm.Model{ID: 7}, Role: models.RoleUser}
+	repo := &memoryUserRepo{users: map[uint]*models.User{user.ID: user}}
+	return NewAuthService(repo, tokenstore.NewMemoryStore(), time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestRefreshRotation(t *testing.T) {
+	auth := newTestAuthService(t)
+
+	first, err := auth.IssueTokens(7, models.RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := auth.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.Token == "" {
+		t.Fatal("refresh did not rotate the tokens")
+	}
+
+	third, err := auth.Refresh(second.RefreshToken)
+	if err != nil {
+		t.Fatalf("rotated token rejected: %v", err)
+	}
+	if third.RefreshToken == second.RefreshToken {
+		t.Fatal("refresh did not rotate the tokens")
+	}
+
+	if _, err := auth.Refresh("unknown"); !errors.Is(err, dto.ErrRefreshTokenInvalid) {
+		t.Fatalf("got %v, want ErrRefreshTokenInvalid", err)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	auth := newTestAuthService(t)
+
+	stolen, err := auth.IssueTokens(7, models.RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legit, err := auth.Refresh(stolen.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := auth.Refresh(stolen.RefreshToken); !errors.Is(err, dto.ErrRefreshTokenReused) {
+		t.Fatalf("got %v, want ErrRefreshTokenReused", err)
+	}
+
+	// the whole family is gone, including the token of the legitimate holder
+	if _, err := auth.Refresh(legit.RefreshToken); !errors.Is(err, dto.ErrRefreshTokenInvalid) {
+		t.Fatalf("got %v, want ErrRefreshTokenInvalid", err)
+	}
+
+	// other sessions of the user are not affected
+	other, err := auth.IssueTokens(7, models.RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Refresh(other.RefreshToken); err != nil {
+		t.Fatalf("unrelated session rejected: %v", err)
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	auth := newTestAuthService(t)
+
+	before, err := auth.IssueTokens(7, models.RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+
+	if err := auth.RevokeAllSessions(7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Refresh(before.RefreshToken); !errors.Is(err, dto.ErrRefreshTokenInvalid) {
+		t.Fatalf("got %v, want ErrRefreshTokenInvalid", err)
+	}
+}
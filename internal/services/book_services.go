@@ -15,6 +15,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/dasler-fw/bookcrossing/internal/authz"
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/dasler-fw/bookcrossing/internal/repository"
@@ -31,24 +32,26 @@ type BookService interface {
 	CreateBook(userID uint, ras dto.CreateBookRequest) (*models.Book, error)
 	GetByID(id uint) (*models.Book, error)
 	GetList(limit, offset int) ([]models.Book, error)
-	Update(bookID uint, userID uint, req dto.UpdateBookRequest) (*models.Book, error)
-	Delete(bookID uint, userID uint) error
+	Update(bookID uint, actor authz.Actor, req dto.UpdateBookRequest) (*models.Book, error)
+	Delete(bookID uint, actor authz.Actor) error
 	SearchBooks(query dto.BookListQuery) ([]models.Book, int64, error)
 	GetBooksByUserID(userID uint, status string) ([]models.Book, error)
 	GetAvailableBooks(city string) ([]models.Book, error)
 }
 
 type bookService struct {
-	bookRepo repository.BookRepository
-	log      *slog.Logger
-	rdb      *redis.Client
+	bookRepo   repository.BookRepository
+	authorizer authz.Authorizer
+	log        *slog.Logger
+	rdb        *redis.Client
 }
 
-func NewServiceBook(bookRepo repository.BookRepository, log *slog.Logger, rdb *redis.Client) BookService {
+func NewServiceBook(bookRepo repository.BookRepository, authorizer authz.Authorizer, log *slog.Logger, rdb *redis.Client) BookService {
 	svc := &bookService{
-		bookRepo: bookRepo,
-		log:      log,
-		rdb:      rdb,
+		bookRepo:   bookRepo,
+		authorizer: authorizer,
+		log:        log,
+		rdb:        rdb,
 	}
 
 	return svc
@@ -179,14 +182,14 @@ func (s *bookService) GetList(limit, offset int) ([]models.Book, error) {
 	return list, nil
 }
 
-func (s *bookService) Update(bookID uint, userID uint, req dto.UpdateBookRequest) (*models.Book, error) {
+func (s *bookService) Update(bookID uint, actor authz.Actor, req dto.UpdateBookRequest) (*models.Book, error) {
 	book, err := s.bookRepo.GetByID(bookID)
 	if err != nil {
 		return nil, err
 	}
 
-	if book.UserID != userID {
-		return nil, dto.ErrBookForbidden
+	if err := s.authorizer.Can(actor, authz.ActionUpdate, book); err != nil {
+		return nil, err
 	}
 
 	if req.Description != nil {
@@ -201,14 +204,14 @@ func (s *bookService) Update(bookID uint, userID uint, req dto.UpdateBookRequest
 	return book, nil
 }
 
-func (s *bookService) Delete(bookID uint, userID uint) error {
+func (s *bookService) Delete(bookID uint, actor authz.Actor) error {
 	book, err := s.bookRepo.GetByID(bookID)
 	if err != nil {
 		return err
 	}
 
-	if book.UserID != userID {
-		return dto.ErrBookForbidden
+	if err := s.authorizer.Can(actor, authz.ActionDelete, book); err != nil {
+		return err
 	}
 
 	if book.Status == "pending" || book.Status == "accepted" {
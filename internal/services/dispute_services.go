@@ -0,0 +1,350 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dasler-fw/bookcrossing/internal/authz"
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+)
+
+var disputeReasons = map[string]bool{
+	"not_delivered":   true,
+	"wrong_condition": true,
+	"other":           true,
+}
+
+var disputeResolutions = map[string]bool{
+	"reverse_transfer":  true,
+	"adjust_reputation": true,
+	"no_action":         true,
+}
+
+var evidenceExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
+
+type DisputeService interface {
+	Open(userID uint, req dto.OpenDisputeRequest) (*models.Dispute, error)
+	Get(disputeID uint, userID uint) (*models.Dispute, error)
+	AddEvidence(disputeID uint, userID uint, fileName, contentType string, size int64, file io.Reader) (*models.DisputeEvidence, error)
+	GetEvidence(disputeID uint, evidenceID uint, userID uint, moderator bool) (*models.DisputeEvidence, error)
+	Queue(query dto.DisputeQueueQuery) ([]models.Dispute, int64, error)
+	GetForModeration(disputeID uint) (*models.Dispute, error)
+	Claim(disputeID uint, moderatorID uint) (*models.Dispute, error)
+	Resolve(disputeID uint, moderatorID uint, req dto.ResolveDisputeRequest, requestID string) (*models.Dispute, error)
+}
+
+type disputeService struct {
+	disputeRepo  repository.DisputeRepository
+	exchangeRepo repository.ExchangeRepository
+	authorizer   authz.Authorizer
+	evidenceDir  string
+	log          *slog.Logger
+}
+
+func NewDisputeService(disputeRepo repository.DisputeRepository, exchangeRepo repository.ExchangeRepository, authorizer authz.Authorizer, evidenceDir string, log *slog.Logger) DisputeService {
+	if evidenceDir == "" {
+		evidenceDir = filepath.Join("uploads", "disputes")
+	}
+	return &disputeService{
+		disputeRepo:  disputeRepo,
+		exchangeRepo: exchangeRepo,
+		authorizer:   authorizer,
+		evidenceDir:  evidenceDir,
+		log:          log,
+	}
+}
+
+func (s *disputeService) Open(userID uint, req dto.OpenDisputeRequest) (*models.Dispute, error) {
+	if userID == 0 {
+		return nil, dto.ErrUnauthorized
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if !disputeReasons[reason] {
+		return nil, dto.ErrDisputeInvalidReason
+	}
+
+	description := strings.TrimSpace(req.Description)
+	if n := len([]rune(description)); n < dto.MinDisputeDescriptionLength || n > dto.MaxDisputeDescriptionLength {
+		return nil, dto.ErrDisputeDescription
+	}
+
+	if req.ExchangeID == 0 {
+		return nil, dto.ErrExchangeInvalidID
+	}
+
+	exchange, err := s.exchangeRepo.GetByID(req.ExchangeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authorizer.Can(authz.Actor{UserID: userID}, authz.ActionParticipate, exchange); err != nil {
+		s.log.Error("error in Open function dispute_services.go", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	if exchange.Status != "accepted" && exchange.Status != "completed" {
+		return nil, dto.ErrDisputeExchangeStatus
+	}
+
+	active, err := s.disputeRepo.HasActive(exchange.ID)
+	if err != nil {
+		return nil, err
+	}
+	if active {
+		return nil, dto.ErrDisputeAlreadyOpen
+	}
+
+	dispute := &models.Dispute{
+		ExchangeID:  exchange.ID,
+		OpenedByID:  userID,
+		Reason:      reason,
+		Description: description,
+		Status:      "open",
+	}
+	if err := s.disputeRepo.Create(dispute); err != nil {
+		return nil, err
+	}
+
+	return dispute, nil
+}
+
+func (s *disputeService) Get(disputeID uint, userID uint) (*models.Dispute, error) {
+	dispute, _, err := s.getParticipantDispute(disputeID, userID)
+	return dispute, err
+}
+
+// AddEvidence stores an uploaded file under a random name in the evidence
+// directory. Evidence can be added until the dispute is resolved.
+func (s *disputeService) AddEvidence(disputeID uint, userID uint, fileName, contentType string, size int64, file io.Reader) (*models.DisputeEvidence, error) {
+	ext, ok := evidenceExtensions[contentType]
+	if !ok {
+		return nil, dto.ErrDisputeEvidenceType
+	}
+	if size <= 0 || size > dto.MaxDisputeEvidenceSize {
+		return nil, dto.ErrDisputeEvidenceSize
+	}
+
+	dispute, _, err := s.getParticipantDispute(disputeID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if dispute.Status == "resolved" {
+		return nil, dto.ErrDisputeClosed
+	}
+	if len(dispute.Evidence) >= dto.MaxDisputeEvidenceFiles {
+		return nil, dto.ErrDisputeTooManyEvidence
+	}
+
+	path, written, err := s.storeEvidence(file, ext)
+	if err != nil {
+		return nil, err
+	}
+
+	evidence := &models.DisputeEvidence{
+		UploaderID:  userID,
+		FileName:    filepath.Base(fileName),
+		ContentType: contentType,
+		Size:        written,
+		Path:        path,
+	}
+	if err := s.disputeRepo.AddEvidence(dispute, evidence); err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+
+	return evidence, nil
+}
+
+func (s *disputeService) storeEvidence(file io.Reader, ext string) (string, int64, error) {
+	if err := os.MkdirAll(s.evidenceDir, 0o750); err != nil {
+		s.log.Error("error in storeEvidence function dispute_services.go", "error", err)
+		return "", 0, dto.ErrDisputeEvidenceFailed
+	}
+
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		s.log.Error("error in storeEvidence function dispute_services.go", "error", err)
+		return "", 0, dto.ErrDisputeEvidenceFailed
+	}
+	path := filepath.Join(s.evidenceDir, hex.EncodeToString(name)+ext)
+
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		s.log.Error("error in storeEvidence function dispute_services.go", "error", err)
+		return "", 0, dto.ErrDisputeEvidenceFailed
+	}
+
+	// read one byte past the limit to catch bodies larger than announced
+	written, err := io.Copy(out, io.LimitReader(file, dto.MaxDisputeEvidenceSize+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || written > dto.MaxDisputeEvidenceSize {
+		_ = os.Remove(path)
+		if err != nil {
+			s.log.Error("error in storeEvidence function dispute_services.go", "error", err)
+			return "", 0, dto.ErrDisputeEvidenceFailed
+		}
+		return "", 0, dto.ErrDisputeEvidenceSize
+	}
+
+	return path, written, nil
+}
+
+func (s *disputeService) GetEvidence(disputeID uint, evidenceID uint, userID uint, moderator bool) (*models.DisputeEvidence, error) {
+	var dispute *models.Dispute
+	var err error
+	if moderator {
+		dispute, err = s.disputeRepo.GetByID(disputeID)
+	} else {
+		dispute, _, err = s.getParticipantDispute(disputeID, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range dispute.Evidence {
+		if dispute.Evidence[i].ID == evidenceID {
+			return &dispute.Evidence[i], nil
+		}
+	}
+	return nil, dto.ErrDisputeEvidenceNotFound
+}
+
+func (s *disputeService) Queue(query dto.DisputeQueueQuery) ([]models.Dispute, int64, error) {
+	statuses := []string{"open", "in_review"}
+	switch query.Status {
+	case "":
+	case "open", "in_review", "resolved":
+		statuses = []string{query.Status}
+	default:
+		return nil, 0, dto.ErrInvalidInput
+	}
+
+	if query.Page <= 0 {
+		query.Page = dto.DefaultPage
+	}
+	if query.Limit <= 0 {
+		query.Limit = dto.DefaultDisputeLimit
+	}
+	if query.Limit > dto.MaxDisputeLimit {
+		query.Limit = dto.MaxDisputeLimit
+	}
+
+	return s.disputeRepo.ListQueue(statuses, query.Limit, (query.Page-1)*query.Limit)
+}
+
+func (s *disputeService) GetForModeration(disputeID uint) (*models.Dispute, error) {
+	return s.disputeRepo.GetByID(disputeID)
+}
+
+func (s *disputeService) Claim(disputeID uint, moderatorID uint) (*models.Dispute, error) {
+	dispute, err := s.disputeRepo.GetByID(disputeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if dispute.Status == "resolved" {
+		return nil, dto.ErrDisputeClosed
+	}
+	if dispute.Status != "open" {
+		return nil, dto.ErrDisputeAlreadyClaimed
+	}
+
+	if err := s.disputeRepo.Claim(dispute, moderatorID); err != nil {
+		return nil, err
+	}
+
+	return dispute, nil
+}
+
+// Resolve closes a dispute claimed by the moderator and applies the chosen
+// resolution.
+func (s *disputeService) Resolve(disputeID uint, moderatorID uint, req dto.ResolveDisputeRequest, requestID string) (*models.Dispute, error) {
+	resolution := strings.TrimSpace(req.Resolution)
+	if !disputeResolutions[resolution] {
+		return nil, dto.ErrDisputeInvalidResolution
+	}
+	if resolution != "adjust_reputation" && (req.InitiatorReputationDelta != 0 || req.RecipientReputationDelta != 0) {
+		return nil, dto.ErrDisputeReputationDelta
+	}
+
+	note := strings.TrimSpace(req.Note)
+	if len([]rune(note)) > dto.MaxDisputeNoteLength {
+		return nil, dto.ErrInvalidInput
+	}
+
+	dispute, err := s.disputeRepo.GetByID(disputeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if dispute.Status == "resolved" {
+		return nil, dto.ErrDisputeClosed
+	}
+	if dispute.Status != "in_review" || dispute.ModeratorID == nil || *dispute.ModeratorID != moderatorID {
+		return nil, dto.ErrDisputeNotClaimed
+	}
+
+	exchange, err := s.exchangeRepo.GetByID(dispute.ExchangeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if resolution == "reverse_transfer" && exchange.Status != "completed" {
+		return nil, dto.ErrDisputeNotReversible
+	}
+
+	if err := s.disputeRepo.Resolve(dispute, exchange, repository.DisputeResolution{
+		ModeratorID:              moderatorID,
+		Resolution:               resolution,
+		Note:                     note,
+		InitiatorReputationDelta: req.InitiatorReputationDelta,
+		RecipientReputationDelta: req.RecipientReputationDelta,
+		RequestID:                requestID,
+	}); err != nil {
+		if !errors.Is(err, dto.ErrDisputeBooksMoved) {
+			s.log.Error("error in Resolve function dispute_services.go", "error", err, "dispute_id", disputeID)
+		}
+		return nil, err
+	}
+
+	return dispute, nil
+}
+
+func (s *disputeService) getParticipantDispute(disputeID uint, userID uint) (*models.Dispute, *models.Exchange, error) {
+	if userID == 0 {
+		return nil, nil, dto.ErrUnauthorized
+	}
+
+	dispute, err := s.disputeRepo.GetByID(disputeID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exchange, err := s.exchangeRepo.GetByID(dispute.ExchangeID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.authorizer.Can(authz.Actor{UserID: userID}, authz.ActionParticipate, exchange); err != nil {
+		s.log.Error("error in getParticipantDispute function dispute_services.go", "error", err, "user_id", userID)
+		return nil, nil, err
+	}
+
+	return dispute, exchange, nil
+}
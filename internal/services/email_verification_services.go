@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/mailer"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	verificationResendPrefix = "email-verification:resend:"
+	mailTimeout              = 10 * time.Second
+)
+
+type EmailVerificationConfig struct {
+	// BaseURL is the public address the verification link points to.
+	BaseURL string
+	Secret  []byte
+	TTL     time.Duration
+	// ResendCooldown is the minimum time between two emails to one user.
+	ResendCooldown time.Duration
+}
+
+type EmailVerificationService interface {
+	Send(user *models.User) error
+	Verify(token string) error
+	Resend(userID uint) error
+}
+
+type emailVerificationService struct {
+	userRepo repository.UserRepository
+	mailer   mailer.Mailer
+	rdb      *redis.Client
+	cfg      EmailVerificationConfig
+	log      *slog.Logger
+}
+
+// NewEmailVerificationService fails without a secret, verification links
+// could be forged otherwise.
+func NewEmailVerificationService(userRepo repository.UserRepository, m mailer.Mailer, rdb *redis.Client, cfg EmailVerificationConfig, log *slog.Logger) (EmailVerificationService, error) {
+	if len(cfg.Secret) == 0 {
+		return nil, errors.New("email verification secret is empty")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "http://localhost:8080"
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 48 * time.Hour
+	}
+	if cfg.ResendCooldown <= 0 {
+		cfg.ResendCooldown = time.Minute
+	}
+
+	return &emailVerificationService{
+		userRepo: userRepo,
+		mailer:   m,
+		rdb:      rdb,
+		cfg:      cfg,
+		log:      log,
+	}, nil
+}
+
+// Send mails a signed link that verifies the current email of user.
+func (s *emailVerificationService) Send(user *models.User) error {
+	token := s.sign(user.ID, user.Email, time.Now().Add(s.cfg.TTL))
+	link := strings.TrimRight(s.cfg.BaseURL, "/") + "/users/verify?token=" + url.QueryEscape(token)
+
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+
+	err := s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hi %s,\n\nplease confirm your email address by opening the link below:\n\n%s\n\nThe link is valid for %s.\n",
+			user.Name, link, s.cfg.TTL),
+	})
+	if err != nil {
+		s.log.Error("error in Send function email_verification_services.go", "error", err, "user_id", user.ID)
+		return dto.ErrVerificationSendFailed
+	}
+
+	return nil
+}
+
+func (s *emailVerificationService) Verify(token string) error {
+	userID, email, err := s.parse(token)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return dto.ErrVerificationTokenInvalid
+	}
+
+	// a link sent to an older address must not verify the new one
+	if !strings.EqualFold(user.Email, email) {
+		return dto.ErrVerificationTokenInvalid
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	user.EmailVerified = true
+	if err := s.userRepo.Update(user); err != nil {
+		return dto.ErrUserUpdateFailed
+	}
+
+	return nil
+}
+
+// Resend mails a new link unless the previous one was sent less than
+// ResendCooldown ago.
+func (s *emailVerificationService) Resend(userID uint) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return repository.ErrUserNotFound
+	}
+
+	if user.EmailVerified {
+		return dto.ErrEmailAlreadyVerified
+	}
+
+	if s.rdb != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		allowed, err := s.rdb.SetNX(ctx, verificationResendPrefix+strconv.FormatUint(uint64(userID), 10), 1, s.cfg.ResendCooldown).Result()
+		cancel()
+		if err != nil {
+			s.log.Warn("verification resend limit check failed", "error", err, "user_id", userID)
+		} else if !allowed {
+			return dto.ErrVerificationRateLimited
+		}
+	}
+
+	return s.Send(user)
+}
+
+// sign returns base64(userID:expires:email) + "." + base64(HMAC-SHA256).
+func (s *emailVerificationService) sign(userID uint, email string, expires time.Time) string {
+	payload := fmt.Sprintf("%d:%d:%s", userID, expires.Unix(), email)
+
+	mac := hmac.New(sha256.New, s.cfg.Secret)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *emailVerificationService) parse(token string) (uint, string, error) {
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, "", dto.ErrVerificationTokenInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return 0, "", dto.ErrVerificationTokenInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return 0, "", dto.ErrVerificationTokenInvalid
+	}
+
+	mac := hmac.New(sha256.New, s.cfg.Secret)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return 0, "", dto.ErrVerificationTokenInvalid
+	}
+
+	parts := strings.SplitN(string(payload), ":", 3)
+	if len(parts) != 3 {
+		return 0, "", dto.ErrVerificationTokenInvalid
+	}
+
+	userID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", dto.ErrVerificationTokenInvalid
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, "", dto.ErrVerificationTokenInvalid
+	}
+	if time.Now().Unix() > expires {
+		return 0, "", dto.ErrVerificationTokenInvalid
+	}
+
+	return uint(userID), parts[2], nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/mailer"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"gorm.io/gorm"
+)
+
+type memoryUserRepo struct {
+	repository.UserRepository
+	users map[uint]*models.User
+}
+
+func (r *memoryUserRepo) GetByID(id uint) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *memoryUserRepo) Update(user *models.User) error {
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+func tokenFromMail(t *testing.T, msg mailer.Message) string {
+	t.Helper()
+
+	_, rest, ok := strings.Cut(msg.Body, "token=")
+	if !ok {
+		t.Fatalf("no verification link in %q", msg.Body)
+	}
+	token, err := url.QueryUnescape(strings.Fields(rest)[0])
+	if err != nil {
+		t.Fatalf("unescape token: %v", err)
+	}
+	return token
+}
+
+func mustVerificationService(t *testing.T, repo repository.UserRepository, mail mailer.Mailer, cfg EmailVerificationConfig) EmailVerificationService {
+	t.Helper()
+
+	svc, err := NewEmailVerificationService(repo, mail, nil, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func TestEmailVerificationRequiresSecret(t *testing.T) {
+	_, err := NewEmailVerificationService(&memoryUserRepo{}, mailer.NewMemoryMailer(), nil, EmailVerificationConfig{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err == nil {
+		t.Fatal("service started without a secret")
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	user := &models.User{Model: gorm.Model{ID: 7}, Name: "Ann", Email: "ann@example.com"}
+	repo := &memoryUserRepo{users: map[uint]*models.User{user.ID: user}}
+	mail := mailer.NewMemoryMailer()
+
+	svc := mustVerificationService(t, repo, mail, EmailVerificationConfig{
+		BaseURL: "https://books.example.com",
+		Secret:  []byte("test-secret"),
+	})
+
+	if err := svc.Send(user); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	sent := mail.Messages()
+	if len(sent) != 1 || sent[0].To != user.Email {
+		t.Fatalf("expected one mail to %s, got %+v", user.Email, sent)
+	}
+	if !strings.Contains(sent[0].Body, "https://books.example.com/users/verify?token=") {
+		t.Fatalf("link does not use the base URL: %q", sent[0].Body)
+	}
+	token := tokenFromMail(t, sent[0])
+
+	otherSecret := mustVerificationService(t, repo, mail, EmailVerificationConfig{Secret: []byte("other")})
+	expired := mustVerificationService(t, repo, mail, EmailVerificationConfig{Secret: []byte("test-secret")}).(*emailVerificationService)
+
+	cases := []struct {
+		name  string
+		svc   EmailVerificationService
+		token string
+		want  error
+	}{
+		{"empty", svc, "", dto.ErrVerificationTokenInvalid},
+		{"garbage", svc, "abc.def", dto.ErrVerificationTokenInvalid},
+		{"tampered signature", svc, token[:len(token)-2] + "AA", dto.ErrVerificationTokenInvalid},
+		{"other secret", otherSecret, token, dto.ErrVerificationTokenInvalid},
+		{"expired", svc, expired.sign(user.ID, user.Email, time.Now().Add(-time.Minute)), dto.ErrVerificationTokenInvalid},
+		{"old email", svc, expired.sign(user.ID, "old@example.com", time.Now().Add(time.Hour)), dto.ErrVerificationTokenInvalid},
+		{"unknown user", svc, expired.sign(99, user.Email, time.Now().Add(time.Hour)), dto.ErrVerificationTokenInvalid},
+		{"valid", svc, token, nil},
+		{"valid twice", svc, token, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.svc.Verify(tc.token)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+
+	if !repo.users[user.ID].EmailVerified {
+		t.Fatal("user is not verified after a valid token")
+	}
+	if err := svc.Resend(user.ID); !errors.Is(err, dto.ErrEmailAlreadyVerified) {
+		t.Fatalf("expected ErrEmailAlreadyVerified on resend, got %v", err)
+	}
+}
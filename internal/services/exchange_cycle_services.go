@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+)
+
+type ExchangeCycleService interface {
+	RunMatcher() (int, error)
+	StartMatcher(ctx context.Context, interval time.Duration)
+	GetSuggestions(userID uint) ([]models.ExchangeCycle, error)
+	AcceptCycle(cycleID uint, userID uint) (*models.ExchangeCycle, error)
+	DeclineCycle(cycleID uint, userID uint) error
+	CancelCycle(cycleID uint, userID uint) error
+	CompleteLeg(cycleID uint, legID uint, userID uint) (*models.ExchangeCycle, error)
+}
+
+type exchangeCycleService struct {
+	cycleRepo      repository.ExchangeCycleRepository
+	maxCycleLength int
+	log            *slog.Logger
+}
+
+func NewExchangeCycleService(cycleRepo repository.ExchangeCycleRepository, maxCycleLength int, log *slog.Logger) ExchangeCycleService {
+	if maxCycleLength < dto.MinCycleLength {
+		maxCycleLength = dto.DefaultMaxCycleLength
+	}
+	return &exchangeCycleService{cycleRepo: cycleRepo, maxCycleLength: maxCycleLength, log: log}
+}
+
+// RunMatcher looks for trade cycles in the wish graph and stores them as
+// proposed exchange cycles. It returns the number of proposed cycles.
+func (s *exchangeCycleService) RunMatcher() (int, error) {
+	edges, err := s.cycleRepo.ListWishEdges()
+	if err != nil {
+		return 0, err
+	}
+
+	cancelled, err := s.cycleRepo.ListCancelledSince(time.Now().Add(-dto.CycleProposalCooldown))
+	if err != nil {
+		return 0, err
+	}
+	blocked := make(map[string]bool, len(cancelled))
+	for _, cycle := range cancelled {
+		givers := make([]uint, 0, len(cycle.Legs))
+		books := make([]uint, 0, len(cycle.Legs))
+		for _, leg := range cycle.Legs {
+			givers = append(givers, leg.GiverID)
+			books = append(books, leg.BookID)
+		}
+		blocked[cycleKey(givers, books)] = true
+	}
+
+	found := findTradeCycles(edges, s.maxCycleLength, dto.MaxCyclesPerRun, blocked)
+	if len(found) == 0 {
+		return 0, nil
+	}
+
+	cycles := make([]models.ExchangeCycle, 0, len(found))
+	for _, path := range found {
+		cycle := models.ExchangeCycle{Status: "proposed"}
+		for i, edge := range path {
+			cycle.Legs = append(cycle.Legs, models.ExchangeCycleLeg{
+				Position:   i,
+				GiverID:    edge.OwnerID,
+				ReceiverID: edge.WisherID,
+				BookID:     edge.BookID,
+			})
+		}
+		cycles = append(cycles, cycle)
+	}
+
+	if err := s.cycleRepo.CreateCycles(cycles); err != nil {
+		return 0, err
+	}
+
+	return len(cycles), nil
+}
+
+// StartMatcher runs the matcher every interval until ctx is cancelled.
+func (s *exchangeCycleService) StartMatcher(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	t := time.NewTicker(interval)
+
+	go func() {
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				proposed, err := s.RunMatcher()
+				if err != nil {
+					s.log.Error("exchange cycle matcher failed", "error", err)
+					continue
+				}
+				if proposed > 0 {
+					s.log.Info("exchange cycles proposed", "count", proposed)
+				}
+			}
+		}
+	}()
+}
+
+// findTradeCycles returns disjoint cycles of wishes where every user gets a
+// book from the next user and gives one to the previous user. Cycles are
+// searched from the lowest user ID so every cycle is found only once, and a
+// user takes part in at most one cycle per run. Cycles whose cycleKey is in
+// blocked are skipped.
+func findTradeCycles(edges []repository.WishEdge, maxLength, maxCycles int, blocked map[string]bool) [][]repository.WishEdge {
+	graph := make(map[uint][]repository.WishEdge)
+	for _, edge := range edges {
+		graph[edge.WisherID] = append(graph[edge.WisherID], edge)
+	}
+
+	users := make([]uint, 0, len(graph))
+	for userID := range graph {
+		users = append(users, userID)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i] < users[j] })
+
+	used := make(map[uint]bool)
+	var cycles [][]repository.WishEdge
+
+	var search func(start uint, path []repository.WishEdge, onPath map[uint]bool) []repository.WishEdge
+	search = func(start uint, path []repository.WishEdge, onPath map[uint]bool) []repository.WishEdge {
+		current := start
+		if len(path) > 0 {
+			current = path[len(path)-1].OwnerID
+		}
+
+		for _, edge := range graph[current] {
+			next := edge.OwnerID
+			if next == start && len(path)+1 >= dto.MinCycleLength {
+				cycle := append(append([]repository.WishEdge(nil), path...), edge)
+				if !blocked[edgesKey(cycle)] {
+					return cycle
+				}
+				continue
+			}
+			if next <= start || onPath[next] || used[next] || len(path)+1 >= maxLength {
+				continue
+			}
+
+			onPath[next] = true
+			if cycle := search(start, append(path, edge), onPath); cycle != nil {
+				return cycle
+			}
+			delete(onPath, next)
+		}
+		return nil
+	}
+
+	for _, start := range users {
+		if len(cycles) >= maxCycles {
+			break
+		}
+		if used[start] {
+			continue
+		}
+
+		cycle := search(start, nil, map[uint]bool{start: true})
+		if cycle == nil {
+			continue
+		}
+
+		for _, edge := range cycle {
+			used[edge.WisherID] = true
+		}
+		cycles = append(cycles, cycle)
+	}
+
+	return cycles
+}
+
+// cycleKey identifies a cycle by its participants and books, independent of
+// the order of the legs.
+func cycleKey(givers, books []uint) string {
+	givers = slices.Clone(givers)
+	books = slices.Clone(books)
+	slices.Sort(givers)
+	slices.Sort(books)
+	return fmt.Sprint(givers, books)
+}
+
+func edgesKey(cycle []repository.WishEdge) string {
+	givers := make([]uint, 0, len(cycle))
+	books := make([]uint, 0, len(cycle))
+	for _, edge := range cycle {
+		givers = append(givers, edge.OwnerID)
+		books = append(books, edge.BookID)
+	}
+	return cycleKey(givers, books)
+}
+
+func (s *exchangeCycleService) GetSuggestions(userID uint) ([]models.ExchangeCycle, error) {
+	if userID == 0 {
+		return nil, dto.ErrUnauthorized
+	}
+	return s.cycleRepo.ListByUser(userID, []string{"proposed", "accepted"})
+}
+
+func (s *exchangeCycleService) AcceptCycle(cycleID uint, userID uint) (*models.ExchangeCycle, error) {
+	cycle, err := s.getParticipantCycle(cycleID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cycle.Status != "proposed" {
+		return nil, dto.ErrCycleNotProposed
+	}
+
+	for _, leg := range cycle.Legs {
+		if leg.GiverID == userID && leg.AcceptedAt != nil {
+			return nil, dto.ErrCycleAlreadyDone
+		}
+	}
+
+	if err := s.cycleRepo.AcceptLeg(cycle, userID); err != nil {
+		return nil, err
+	}
+
+	return cycle, nil
+}
+
+func (s *exchangeCycleService) DeclineCycle(cycleID uint, userID uint) error {
+	cycle, err := s.getParticipantCycle(cycleID, userID)
+	if err != nil {
+		return err
+	}
+
+	if cycle.Status != "proposed" {
+		return dto.ErrCycleNotProposed
+	}
+
+	return s.cycleRepo.Cancel(cycle)
+}
+
+// CancelCycle lets any participant give up an accepted cycle, e.g. when a
+// giver never hands over their book. The books of legs that were not
+// completed yet become available again.
+func (s *exchangeCycleService) CancelCycle(cycleID uint, userID uint) error {
+	cycle, err := s.getParticipantCycle(cycleID, userID)
+	if err != nil {
+		return err
+	}
+
+	if cycle.Status != "accepted" {
+		return dto.ErrCycleNotAccepted
+	}
+
+	return s.cycleRepo.Cancel(cycle)
+}
+
+func (s *exchangeCycleService) CompleteLeg(cycleID uint, legID uint, userID uint) (*models.ExchangeCycle, error) {
+	cycle, err := s.getParticipantCycle(cycleID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cycle.Status != "accepted" {
+		return nil, dto.ErrCycleNotAccepted
+	}
+
+	var leg *models.ExchangeCycleLeg
+	for i := range cycle.Legs {
+		if cycle.Legs[i].ID == legID {
+			leg = &cycle.Legs[i]
+		}
+	}
+	if leg == nil {
+		return nil, dto.ErrCycleLegNotFound
+	}
+
+	if leg.ReceiverID != userID {
+		return nil, dto.ErrCycleNotReceiver
+	}
+
+	if leg.CompletedAt != nil {
+		return nil, dto.ErrCycleAlreadyDone
+	}
+
+	if err := s.cycleRepo.CompleteLeg(cycle, legID); err != nil {
+		return nil, err
+	}
+
+	return cycle, nil
+}
+
+func (s *exchangeCycleService) getParticipantCycle(cycleID uint, userID uint) (*models.ExchangeCycle, error) {
+	if userID == 0 {
+		return nil, dto.ErrUnauthorized
+	}
+
+	cycle, err := s.cycleRepo.GetByID(cycleID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, leg := range cycle.Legs {
+		if leg.GiverID == userID || leg.ReceiverID == userID {
+			return cycle, nil
+		}
+	}
+
+	s.log.Error("error in getParticipantCycle function exchange_cycle_services.go", "error", dto.ErrCycleNotParticipant, "user_id", userID)
+	return nil, dto.ErrCycleNotParticipant
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+)
+
+type memoryCycleRepo struct {
+	repository.ExchangeCycleRepository
+	cycles    map[uint]*models.ExchangeCycle
+	cancelled []uint
+}
+
+func (r *memoryCycleRepo) GetByID(id uint) (*models.ExchangeCycle, error) {
+	cycle, ok := r.cycles[id]
+	if !ok {
+		return nil, dto.ErrCycleNotFound
+	}
+	copied := *cycle
+	return &copied, nil
+}
+
+func (r *memoryCycleRepo) Cancel(cycle *models.ExchangeCycle) error {
+	r.cancelled = append(r.cancelled, cycle.ID)
+	return nil
+}
+
+func TestFindTradeCyclesSkipsBlocked(t *testing.T) {
+	// users 1 and 2 want each other's books; user 2 owns two wanted books
+	edges := []repository.WishEdge{
+		{WisherID: 1, OwnerID: 2, BookID: 20},
+		{WisherID: 1, OwnerID: 2, BookID: 21},
+		{WisherID: 2, OwnerID: 1, BookID: 10},
+	}
+
+	cycles := findTradeCycles(edges, 4, 10, nil)
+	if len(cycles) != 1 || edgesKey(cycles[0]) != cycleKey([]uint{1, 2}, []uint{10, 20}) {
+		t.Fatalf("unexpected cycles %+v", cycles)
+	}
+
+	blocked := map[string]bool{cycleKey([]uint{2, 1}, []uint{20, 10}): true}
+	cycles = findTradeCycles(edges, 4, 10, blocked)
+	if len(cycles) != 1 || edgesKey(cycles[0]) != cycleKey([]uint{1, 2}, []uint{10, 21}) {
+		t.Fatalf("blocked cycle proposed again: %+v", cycles)
+	}
+
+	blocked[cycleKey([]uint{1, 2}, []uint{10, 21})] = true
+	if cycles := findTradeCycles(edges, 4, 10, blocked); len(cycles) != 0 {
+		t.Fatalf("unexpected cycles %+v", cycles)
+	}
+}
+
+func TestCancelAcceptedCycle(t *testing.T) {
+	legs := []models.ExchangeCycleLeg{
+		{GiverID: 1, ReceiverID: 2, BookID: 10},
+		{GiverID: 2, ReceiverID: 1, BookID: 20},
+	}
+	accepted := &models.ExchangeCycle{Status: "accepted", Legs: legs}
+	accepted.ID = 1
+	proposed := &models.ExchangeCycle{Status: "proposed", Legs: legs}
+	proposed.ID = 2
+	repo := &memoryCycleRepo{cycles: map[uint]*models.ExchangeCycle{1: accepted, 2: proposed}}
+	svc := NewExchangeCycleService(repo, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if err := svc.CancelCycle(1, 3); !errors.Is(err, dto.ErrCycleNotParticipant) {
+		t.Fatalf("got %v, want ErrCycleNotParticipant", err)
+	}
+	if err := svc.CancelCycle(2, 1); !errors.Is(err, dto.ErrCycleNotAccepted) {
+		t.Fatalf("got %v, want ErrCycleNotAccepted", err)
+	}
+	if err := svc.CancelCycle(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.cancelled) != 1 || repo.cancelled[0] != 1 {
+		t.Fatalf("cancelled cycles %v, want [1]", repo.cancelled)
+	}
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	expiryLockKey   = "jobs:exchange-expiry:lock"
+	expiryBatchSize = 500
+)
+
+// releaseLockScript deletes the lock only if it still belongs to this instance.
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type ExchangeExpiryConfig struct {
+	PendingTTL       time.Duration
+	FollowUpAfter    time.Duration
+	AutoConfirmAfter time.Duration
+	Interval         time.Duration
+	LockTTL          time.Duration
+}
+
+type ExchangeExpiryResult struct {
+	Expired       int
+	Flagged       int
+	AutoCompleted int
+}
+
+// ExchangeExpiryJob cancels stale pending exchanges, completes handovers that
+// only one side confirmed and flags accepted exchanges that were never
+// completed. A Redis lock makes sure only one instance runs it at a time.
+type ExchangeExpiryJob struct {
+	exchangeRepo repository.ExchangeRepository
+	rdb          *redis.Client
+	cfg          ExchangeExpiryConfig
+	log          *slog.Logger
+}
+
+func NewExchangeExpiryJob(exchangeRepo repository.ExchangeRepository, rdb *redis.Client, cfg ExchangeExpiryConfig, log *slog.Logger) *ExchangeExpiryJob {
+	if cfg.PendingTTL <= 0 {
+		cfg.PendingTTL = 7 * 24 * time.Hour
+	}
+	if cfg.FollowUpAfter <= 0 {
+		cfg.FollowUpAfter = 14 * 24 * time.Hour
+	}
+	if cfg.AutoConfirmAfter <= 0 {
+		cfg.AutoConfirmAfter = 7 * 24 * time.Hour
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 15 * time.Minute
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = cfg.Interval
+	}
+
+	return &ExchangeExpiryJob{
+		exchangeRepo: exchangeRepo,
+		rdb:          rdb,
+		cfg:          cfg,
+		log:          log,
+	}
+}
+
+// Start runs the job every configured interval until ctx is cancelled.
+func (j *ExchangeExpiryJob) Start(ctx context.Context) {
+	t := time.NewTicker(j.cfg.Interval)
+
+	go func() {
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				j.RunLocked(ctx)
+			}
+		}
+	}()
+}
+
+// RunLocked runs the job if no other instance holds the lock.
+func (j *ExchangeExpiryJob) RunLocked(ctx context.Context) {
+	if j.rdb == nil {
+		return
+	}
+
+	token := newLockToken()
+
+	lockCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	acquired, err := j.rdb.SetNX(lockCtx, expiryLockKey, token, j.cfg.LockTTL).Result()
+	cancel()
+	if err != nil {
+		j.log.Warn("exchange expiry lock failed", "error", err)
+		return
+	}
+	if !acquired {
+		j.log.Info("exchange expiry skipped, lock is held by another instance")
+		return
+	}
+
+	defer func() {
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		if err := releaseLockScript.Run(unlockCtx, j.rdb, []string{expiryLockKey}, token).Err(); err != nil {
+			j.log.Warn("exchange expiry unlock failed", "error", err)
+		}
+	}()
+
+	res, err := j.Run()
+	if err != nil {
+		j.log.Error("exchange expiry failed", "error", err, "expired", res.Expired, "flagged", res.Flagged, "auto_completed", res.AutoCompleted)
+		return
+	}
+
+	if res.Expired > 0 || res.Flagged > 0 || res.AutoCompleted > 0 {
+		j.log.Info("exchange expiry finished", "expired", res.Expired, "flagged", res.Flagged, "auto_completed", res.AutoCompleted)
+	}
+}
+
+// Run cancels pending exchanges older than PendingTTL with the reason
+// "expired", confirms the silent side of handovers older than
+// AutoConfirmAfter and flags accepted exchanges older than FollowUpAfter.
+func (j *ExchangeExpiryJob) Run() (ExchangeExpiryResult, error) {
+	var res ExchangeExpiryResult
+	now := time.Now()
+
+	pending, err := j.exchangeRepo.ListStale("pending", now.Add(-j.cfg.PendingTTL), expiryBatchSize)
+	if err != nil {
+		return res, err
+	}
+
+	for i := range pending {
+		err := j.exchangeRepo.CancelExchange(&pending[i], dto.ExchangeEventMeta{Reason: "expired"})
+		if errors.Is(err, dto.ErrExchangeNotPending) {
+			// accepted or cancelled since it was listed
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		res.Expired++
+	}
+
+	halfConfirmed, err := j.exchangeRepo.ListHalfConfirmed(now.Add(-j.cfg.AutoConfirmAfter), expiryBatchSize)
+	if err != nil {
+		return res, err
+	}
+
+	for i := range halfConfirmed {
+		side := "recipient"
+		if halfConfirmed[i].InitiatorConfirmedAt == nil {
+			side = "initiator"
+		}
+		err := j.exchangeRepo.ConfirmHandover(&halfConfirmed[i], side, now, dto.ExchangeEventMeta{Reason: "auto_confirmed"})
+		if errors.Is(err, dto.ErrExchangeAlreadyConfirmed) || errors.Is(err, dto.ErrExchangeNotAccepted) {
+			// the participant confirmed or the exchange moved on since it was listed
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		res.AutoCompleted++
+	}
+
+	accepted, err := j.exchangeRepo.ListStale("accepted", now.Add(-j.cfg.FollowUpAfter), expiryBatchSize)
+	if err != nil {
+		return res, err
+	}
+
+	ids := make([]uint, 0, len(accepted))
+	for _, exchange := range accepted {
+		ids = append(ids, exchange.ID)
+	}
+
+	if err := j.exchangeRepo.FlagForFollowUp(ids, now); err != nil {
+		return res, err
+	}
+	res.Flagged = len(ids)
+
+	return res, nil
+}
+
+func newLockToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/authz"
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+)
+
+type ExchangeMessageService interface {
+	Send(exchangeID uint, userID uint, req dto.CreateExchangeMessageRequest) (*models.ExchangeMessage, error)
+	List(exchangeID uint, userID uint, query *dto.ExchangeMessageListQuery) ([]models.ExchangeMessage, int64, error)
+}
+
+type exchangeMessageService struct {
+	exchangeRepo repository.ExchangeRepository
+	messageRepo  repository.ExchangeMessageRepository
+	authorizer   authz.Authorizer
+	log          *slog.Logger
+}
+
+func NewExchangeMessageService(exchangeRepo repository.ExchangeRepository, messageRepo repository.ExchangeMessageRepository, authorizer authz.Authorizer, log *slog.Logger) ExchangeMessageService {
+	return &exchangeMessageService{exchangeRepo: exchangeRepo, messageRepo: messageRepo, authorizer: authorizer, log: log}
+}
+
+func (s *exchangeMessageService) Send(exchangeID uint, userID uint, req dto.CreateExchangeMessageRequest) (*models.ExchangeMessage, error) {
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		return nil, dto.ErrMessageTextRequired
+	}
+	if len([]rune(text)) > dto.MaxExchangeMessageLength {
+		return nil, dto.ErrMessageTextLength
+	}
+
+	exchange, err := s.getParticipantExchange(exchangeID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the thread is frozen once the exchange is over
+	switch exchange.Status {
+	case "cancelled", "declined", "completed", "reversed":
+		return nil, dto.ErrMessageThreadFrozen
+	}
+
+	message := &models.ExchangeMessage{
+		ExchangeID: exchange.ID,
+		SenderID:   userID,
+		Text:       text,
+	}
+	if err := s.messageRepo.Create(message); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// List returns one page of the thread and marks the received messages on it
+// as read. Page and limit of query are normalized in place.
+func (s *exchangeMessageService) List(exchangeID uint, userID uint, query *dto.ExchangeMessageListQuery) ([]models.ExchangeMessage, int64, error) {
+	exchange, err := s.getParticipantExchange(exchangeID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if query.Page <= 0 {
+		query.Page = dto.DefaultPage
+	}
+	if query.Limit <= 0 {
+		query.Limit = dto.DefaultMessageLimit
+	}
+	if query.Limit > dto.MaxMessageLimit {
+		query.Limit = dto.MaxMessageLimit
+	}
+
+	messages, total, err := s.messageRepo.ListByExchange(exchange.ID, query.Limit, (query.Page-1)*query.Limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	var unread []uint
+	for i := range messages {
+		if messages[i].SenderID != userID && messages[i].ReadAt == nil {
+			unread = append(unread, messages[i].ID)
+			messages[i].ReadAt = &now
+		}
+	}
+	if err := s.messageRepo.MarkRead(exchange.ID, userID, unread); err != nil {
+		return nil, 0, err
+	}
+
+	return messages, total, nil
+}
+
+func (s *exchangeMessageService) getParticipantExchange(exchangeID uint, userID uint) (*models.Exchange, error) {
+	return participantExchange(s.exchangeRepo, s.authorizer, s.log, exchangeID, userID)
+}
+
+// participantExchange loads the exchange and checks that userID takes part in it.
+func participantExchange(exchangeRepo repository.ExchangeRepository, authorizer authz.Authorizer, log *slog.Logger, exchangeID uint, userID uint) (*models.Exchange, error) {
+	if userID == 0 {
+		return nil, dto.ErrUnauthorized
+	}
+
+	if exchangeID == 0 {
+		return nil, dto.ErrExchangeInvalidID
+	}
+
+	exchange, err := exchangeRepo.GetByID(exchangeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authorizer.Can(authz.Actor{UserID: userID}, authz.ActionParticipate, exchange); err != nil {
+		log.Error("error in participantExchange function exchange_message_services.go", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	return exchange, nil
+}
@@ -3,32 +3,45 @@ package services
 import (
 	"errors"
 	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/dasler-fw/bookcrossing/internal/authz"
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/dasler-fw/bookcrossing/internal/repository"
 )
 
 type ExchangeService interface {
-	CreateExchange(req *dto.CreateExchangeRequest) (*models.Exchange, error)
-	AcceptExchange(exchangeID uint) error
-	CompleteExchange(exchangeID uint) error
-	CancelExchange(exchangeID uint) error
-	GetByID(exchangeID uint) (*models.Exchange, error)
-	GetAll() ([]models.Exchange, error)
+	CreateExchange(userID uint, req *dto.CreateExchangeRequest, requestID string) (*models.Exchange, error)
+	AcceptExchange(exchangeID uint, userID uint, requestID string) error
+	CompleteExchange(exchangeID uint, userID uint, requestID string) (*models.Exchange, error)
+	CancelExchange(exchangeID uint, userID uint, requestID string) error
+	DeclineExchange(exchangeID uint, userID uint, reason string, requestID string) error
+	CounterExchange(exchangeID uint, userID uint, req *dto.CounterExchangeRequest) (*models.Exchange, error)
+	GetByID(exchangeID uint, actor authz.Actor) (*models.Exchange, error)
+	GetEvents(exchangeID uint, actor authz.Actor) ([]models.ExchangeEvent, error)
+	ListExchanges(query *dto.ExchangeListQuery) ([]models.Exchange, string, error)
 }
 
 type exchangeService struct {
 	exchangeRepo repository.ExchangeRepository
 	bookRepo     repository.BookRepository
+	userRepo     repository.UserRepository
+	authorizer   authz.Authorizer
 	log          *slog.Logger
 }
 
-func NewExchangeService(exchangeRepo repository.ExchangeRepository, bookRepo repository.BookRepository, log *slog.Logger) ExchangeService {
-	return &exchangeService{exchangeRepo: exchangeRepo, bookRepo: bookRepo, log: log}
+func NewExchangeService(exchangeRepo repository.ExchangeRepository, bookRepo repository.BookRepository, userRepo repository.UserRepository, authorizer authz.Authorizer, log *slog.Logger) ExchangeService {
+	return &exchangeService{exchangeRepo: exchangeRepo, bookRepo: bookRepo, userRepo: userRepo, authorizer: authorizer, log: log}
 }
 
-func (s *exchangeService) CancelExchange(exchangeID uint) error {
+func (s *exchangeService) CancelExchange(exchangeID uint, userID uint, requestID string) error {
+	if userID == 0 {
+		s.log.Error("error in CancelExchange function exchange_services.go", "error", dto.ErrUnauthorized)
+		return dto.ErrUnauthorized
+	}
+
 	if exchangeID == 0 {
 		s.log.Error("error in CancelExchange function exchange_services.go")
 		return dto.ErrExchangeInvalidID
@@ -40,39 +53,115 @@ func (s *exchangeService) CancelExchange(exchangeID uint) error {
 		return err
 	}
 
-	// todo: check if the current user is the initiator, after the auth handler is implemented
+	if err := s.authorizer.Can(authz.Actor{UserID: userID}, authz.ActionCancel, exchange); err != nil {
+		s.log.Error("error in CancelExchange function exchange_services.go", "error", err, "user_id", userID)
+		return err
+	}
 
 	if exchange.Status != "pending" {
 		s.log.Error("error in CancelExchange function exchange_services.go", "error", errors.New("exchange is not pending"))
 		return dto.ErrExchangeNotPending
 	}
 
-	return s.exchangeRepo.CancelExchange(exchange)
+	return s.exchangeRepo.CancelExchange(exchange, dto.ExchangeEventMeta{ActorID: userID, RequestID: requestID})
 }
 
-func (s *exchangeService) CompleteExchange(exchangeID uint) error {
+func (s *exchangeService) DeclineExchange(exchangeID uint, userID uint, reason string, requestID string) error {
+	if userID == 0 {
+		s.log.Error("error in DeclineExchange function exchange_services.go", "error", dto.ErrUnauthorized)
+		return dto.ErrUnauthorized
+	}
+
 	if exchangeID == 0 {
-		s.log.Error("error in CompleteExchange function exchange_services.go")
+		s.log.Error("error in DeclineExchange function exchange_services.go")
 		return dto.ErrExchangeInvalidID
 	}
 
+	reason = strings.TrimSpace(reason)
+	if len([]rune(reason)) > dto.MaxDeclineReasonLength {
+		s.log.Error("error in DeclineExchange function exchange_services.go", "error", dto.ErrDeclineReasonLength)
+		return dto.ErrDeclineReasonLength
+	}
+
 	exchange, err := s.exchangeRepo.GetByID(exchangeID)
 	if err != nil {
-		s.log.Error("error in CompleteExchange function exchange_services.go", "error", err)
+		s.log.Error("error in DeclineExchange function exchange_services.go", "error", err)
 		return err
 	}
 
-	// todo: check if the current user is the initiator or the recipient, after the auth handler is implemented
+	if err := s.authorizer.Can(authz.Actor{UserID: userID}, authz.ActionDecline, exchange); err != nil {
+		s.log.Error("error in DeclineExchange function exchange_services.go", "error", err, "user_id", userID)
+		return err
+	}
+
+	if exchange.Status != "pending" {
+		s.log.Error("error in DeclineExchange function exchange_services.go", "error", errors.New("exchange is not pending"))
+		return dto.ErrExchangeNotPending
+	}
+
+	return s.exchangeRepo.DeclineExchange(exchange, dto.ExchangeEventMeta{ActorID: userID, RequestID: requestID, Reason: reason})
+}
+
+// CompleteExchange confirms that the user received the books. Ownership is
+// transferred once both participants have confirmed the handover.
+func (s *exchangeService) CompleteExchange(exchangeID uint, userID uint, requestID string) (*models.Exchange, error) {
+	if userID == 0 {
+		s.log.Error("error in CompleteExchange function exchange_services.go", "error", dto.ErrUnauthorized)
+		return nil, dto.ErrUnauthorized
+	}
+
+	if exchangeID == 0 {
+		s.log.Error("error in CompleteExchange function exchange_services.go")
+		return nil, dto.ErrExchangeInvalidID
+	}
+
+	exchange, err := s.exchangeRepo.GetByID(exchangeID)
+	if err != nil {
+		s.log.Error("error in CompleteExchange function exchange_services.go", "error", err)
+		return nil, err
+	}
+
+	if err := s.authorizer.Can(authz.Actor{UserID: userID}, authz.ActionComplete, exchange); err != nil {
+		s.log.Error("error in CompleteExchange function exchange_services.go", "error", err, "user_id", userID)
+		return nil, err
+	}
 
 	if exchange.Status != "accepted" {
 		s.log.Error("error in CompleteExchange function exchange_services.go", "error", errors.New("exchange is not accepted"))
-		return dto.ErrExchangeNotAccepted
+		return nil, dto.ErrExchangeNotAccepted
+	}
+
+	if exchange.DeliveryMethod == "postal" {
+		s.log.Error("error in CompleteExchange function exchange_services.go", "error", dto.ErrExchangePostalComplete)
+		return nil, dto.ErrExchangePostalComplete
+	}
+
+	side := "recipient"
+	confirmedAt := exchange.RecipientConfirmedAt
+	if exchange.InitiatorID == userID {
+		side = "initiator"
+		confirmedAt = exchange.InitiatorConfirmedAt
+	}
+
+	if confirmedAt != nil {
+		s.log.Error("error in CompleteExchange function exchange_services.go", "error", dto.ErrExchangeAlreadyConfirmed, "user_id", userID)
+		return nil, dto.ErrExchangeAlreadyConfirmed
 	}
 
-	return s.exchangeRepo.CompleteExchange(exchange)
+	meta := dto.ExchangeEventMeta{ActorID: userID, RequestID: requestID}
+	if err := s.exchangeRepo.ConfirmHandover(exchange, side, time.Now(), meta); err != nil {
+		return nil, err
+	}
+
+	return exchange, nil
 }
 
-func (s *exchangeService) AcceptExchange(exchangeID uint) error {
+func (s *exchangeService) AcceptExchange(exchangeID uint, userID uint, requestID string) error {
+	if userID == 0 {
+		s.log.Error("error in AcceptExchange function exchange_services.go", "error", dto.ErrUnauthorized)
+		return dto.ErrUnauthorized
+	}
+
 	if exchangeID == 0 {
 		s.log.Error("error in AcceptExchange function exchange_services.go")
 		return dto.ErrExchangeInvalidID
@@ -84,69 +173,227 @@ func (s *exchangeService) AcceptExchange(exchangeID uint) error {
 		return err
 	}
 
-	// todo: check if the current user is the recipient, after the auth handler is implemented
+	if err := s.authorizer.Can(authz.Actor{UserID: userID}, authz.ActionAccept, exchange); err != nil {
+		s.log.Error("error in AcceptExchange function exchange_services.go", "error", err, "user_id", userID)
+		return err
+	}
 
 	if exchange.Status != "pending" {
 		s.log.Error("error in AcceptExchange function exchange_services.go", "error", errors.New("exchange is not pending"))
 		return dto.ErrExchangeNotPending
 	}
 
-	exchange.Status = "accepted"
-	return s.exchangeRepo.Update(exchange)
+	return s.exchangeRepo.AcceptExchange(exchange, dto.ExchangeEventMeta{ActorID: userID, RequestID: requestID})
 }
 
-func (s *exchangeService) CreateExchange(req *dto.CreateExchangeRequest) (*models.Exchange, error) {
-	if req == nil {
-		s.log.Error("error in CreateExchange function exchange_services.go")
-		return nil, dto.ErrExchangeInvalidID
+func (s *exchangeService) CounterExchange(exchangeID uint, userID uint, req *dto.CounterExchangeRequest) (*models.Exchange, error) {
+	if userID == 0 {
+		s.log.Error("error in CounterExchange function exchange_services.go", "error", dto.ErrUnauthorized)
+		return nil, dto.ErrUnauthorized
 	}
 
-	exchange := &models.Exchange{
-		InitiatorID:     req.InitiatorID,
-		RecipientID:     req.RecipientID,
-		InitiatorBookID: req.InitiatorBookID,
-		RecipientBookID: req.RecipientBookID,
-		Status:          "pending",
+	if exchangeID == 0 || req == nil {
+		s.log.Error("error in CounterExchange function exchange_services.go")
+		return nil, dto.ErrExchangeInvalidID
 	}
 
-	initiatorBook, err := s.bookRepo.GetByID(req.InitiatorBookID)
+	exchange, err := s.exchangeRepo.GetByID(exchangeID)
 	if err != nil {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
+		s.log.Error("error in CounterExchange function exchange_services.go", "error", err)
 		return nil, err
 	}
 
-	recipientBook, err := s.bookRepo.GetByID(req.RecipientBookID)
-	if err != nil {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
+	if err := s.authorizer.Can(authz.Actor{UserID: userID}, authz.ActionCounter, exchange); err != nil {
+		s.log.Error("error in CounterExchange function exchange_services.go", "error", err, "user_id", userID)
 		return nil, err
 	}
 
-	if err := s.CheckIsTheSameUser(initiatorBook.UserID, recipientBook.UserID); err != nil {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
-		return nil, err
+	if exchange.Status != "pending" {
+		s.log.Error("error in CounterExchange function exchange_services.go", "error", errors.New("exchange is not pending"))
+		return nil, dto.ErrExchangeNotPending
 	}
 
-	if err := s.CheckInitiatorOwnsBook(req.InitiatorID, initiatorBook); err != nil {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
-		return nil, err
+	offer := &models.ExchangeOffer{
+		ProposerID:      userID,
+		InitiatorBookID: exchange.InitiatorBookID,
+		RecipientBookID: exchange.RecipientBookID,
+	}
+	if req.InitiatorBookID != 0 {
+		offer.InitiatorBookID = req.InitiatorBookID
+	}
+	if req.RecipientBookID != 0 {
+		offer.RecipientBookID = req.RecipientBookID
 	}
 
-	if err := s.CheckRecipientOwnsBook(req.RecipientID, recipientBook); err != nil {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
+	if offer.InitiatorBookID == exchange.InitiatorBookID && offer.RecipientBookID == exchange.RecipientBookID {
+		s.log.Error("error in CounterExchange function exchange_services.go", "error", dto.ErrExchangeCounterSame)
+		return nil, dto.ErrExchangeCounterSame
+	}
+
+	initiatorBooks, recipientBooks := exchange.BookIDs()
+	if (offer.InitiatorBookID != exchange.InitiatorBookID && len(initiatorBooks) > 1) ||
+		(offer.RecipientBookID != exchange.RecipientBookID && len(recipientBooks) > 1) {
+		s.log.Error("error in CounterExchange function exchange_services.go", "error", dto.ErrExchangeCounterBundle)
+		return nil, dto.ErrExchangeCounterBundle
+	}
+
+	if offer.InitiatorBookID != exchange.InitiatorBookID {
+		book, err := s.bookRepo.GetByID(offer.InitiatorBookID)
+		if err != nil {
+			s.log.Error("error in CounterExchange function exchange_services.go", "error", err)
+			return nil, err
+		}
+		if err := s.CheckInitiatorOwnsBook(exchange.InitiatorID, book); err != nil {
+			return nil, err
+		}
+		if book.Status != "available" {
+			s.log.Error("error in CounterExchange function exchange_services.go", "error", dto.ErrUnavailable)
+			return nil, dto.ErrUnavailable
+		}
+	}
+
+	if offer.RecipientBookID != exchange.RecipientBookID {
+		book, err := s.bookRepo.GetByID(offer.RecipientBookID)
+		if err != nil {
+			s.log.Error("error in CounterExchange function exchange_services.go", "error", err)
+			return nil, err
+		}
+		if err := s.CheckRecipientOwnsBook(exchange.RecipientID, book); err != nil {
+			return nil, err
+		}
+		if book.Status != "available" {
+			s.log.Error("error in CounterExchange function exchange_services.go", "error", dto.ErrRUnavailable)
+			return nil, dto.ErrRUnavailable
+		}
+	}
+
+	if err := s.exchangeRepo.CounterExchange(exchange, offer); err != nil {
 		return nil, err
 	}
 
-	if err := s.CheckIsAvailable(initiatorBook, recipientBook); err != nil {
+	return exchange, nil
+}
+
+func (s *exchangeService) CreateExchange(userID uint, req *dto.CreateExchangeRequest, requestID string) (*models.Exchange, error) {
+	if userID == 0 {
+		s.log.Error("error in CreateExchange function exchange_services.go", "error", dto.ErrUnauthorized)
+		return nil, dto.ErrUnauthorized
+	}
+
+	if req == nil {
+		s.log.Error("error in CreateExchange function exchange_services.go")
+		return nil, dto.ErrExchangeInvalidID
+	}
+
+	initiator, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		s.log.Error("error in CreateExchange function exchange_services.go", "error", err, "user_id", userID)
+		return nil, dto.ErrUnauthorized
+	}
+	if !initiator.EmailVerified {
+		s.log.Error("error in CreateExchange function exchange_services.go", "error", dto.ErrEmailNotVerified, "user_id", userID)
+		return nil, dto.ErrEmailNotVerified
+	}
+
+	initiatorBookIDs := collectBookIDs(req.InitiatorBookID, req.InitiatorBookIDs)
+	recipientBookIDs := collectBookIDs(req.RecipientBookID, req.RecipientBookIDs)
+
+	if len(initiatorBookIDs) == 0 || len(recipientBookIDs) == 0 {
+		s.log.Error("error in CreateExchange function exchange_services.go", "error", dto.ErrExchangeNoBooks)
+		return nil, dto.ErrExchangeNoBooks
+	}
+
+	if len(initiatorBookIDs) > dto.MaxExchangeItemsPerSide || len(recipientBookIDs) > dto.MaxExchangeItemsPerSide {
+		s.log.Error("error in CreateExchange function exchange_services.go", "error", dto.ErrExchangeTooManyItems)
+		return nil, dto.ErrExchangeTooManyItems
+	}
+
+	deliveryMethod := strings.TrimSpace(req.DeliveryMethod)
+	if deliveryMethod == "" {
+		deliveryMethod = "in_person"
+	}
+	if deliveryMethod != "in_person" && deliveryMethod != "postal" {
+		s.log.Error("error in CreateExchange function exchange_services.go", "error", dto.ErrExchangeDeliveryMethod)
+		return nil, dto.ErrExchangeDeliveryMethod
+	}
+
+	if err := s.CheckIsTheSameUser(userID, req.RecipientID); err != nil {
 		s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
 		return nil, err
 	}
-	if err := s.exchangeRepo.CreateExchange(exchange); err != nil {
+
+	exchange := &models.Exchange{
+		InitiatorID:     userID,
+		RecipientID:     req.RecipientID,
+		InitiatorBookID: initiatorBookIDs[0],
+		RecipientBookID: recipientBookIDs[0],
+		ProposerID:      userID,
+		DeliveryMethod:  deliveryMethod,
+		Status:          "pending",
+	}
+
+	for _, bookID := range initiatorBookIDs {
+		initiatorBook, err := s.bookRepo.GetByID(bookID)
+		if err != nil {
+			s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
+			return nil, err
+		}
+
+		if err := s.CheckInitiatorOwnsBook(userID, initiatorBook); err != nil {
+			s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
+			return nil, err
+		}
+
+		if initiatorBook.Status != "available" {
+			s.log.Error("error in CreateExchange function exchange_services.go", "error", dto.ErrUnavailable, "book_id", bookID)
+			return nil, dto.ErrUnavailable
+		}
+
+		exchange.Items = append(exchange.Items, models.ExchangeItem{BookID: bookID, Side: "initiator"})
+	}
+
+	for _, bookID := range recipientBookIDs {
+		recipientBook, err := s.bookRepo.GetByID(bookID)
+		if err != nil {
+			s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
+			return nil, err
+		}
+
+		if err := s.CheckRecipientOwnsBook(req.RecipientID, recipientBook); err != nil {
+			s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
+			return nil, err
+		}
+
+		if recipientBook.Status != "available" {
+			s.log.Error("error in CreateExchange function exchange_services.go", "error", dto.ErrRUnavailable, "book_id", bookID)
+			return nil, dto.ErrRUnavailable
+		}
+
+		exchange.Items = append(exchange.Items, models.ExchangeItem{BookID: bookID, Side: "recipient"})
+	}
+
+	if err := s.exchangeRepo.CreateExchange(exchange, dto.ExchangeEventMeta{ActorID: userID, RequestID: requestID}); err != nil {
 		return nil, err
 	}
 
 	return exchange, nil
 }
 
+// collectBookIDs merges the single book ID and the bundle list of one side,
+// dropping zero and duplicate IDs while keeping the request order.
+func collectBookIDs(single uint, bundle []uint) []uint {
+	seen := make(map[uint]bool, len(bundle)+1)
+	ids := make([]uint, 0, len(bundle)+1)
+	for _, id := range append([]uint{single}, bundle...) {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 func (s *exchangeService) CheckInitiatorOwnsBook(initiatorID uint, initiatorBook *models.Book) error {
 	if initiatorID != initiatorBook.UserID {
 		s.log.Error("error in CreateExchange function exchange_services.go", "error", errors.New("initiator does not own the book"))
@@ -166,8 +413,8 @@ func (s *exchangeService) CheckRecipientOwnsBook(recipientID uint, recipientBook
 }
 func (s *exchangeService) CheckIsTheSameUser(initiatorID uint, recipientID uint) error {
 	if initiatorID == recipientID {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", errors.New("initiator and recipient book cannot be the same user"))
-		return errors.New("initiator and recipient book cannot be the same user")
+		s.log.Error("error in CreateExchange function exchange_services.go", "error", dto.ErrExchangeSameUser)
+		return dto.ErrExchangeSameUser
 	}
 
 	return nil
@@ -187,10 +434,110 @@ func (s *exchangeService) CheckIsAvailable(initiatorBook *models.Book, recipient
 	return nil
 }
 
-func (s *exchangeService) GetByID(exchangeID uint) (*models.Exchange, error) {
-	return s.exchangeRepo.GetByID(exchangeID)
+func (s *exchangeService) GetByID(exchangeID uint, actor authz.Actor) (*models.Exchange, error) {
+	exchange, err := s.exchangeRepo.GetByID(exchangeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authorizer.Can(actor, authz.ActionRead, exchange); err != nil {
+		s.log.Error("error in GetByID function exchange_services.go", "error", err, "user_id", actor.UserID)
+		return nil, err
+	}
+
+	offers, err := s.exchangeRepo.GetOffers(exchange.ID)
+	if err != nil {
+		return nil, err
+	}
+	exchange.Offers = offers
+
+	return exchange, nil
+}
+
+// GetEvents returns the status history of the exchange to its participants
+// and to moderators.
+func (s *exchangeService) GetEvents(exchangeID uint, actor authz.Actor) ([]models.ExchangeEvent, error) {
+	exchange, err := s.exchangeRepo.GetByID(exchangeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authorizer.Can(actor, authz.ActionRead, exchange); err != nil {
+		s.log.Error("error in GetEvents function exchange_services.go", "error", err, "user_id", actor.UserID)
+		return nil, err
+	}
+
+	return s.exchangeRepo.ListEvents(exchange.ID)
 }
 
-func (s *exchangeService) GetAll() ([]models.Exchange, error) {
-	return s.exchangeRepo.GetAll()
+// ListExchanges returns one page of exchanges. The query is normalized in
+// place, so the caller sees the effective limit.
+func (s *exchangeService) ListExchanges(query *dto.ExchangeListQuery) ([]models.Exchange, string, error) {
+	normalized, err := normalizeExchangeListQuery(*query)
+	if err != nil {
+		s.log.Error("error in ListExchanges function exchange_services.go", "error", err)
+		return nil, "", err
+	}
+	*query = normalized
+
+	return s.exchangeRepo.List(normalized)
+}
+
+var exchangeStatuses = map[string]bool{
+	"pending":   true,
+	"accepted":  true,
+	"completed": true,
+	"cancelled": true,
+	"declined":  true,
+	"reversed":  true,
+}
+
+// normalizeExchangeListQuery validates the filters and applies the default
+// sort and page size.
+func normalizeExchangeListQuery(query dto.ExchangeListQuery) (dto.ExchangeListQuery, error) {
+	query.Role = strings.ToLower(strings.TrimSpace(query.Role))
+	if query.Role != "" && (query.ParticipantID == 0 || (query.Role != "initiator" && query.Role != "recipient")) {
+		return query, dto.ErrExchangeInvalidFilter
+	}
+
+	// status can be repeated or given as a comma separated list
+	statuses := make([]string, 0, len(query.Statuses))
+	for _, value := range query.Statuses {
+		for _, status := range strings.Split(value, ",") {
+			status = strings.ToLower(strings.TrimSpace(status))
+			if status == "" {
+				continue
+			}
+			if !exchangeStatuses[status] {
+				return query, dto.ErrExchangeInvalidFilter
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	query.Statuses = statuses
+
+	query.SortBy = strings.ToLower(strings.TrimSpace(query.SortBy))
+	if query.SortBy == "" {
+		query.SortBy = "created_at"
+	}
+	if query.SortBy != "created_at" && query.SortBy != "updated_at" {
+		return query, dto.ErrExchangeInvalidFilter
+	}
+
+	query.SortOrder = strings.ToLower(strings.TrimSpace(query.SortOrder))
+	if query.SortOrder == "" {
+		query.SortOrder = "desc"
+	}
+	if query.SortOrder != "asc" && query.SortOrder != "desc" {
+		return query, dto.ErrExchangeInvalidFilter
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = dto.DefaultExchangeListLimit
+	}
+	if query.Limit > dto.MaxExchangeListLimit {
+		query.Limit = dto.MaxExchangeListLimit
+	}
+
+	return query, nil
 }
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/mailer"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	loginGuardPrefix  = "login-guard:"
+	loginGuardTimeout = 200 * time.Millisecond
+)
+
+type LoginGuardConfig struct {
+	// Disabled turns the guard off, e.g. for load tests.
+	Disabled bool
+	// MaxAccountFailures locks an account, MaxIPFailures blocks a client
+	// address, both counted within Window.
+	MaxAccountFailures int
+	MaxIPFailures      int
+	Window             time.Duration
+	LockoutDuration    time.Duration
+	// After DelayAfter failures every further attempt on the account has to
+	// wait BaseDelay, doubled with each failure up to MaxDelay.
+	DelayAfter int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	// UnlockURL is the page that receives the unlock token as ?token=.
+	UnlockURL string
+}
+
+// LoginGuard throttles password logins with failure counters in Redis, per
+// account and per client IP. When Redis is unavailable it lets every attempt
+// through, so an outage does not lock everybody out.
+type LoginGuard interface {
+	Check(ip, email string) error
+	Failed(ip, email string, user *models.User)
+	Succeeded(ip, email string)
+	Unlock(token string) error
+}
+
+type loginGuard struct {
+	rdb    *redis.Client
+	mailer mailer.Mailer
+	cfg    LoginGuardConfig
+	log    *slog.Logger
+}
+
+func NewLoginGuard(rdb *redis.Client, m mailer.Mailer, cfg LoginGuardConfig, log *slog.Logger) LoginGuard {
+	if cfg.MaxAccountFailures <= 0 {
+		cfg.MaxAccountFailures = 10
+	}
+	if cfg.MaxIPFailures <= 0 {
+		cfg.MaxIPFailures = 100
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 15 * time.Minute
+	}
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = 30 * time.Minute
+	}
+	if cfg.DelayAfter <= 0 {
+		cfg.DelayAfter = 3
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = time.Second
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 30 * time.Second
+	}
+	if cfg.UnlockURL == "" {
+		cfg.UnlockURL = "http://localhost:8080/users/login/unlock"
+	}
+
+	return &loginGuard{
+		rdb:    rdb,
+		mailer: m,
+		cfg:    cfg,
+		log:    log,
+	}
+}
+
+// Check is called before the password is compared, so refused attempts do
+// not cost a bcrypt run.
+func (g *loginGuard) Check(ip, email string) error {
+	if g.cfg.Disabled || g.rdb == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), loginGuardTimeout)
+	defer cancel()
+
+	account := accountKey(email)
+
+	pipe := g.rdb.Pipeline()
+	accountLock := pipe.PTTL(ctx, loginGuardPrefix+"lock:"+account)
+	ipLock := pipe.PTTL(ctx, loginGuardPrefix+"lock:"+ipKey(ip))
+	delay := pipe.PTTL(ctx, loginGuardPrefix+"delay:"+account)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		g.log.Warn("login guard check failed, allowing attempt", "error", err)
+		return nil
+	}
+
+	if ttl := accountLock.Val(); ttl > 0 {
+		return &dto.LoginThrottledError{RetryAfter: ttl, Err: dto.ErrAccountLocked}
+	}
+	if ttl := ipLock.Val(); ttl > 0 {
+		return &dto.LoginThrottledError{RetryAfter: ttl, Err: dto.ErrLoginThrottled}
+	}
+	if ttl := delay.Val(); ttl > 0 {
+		return &dto.LoginThrottledError{RetryAfter: ttl, Err: dto.ErrLoginThrottled}
+	}
+
+	return nil
+}
+
+// Failed counts a failed attempt. user is nil when the email is unknown;
+// such attempts are counted the same way so responses do not reveal which
+// accounts exist, but no unlock email is sent.
+func (g *loginGuard) Failed(ip, email string, user *models.User) {
+	if g.cfg.Disabled || g.rdb == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), loginGuardTimeout)
+	defer cancel()
+
+	account := accountKey(email)
+	accountFails := loginGuardPrefix + "fail:" + account
+	ipFails := loginGuardPrefix + "fail:" + ipKey(ip)
+
+	pipe := g.rdb.TxPipeline()
+	accountCount := pipe.Incr(ctx, accountFails)
+	pipe.Expire(ctx, accountFails, g.cfg.Window)
+	ipCount := pipe.Incr(ctx, ipFails)
+	pipe.Expire(ctx, ipFails, g.cfg.Window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		g.log.Warn("login guard update failed", "error", err)
+		return
+	}
+
+	g.log.Warn("security event",
+		"event", "login_failed",
+		"ip", ip,
+		"account", account,
+		"user_id", userIDOf(user),
+		"account_failures", accountCount.Val(),
+		"ip_failures", ipCount.Val(),
+	)
+
+	if ipCount.Val() >= int64(g.cfg.MaxIPFailures) {
+		g.rdb.Set(ctx, loginGuardPrefix+"lock:"+ipKey(ip), 1, g.cfg.LockoutDuration)
+		g.rdb.Del(ctx, ipFails)
+		g.log.Warn("security event", "event", "ip_blocked", "ip", ip, "duration", g.cfg.LockoutDuration)
+	}
+
+	if accountCount.Val() >= int64(g.cfg.MaxAccountFailures) {
+		g.lock(ctx, account, accountFails, user)
+		return
+	}
+
+	if delay := g.delay(accountCount.Val()); delay > 0 {
+		g.rdb.Set(ctx, loginGuardPrefix+"delay:"+account, 1, delay)
+	}
+}
+
+// Succeeded resets the account counters. The IP counter is kept, a
+// credential stuffing run has occasional hits too.
+func (g *loginGuard) Succeeded(ip, email string) {
+	if g.cfg.Disabled || g.rdb == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), loginGuardTimeout)
+	defer cancel()
+
+	account := accountKey(email)
+	if err := g.rdb.Del(ctx, loginGuardPrefix+"fail:"+account, loginGuardPrefix+"delay:"+account).Err(); err != nil {
+		g.log.Warn("login guard reset failed", "error", err)
+	}
+}
+
+// Unlock lifts an account lockout with the token from the unlock email.
+func (g *loginGuard) Unlock(token string) error {
+	if token == "" || g.rdb == nil {
+		return dto.ErrUnlockTokenInvalid
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), loginGuardTimeout)
+	defer cancel()
+
+	account, err := g.rdb.GetDel(ctx, loginGuardPrefix+"unlock:"+hashToken(token)).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			g.log.Error("error in Unlock function login_guard.go", "error", err)
+		}
+		return dto.ErrUnlockTokenInvalid
+	}
+
+	if err := g.rdb.Del(ctx,
+		loginGuardPrefix+"lock:"+account,
+		loginGuardPrefix+"fail:"+account,
+		loginGuardPrefix+"delay:"+account,
+	).Err(); err != nil {
+		g.log.Error("error in Unlock function login_guard.go", "error", err)
+		return dto.ErrUnlockTokenInvalid
+	}
+
+	g.log.Info("security event", "event", "account_unlocked", "account", account)
+	return nil
+}
+
+func (g *loginGuard) lock(ctx context.Context, account, failsKey string, user *models.User) {
+	locked, err := g.rdb.SetNX(ctx, loginGuardPrefix+"lock:"+account, 1, g.cfg.LockoutDuration).Result()
+	if err != nil {
+		g.log.Warn("login guard lock failed", "error", err)
+		return
+	}
+	g.rdb.Del(ctx, failsKey, loginGuardPrefix+"delay:"+account)
+
+	// a concurrent request already locked the account and sent the email
+	if !locked {
+		return
+	}
+
+	g.log.Warn("security event", "event", "account_locked", "account", account, "user_id", userIDOf(user), "duration", g.cfg.LockoutDuration)
+
+	if user == nil {
+		return
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return
+	}
+	if err := g.rdb.Set(ctx, loginGuardPrefix+"unlock:"+hashToken(token), account, g.cfg.LockoutDuration).Err(); err != nil {
+		g.log.Warn("login guard unlock token failed", "error", err)
+		return
+	}
+
+	go g.sendUnlock(*user, token)
+}
+
+func (g *loginGuard) sendUnlock(user models.User, token string) {
+	link := g.cfg.UnlockURL + "?token=" + url.QueryEscape(token)
+
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+
+	err := g.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your account was locked",
+		Body: fmt.Sprintf("Hi %s,\n\nthere were too many failed login attempts on your account, so it is locked for %s. If it was you, open the link below to unlock it now:\n\n%s\n\nIf it was not you, consider changing your password.\n",
+			user.Name, g.cfg.LockoutDuration, link),
+	})
+	if err != nil {
+		g.log.Error("error in sendUnlock function login_guard.go", "error", err, "user_id", user.ID)
+	}
+}
+
+// delay is the wait before the next attempt after n failures.
+func (g *loginGuard) delay(n int64) time.Duration {
+	if n < int64(g.cfg.DelayAfter) {
+		return 0
+	}
+
+	d := g.cfg.BaseDelay
+	for i := int64(g.cfg.DelayAfter); i < n && d < g.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > g.cfg.MaxDelay {
+		d = g.cfg.MaxDelay
+	}
+	return d
+}
+
+// accountKey hashes the normalized email so addresses do not end up in
+// Redis keys or logs.
+func accountKey(email string) string {
+	return "account:" + hashToken(strings.ToLower(strings.TrimSpace(email)))[:32]
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func userIDOf(user *models.User) uint {
+	if user == nil {
+		return 0
+	}
+	return user.ID
+}
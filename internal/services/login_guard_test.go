@@ -0,0 +1,110 @@
+package services
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/mailer"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func TestLoginGuardDelay(t *testing.T) {
+	g := NewLoginGuard(nil, mailer.NewMemoryMailer(), LoginGuardConfig{
+		DelayAfter: 3,
+		BaseDelay:  time.Second,
+		MaxDelay:   5 * time.Second,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil))).(*loginGuard)
+
+	tests := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{1, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{6, 5 * time.Second},
+		{50, 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := g.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+// TestLoginGuardFailsOpen makes sure logins keep working when Redis is down.
+func TestLoginGuardFailsOpen(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rdb.Close()
+
+	g := NewLoginGuard(rdb, mailer.NewMemoryMailer(), LoginGuardConfig{MaxAccountFailures: 1}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for i := 0; i < 3; i++ {
+		g.Failed("10.0.0.1", "ann@example.com", &models.User{Email: "ann@example.com"})
+		if err := g.Check("10.0.0.1", "ann@example.com"); err != nil {
+			t.Fatalf("attempt %d refused while Redis is down: %v", i, err)
+		}
+	}
+}
+
+func TestAccountKeyNormalizesEmail(t *testing.T) {
+	if accountKey(" Ann@Example.com ") != accountKey("ann@example.com") {
+		t.Fatal("account key depends on case or whitespace")
+	}
+}
+
+type recordingLoginGuard struct {
+	LoginGuard
+	succeeded int
+}
+
+func (g *recordingLoginGuard) Check(ip, email string) error { return nil }
+func (g *recordingLoginGuard) Succeeded(ip, email string)   { g.succeeded++ }
+
+type enabledTwoFactor struct{ TwoFactorService }
+
+func (enabledTwoFactor) Enabled(uint) (bool, error) { return true, nil }
+func (enabledTwoFactor) Challenge(uint) (*dto.LoginResponse, error) {
+	return &dto.LoginResponse{MFARequired: true, MFAToken: "challenge"}, nil
+}
+
+type emailUserRepo struct{ memoryUserRepo }
+
+func (r *emailUserRepo) GetByEmail(email string) (*models.User, error) {
+	for _, u := range r.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, repository.ErrUserNotFound
+}
+
+// TestLoginKeepsFailuresUntilSecondFactor makes sure the password step alone
+// does not reset the counters that limit guessing the second factor.
+func TestLoginKeepsFailuresUntilSecondFactor(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+	user := &models.User{Model: gorm.Model{ID: 3}, Email: "ann@example.com", PasswordHash: string(hash)}
+	guard := &recordingLoginGuard{}
+	svc := NewServiceUser(nil, &emailUserRepo{memoryUserRepo{users: map[uint]*models.User{user.ID: user}}}, nil, nil, nil, nil, enabledTwoFactor{}, guard, nil, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+
+	resp, err := svc.Login(dto.LoginRequest{Email: user.Email, Password: "secret-password", ClientIP: "10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.MFARequired {
+		t.Fatal("no challenge for a two-factor account")
+	}
+	if guard.succeeded != 0 {
+		t.Fatal("failure counters reset before the second factor was verified")
+	}
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/authz"
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/ical"
+	"github.com/dasler-fw/bookcrossing/internal/mailer"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+)
+
+const (
+	reminderBatchSize = 100
+	// a reminder that fails reminderMaxAttempts times is given up; retries
+	// wait reminderRetryDelay, doubled after every failure
+	reminderMaxAttempts = 5
+	reminderRetryDelay  = time.Minute
+)
+
+type MeetupService interface {
+	Propose(exchangeID uint, userID uint, req dto.CreateMeetupRequest) (*models.Meetup, error)
+	List(exchangeID uint, userID uint) ([]models.Meetup, error)
+	Confirm(exchangeID uint, meetupID uint, userID uint, req dto.ConfirmMeetupRequest) (*models.Meetup, error)
+	ExportICS(exchangeID uint, userID uint) ([]byte, error)
+	DispatchReminders() (int, error)
+	StartReminderDispatcher(ctx context.Context, interval time.Duration)
+}
+
+type meetupService struct {
+	exchangeRepo   repository.ExchangeRepository
+	meetupRepo     repository.MeetupRepository
+	userRepo       repository.UserRepository
+	authorizer     authz.Authorizer
+	mailer         mailer.Mailer
+	reminderBefore time.Duration
+	log            *slog.Logger
+}
+
+func NewMeetupService(exchangeRepo repository.ExchangeRepository, meetupRepo repository.MeetupRepository, userRepo repository.UserRepository, authorizer authz.Authorizer, m mailer.Mailer, reminderBefore time.Duration, log *slog.Logger) MeetupService {
+	if reminderBefore <= 0 {
+		reminderBefore = 2 * time.Hour
+	}
+	return &meetupService{
+		exchangeRepo:   exchangeRepo,
+		meetupRepo:     meetupRepo,
+		userRepo:       userRepo,
+		authorizer:     authorizer,
+		mailer:         m,
+		reminderBefore: reminderBefore,
+		log:            log,
+	}
+}
+
+func (s *meetupService) Propose(exchangeID uint, userID uint, req dto.CreateMeetupRequest) (*models.Meetup, error) {
+	place := strings.TrimSpace(req.Place)
+	if place == "" {
+		return nil, dto.ErrMeetupPlaceRequired
+	}
+	if len([]rune(place)) > dto.MaxMeetupPlaceLength {
+		return nil, dto.ErrMeetupPlaceLength
+	}
+	if len(req.Slots) == 0 || len(req.Slots) > dto.MaxMeetupSlots {
+		return nil, dto.ErrMeetupSlotsCount
+	}
+
+	now := time.Now()
+	slots := make([]models.MeetupSlot, 0, len(req.Slots))
+	for _, slot := range req.Slots {
+		if !slot.StartsAt.After(now) || !slot.EndsAt.After(slot.StartsAt) {
+			return nil, dto.ErrMeetupSlotInvalid
+		}
+		slots = append(slots, models.MeetupSlot{StartsAt: slot.StartsAt, EndsAt: slot.EndsAt})
+	}
+
+	exchange, err := s.getAcceptedExchange(exchangeID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	meetup := &models.Meetup{
+		ExchangeID: exchange.ID,
+		ProposerID: userID,
+		Place:      place,
+		Status:     "proposed",
+		Slots:      slots,
+	}
+	if err := s.meetupRepo.Create(meetup); err != nil {
+		return nil, err
+	}
+
+	return meetup, nil
+}
+
+func (s *meetupService) List(exchangeID uint, userID uint) ([]models.Meetup, error) {
+	exchange, err := s.getParticipantExchange(exchangeID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.meetupRepo.ListByExchange(exchange.ID)
+}
+
+func (s *meetupService) Confirm(exchangeID uint, meetupID uint, userID uint, req dto.ConfirmMeetupRequest) (*models.Meetup, error) {
+	exchange, err := s.getAcceptedExchange(exchangeID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	meetup, err := s.meetupRepo.GetByID(meetupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if meetup.ExchangeID != exchange.ID {
+		return nil, dto.ErrMeetupNotFound
+	}
+
+	if meetup.ProposerID == userID {
+		return nil, dto.ErrMeetupSelfConfirm
+	}
+
+	if meetup.Status != "proposed" {
+		return nil, dto.ErrMeetupNotProposed
+	}
+
+	var slot *models.MeetupSlot
+	for i := range meetup.Slots {
+		if meetup.Slots[i].ID == req.SlotID {
+			slot = &meetup.Slots[i]
+		}
+	}
+	if slot == nil {
+		return nil, dto.ErrMeetupSlotNotFound
+	}
+
+	remindAt := slot.StartsAt.Add(-s.reminderBefore)
+	if remindAt.Before(time.Now()) {
+		remindAt = time.Now()
+	}
+
+	reminders := []models.MeetupReminder{
+		{MeetupID: meetup.ID, UserID: exchange.InitiatorID, RemindAt: remindAt},
+		{MeetupID: meetup.ID, UserID: exchange.RecipientID, RemindAt: remindAt},
+	}
+
+	if err := s.meetupRepo.Confirm(meetup, slot.ID, reminders); err != nil {
+		return nil, err
+	}
+
+	return meetup, nil
+}
+
+// ExportICS renders the confirmed meetup of the exchange as an iCalendar file.
+func (s *meetupService) ExportICS(exchangeID uint, userID uint) ([]byte, error) {
+	exchange, err := s.getParticipantExchange(exchangeID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	meetup, err := s.meetupRepo.GetConfirmed(exchange.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, slot := range meetup.Slots {
+		if meetup.ConfirmedSlotID == nil || slot.ID != *meetup.ConfirmedSlotID {
+			continue
+		}
+
+		return ical.Marshal(ical.Event{
+			UID:         fmt.Sprintf("meetup-%d-slot-%d@bookcrossing", meetup.ID, slot.ID),
+			Start:       slot.StartsAt,
+			End:         slot.EndsAt,
+			Summary:     fmt.Sprintf("Book exchange #%d meetup", exchange.ID),
+			Description: fmt.Sprintf("Meetup for book exchange #%d", exchange.ID),
+			Location:    meetup.Place,
+			Created:     meetup.UpdatedAt,
+		}), nil
+	}
+
+	return nil, dto.ErrMeetupNotConfirmed
+}
+
+// DispatchReminders emails every due reminder and returns how many were sent.
+// Each reminder is claimed before it is sent, so several instances running
+// the dispatcher do not send it twice.
+func (s *meetupService) DispatchReminders() (int, error) {
+	reminders, err := s.meetupRepo.ListDueReminders(time.Now(), reminderBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, reminder := range reminders {
+		claimed, err := s.meetupRepo.ClaimReminder(reminder.ID, time.Now())
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := s.sendReminder(reminder); err != nil {
+			s.log.Error("error in DispatchReminders function meetup_services.go", "error", err, "reminder_id", reminder.ID, "attempt", reminder.Attempts+1)
+			if err := s.retryReminder(reminder, err); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// retryReminder queues a failed reminder again with backoff. Reminders that
+// can never be sent, or failed too often, are given up so they do not keep
+// the head of the queue busy.
+func (s *meetupService) retryReminder(reminder models.MeetupReminder, sendErr error) error {
+	now := time.Now()
+	permanent := errors.Is(sendErr, repository.ErrUserNotFound) ||
+		errors.Is(sendErr, dto.ErrMeetupNotFound) ||
+		errors.Is(sendErr, dto.ErrMeetupNotConfirmed)
+	if permanent || reminder.Attempts+1 >= reminderMaxAttempts {
+		s.log.Warn("meetup reminder given up", "reminder_id", reminder.ID, "error", sendErr)
+		return s.meetupRepo.FailReminder(reminder.ID, now)
+	}
+
+	return s.meetupRepo.RetryReminder(reminder.ID, now.Add(reminderRetryDelay<<reminder.Attempts))
+}
+
+func (s *meetupService) sendReminder(reminder models.MeetupReminder) error {
+	meetup, err := s.meetupRepo.GetByID(reminder.MeetupID)
+	if err != nil {
+		return err
+	}
+
+	var slot *models.MeetupSlot
+	for i := range meetup.Slots {
+		if meetup.ConfirmedSlotID != nil && meetup.Slots[i].ID == *meetup.ConfirmedSlotID {
+			slot = &meetup.Slots[i]
+		}
+	}
+	if slot == nil {
+		return dto.ErrMeetupNotConfirmed
+	}
+
+	user, err := s.userRepo.GetByID(reminder.UserID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: fmt.Sprintf("Reminder: book exchange #%d meetup", meetup.ExchangeID),
+		Body: fmt.Sprintf("Hi %s,\n\nyour meetup for book exchange #%d starts at %s.\n\nPlace: %s\n",
+			user.Name, meetup.ExchangeID, slot.StartsAt.Format(time.RFC1123), meetup.Place),
+	})
+}
+
+// StartReminderDispatcher runs DispatchReminders every interval until ctx is cancelled.
+func (s *meetupService) StartReminderDispatcher(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 1 * time.Minute
+	}
+
+	t := time.NewTicker(interval)
+
+	go func() {
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if _, err := s.DispatchReminders(); err != nil {
+					s.log.Error("meetup reminder dispatch failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+func (s *meetupService) getAcceptedExchange(exchangeID uint, userID uint) (*models.Exchange, error) {
+	exchange, err := s.getParticipantExchange(exchangeID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if exchange.Status != "accepted" {
+		return nil, dto.ErrExchangeNotAccepted
+	}
+
+	return exchange, nil
+}
+
+func (s *meetupService) getParticipantExchange(exchangeID uint, userID uint) (*models.Exchange, error) {
+	return participantExchange(s.exchangeRepo, s.authorizer, s.log, exchangeID, userID)
+}
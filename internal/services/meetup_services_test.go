@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/mailer"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"gorm.io/gorm"
+)
+
+type memoryMeetupRepo struct {
+	repository.MeetupRepository
+	meetup    *models.Meetup
+	reminders []models.MeetupReminder
+	retried   map[uint]time.Time
+	failed    []uint
+}
+
+func (r *memoryMeetupRepo) ListDueReminders(now time.Time, limit int) ([]models.MeetupReminder, error) {
+	return r.reminders, nil
+}
+
+func (r *memoryMeetupRepo) ClaimReminder(id uint, sentAt time.Time) (bool, error) {
+	return true, nil
+}
+
+func (r *memoryMeetupRepo) RetryReminder(id uint, retryAt time.Time) error {
+	r.retried[id] = retryAt
+	return nil
+}
+
+func (r *memoryMeetupRepo) FailReminder(id uint, failedAt time.Time) error {
+	r.failed = append(r.failed, id)
+	return nil
+}
+
+func (r *memoryMeetupRepo) GetByID(id uint) (*models.Meetup, error) {
+	return r.meetup, nil
+}
+
+type failingMailer struct{}
+
+func (failingMailer) Send(context.Context, mailer.Message) error {
+	return errors.New("mailbox unavailable")
+}
+
+func TestDispatchRemindersGivesUpFailures(t *testing.T) {
+	slotID := uint(1)
+	meetup := &models.Meetup{ExchangeID: 5, Status: "confirmed", ConfirmedSlotID: &slotID,
+		Slots: []models.MeetupSlot{{Model: gorm.Model{ID: slotID}, StartsAt: time.Now().Add(time.Hour)}}}
+	user := &models.User{Model: gorm.Model{ID: 7}, Email: "ann@example.com"}
+	repo := &memoryMeetupRepo{
+		meetup: meetup,
+		reminders: []models.MeetupReminder{
+			{Model: gorm.Model{ID: 1}, UserID: 7},
+			{Model: gorm.Model{ID: 2}, UserID: 7, Attempts: reminderMaxAttempts - 1},
+			{Model: gorm.Model{ID: 3}, UserID: 99},
+		},
+		retried: map[uint]time.Time{},
+	}
+	svc := NewMeetupService(nil, repo, &memoryUserRepo{users: map[uint]*models.User{user.ID: user}}, nil, failingMailer{}, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	sent, err := svc.DispatchReminders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 0 {
+		t.Fatalf("sent = %d, want 0", sent)
+	}
+
+	// the first failure is retried later, the last attempt and the reminder
+	// of a deleted user are given up
+	if retryAt, ok := repo.retried[1]; !ok || !retryAt.After(time.Now()) {
+		t.Fatalf("reminder 1 not retried later: %v", repo.retried)
+	}
+	if len(repo.failed) != 2 || repo.failed[0] != 2 || repo.failed[1] != 3 {
+		t.Fatalf("failed reminders %v, want [2 3]", repo.failed)
+	}
+}
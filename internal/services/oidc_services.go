@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/oidc"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	oidcStatePrefix = "oidc:state:"
+	oidcStateTTL    = 10 * time.Minute
+	oidcTimeout     = 10 * time.Second
+)
+
+type OIDCService interface {
+	Providers() []string
+	AuthURL(provider string) (string, error)
+	Callback(provider, state, code string) (*dto.LoginResponse, error)
+}
+
+// oidcLogin is what is kept between the redirect to the provider and the
+// callback.
+type oidcLogin struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+}
+
+type oidcService struct {
+	providers    map[string]*oidc.Provider
+	identityRepo repository.IdentityRepository
+	userRepo     repository.UserRepository
+	auth         AuthService
+	twoFactor    TwoFactorService
+	rdb          *redis.Client
+	log          *slog.Logger
+}
+
+func NewOIDCService(providers []*oidc.Provider, identityRepo repository.IdentityRepository, userRepo repository.UserRepository, auth AuthService, twoFactor TwoFactorService, rdb *redis.Client, log *slog.Logger) OIDCService {
+	byName := make(map[string]*oidc.Provider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+
+	return &oidcService{
+		providers:    byName,
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		auth:         auth,
+		twoFactor:    twoFactor,
+		rdb:          rdb,
+		log:          log,
+	}
+}
+
+func (s *oidcService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AuthURL starts a login: it stores a fresh state, nonce and PKCE verifier
+// and returns the provider URL to redirect the user to.
+func (s *oidcService) AuthURL(provider string) (string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", dto.ErrOIDCProviderUnknown
+	}
+	if s.rdb == nil {
+		return "", dto.ErrOIDCLoginFailed
+	}
+
+	state, err := oidc.NewVerifier()
+	if err != nil {
+		return "", dto.ErrOIDCLoginFailed
+	}
+	nonce, err := oidc.NewVerifier()
+	if err != nil {
+		return "", dto.ErrOIDCLoginFailed
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return "", dto.ErrOIDCLoginFailed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcTimeout)
+	defer cancel()
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		s.log.Error("error in AuthURL function oidc_services.go", "error", err, "provider", provider)
+		return "", dto.ErrOIDCLoginFailed
+	}
+
+	b, _ := json.Marshal(oidcLogin{Provider: provider, Verifier: verifier, Nonce: nonce})
+	if err := s.rdb.Set(ctx, oidcStatePrefix+state, b, oidcStateTTL).Err(); err != nil {
+		s.log.Error("error in AuthURL function oidc_services.go", "error", err)
+		return "", dto.ErrOIDCLoginFailed
+	}
+
+	return authURL, nil
+}
+
+// Callback finishes a login. The user is found by the provider identity,
+// then by verified email, and is created when neither exists. Like a
+// password login, accounts with two-factor authentication get a challenge
+// instead of tokens.
+func (s *oidcService) Callback(provider, state, code string) (*dto.LoginResponse, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, dto.ErrOIDCProviderUnknown
+	}
+	if state == "" || code == "" || s.rdb == nil {
+		return nil, dto.ErrOIDCStateInvalid
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcTimeout)
+	defer cancel()
+
+	// GETDEL makes the state single-use
+	raw, err := s.rdb.GetDel(ctx, oidcStatePrefix+state).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			s.log.Error("error in Callback function oidc_services.go", "error", err)
+		}
+		return nil, dto.ErrOIDCStateInvalid
+	}
+
+	var login oidcLogin
+	if err := json.Unmarshal(raw, &login); err != nil || login.Provider != provider {
+		return nil, dto.ErrOIDCStateInvalid
+	}
+
+	claims, err := p.Exchange(ctx, code, login.Verifier, login.Nonce)
+	if err != nil {
+		s.log.Warn("oidc login rejected", "error", err, "provider", provider)
+		return nil, dto.ErrOIDCLoginFailed
+	}
+
+	user, err := s.resolveUser(provider, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := s.twoFactor.Enabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return s.twoFactor.Challenge(user.ID)
+	}
+
+	tokens, err := s.auth.IssueTokens(user.ID, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.LoginResponse{TokenResponse: tokens}, nil
+}
+
+func (s *oidcService) resolveUser(provider string, claims *oidc.Claims) (*models.User, error) {
+	identity, err := s.identityRepo.GetByProviderSubject(provider, claims.Subject)
+	if err == nil {
+		user, err := s.userRepo.GetByID(identity.UserID)
+		if err != nil {
+			return nil, dto.ErrOIDCLoginFailed
+		}
+		return user, nil
+	}
+	if !errors.Is(err, repository.ErrIdentityNotFound) {
+		return nil, err
+	}
+
+	// an unconfirmed address could belong to anyone, so it is never used to
+	// link accounts
+	if !claims.EmailVerified {
+		return nil, dto.ErrOIDCEmailNotVerified
+	}
+	addr, err := mail.ParseAddress(strings.TrimSpace(claims.Email))
+	if err != nil {
+		return nil, dto.ErrOIDCEmailNotVerified
+	}
+
+	identity = &models.UserIdentity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    addr.Address,
+	}
+
+	user, err := s.userRepo.GetByEmail(addr.Address)
+	if err == nil {
+		// someone may have registered the address without owning it, linking
+		// would let them keep the password login to this account
+		if !user.EmailVerified {
+			return nil, dto.ErrOIDCAccountUnverified
+		}
+		identity.UserID = user.ID
+		if err := s.identityRepo.Create(identity); err != nil {
+			return nil, err
+		}
+		s.log.Info("oidc identity linked", "user_id", user.ID, "provider", provider)
+		return user, nil
+	}
+
+	// the account has no usable password until the user resets it
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, dto.ErrOIDCLoginFailed
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, dto.ErrOIDCLoginFailed
+	}
+
+	name := claims.Name
+	if name == "" {
+		name, _, _ = strings.Cut(addr.Address, "@")
+	}
+
+	user = &models.User{
+		Name:          name,
+		Email:         addr.Address,
+		PasswordHash:  string(hash),
+		Role:          models.RoleUser,
+		EmailVerified: true,
+	}
+	if err := s.identityRepo.CreateWithUser(user, identity); err != nil {
+		return nil, err
+	}
+	s.log.Info("user created from oidc login", "user_id", user.ID, "provider", provider)
+
+	return user, nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/mailer"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordResetCooldownPrefix = "password-reset:cooldown:"
+	minPasswordLength           = 8
+)
+
+type PasswordResetConfig struct {
+	// ResetURL is the page that receives the token as ?token=.
+	ResetURL string
+	TTL      time.Duration
+	// Cooldown is the minimum time between two reset emails to one user.
+	Cooldown time.Duration
+}
+
+type PasswordResetService interface {
+	Forgot(email string) error
+	Reset(token, password string) error
+}
+
+type passwordResetService struct {
+	userRepo  repository.UserRepository
+	resetRepo repository.PasswordResetRepository
+	auth      AuthService
+	mailer    mailer.Mailer
+	rdb       *redis.Client
+	cfg       PasswordResetConfig
+	log       *slog.Logger
+}
+
+func NewPasswordResetService(userRepo repository.UserRepository, resetRepo repository.PasswordResetRepository, auth AuthService, m mailer.Mailer, rdb *redis.Client, cfg PasswordResetConfig, log *slog.Logger) PasswordResetService {
+	if cfg.ResetURL == "" {
+		cfg.ResetURL = "http://localhost:8080/reset-password"
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Hour
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = time.Minute
+	}
+
+	return &passwordResetService{
+		userRepo:  userRepo,
+		resetRepo: resetRepo,
+		auth:      auth,
+		mailer:    m,
+		rdb:       rdb,
+		cfg:       cfg,
+		log:       log,
+	}
+}
+
+// Forgot emails a reset link when the address belongs to a user. It returns
+// nil in every case so the caller cannot tell whether the account exists;
+// failures are only logged.
+func (s *passwordResetService) Forgot(email string) error {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return nil
+	}
+
+	user, err := s.userRepo.GetByEmail(addr.Address)
+	if err != nil {
+		return nil
+	}
+
+	if !s.allowEmail(user.ID) {
+		return nil
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		s.log.Error("error in Forgot function password_reset_services.go", "error", err)
+		return nil
+	}
+
+	if err := s.resetRepo.Create(&models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.cfg.TTL),
+	}); err != nil {
+		return nil
+	}
+
+	// sending in the background keeps the response time the same for known
+	// and unknown addresses
+	go s.send(user, token)
+
+	return nil
+}
+
+// Reset sets a new password with a token from Forgot and signs the user out
+// everywhere.
+func (s *passwordResetService) Reset(token, password string) error {
+	if len([]rune(password)) < minPasswordLength {
+		return dto.ErrPasswordTooShort
+	}
+	if token == "" {
+		return dto.ErrPasswordResetTokenInvalid
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		s.log.Error("error in Reset function password_reset_services.go", "error", err)
+		return dto.ErrUserPasswordHashFailed
+	}
+
+	userID, err := s.resetRepo.Reset(hashToken(token), string(hash), time.Now())
+	if err != nil {
+		return err
+	}
+
+	return s.auth.RevokeAllSessions(userID)
+}
+
+func (s *passwordResetService) allowEmail(userID uint) bool {
+	if s.rdb == nil {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	allowed, err := s.rdb.SetNX(ctx, passwordResetCooldownPrefix+strconv.FormatUint(uint64(userID), 10), 1, s.cfg.Cooldown).Result()
+	if err != nil {
+		s.log.Warn("password reset limit check failed", "error", err, "user_id", userID)
+		return true
+	}
+	return allowed
+}
+
+func (s *passwordResetService) send(user *models.User, token string) {
+	link := s.cfg.ResetURL + "?token=" + url.QueryEscape(token)
+
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+
+	err := s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nsomeone asked to reset the password of your account. Open the link below to choose a new one:\n\n%s\n\nThe link is valid for %s and works once. If it was not you, ignore this email.\n",
+			user.Name, link, s.cfg.TTL),
+	})
+	if err != nil {
+		s.log.Error("error in send function password_reset_services.go", "error", err, "user_id", user.ID)
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/dasler-fw/bookcrossing/internal/tokenstore"
+)
+
+const (
+	maxPersonalAccessTokens        = 50
+	defaultPersonalAccessTokenDays = 90
+	maxPersonalAccessTokenDays     = 365
+	// lastUsedResolution keeps busy scripts from writing on every request.
+	lastUsedResolution = time.Minute
+)
+
+type PersonalAccessTokenService interface {
+	Create(userID uint, req dto.PersonalAccessTokenCreateRequest) (*dto.PersonalAccessTokenCreatedResponse, error)
+	List(userID uint) ([]dto.PersonalAccessTokenResponse, error)
+	Revoke(userID, id uint) error
+	VerifyAccessToken(ctx context.Context, token string) (uint, string, []string, error)
+}
+
+type personalAccessTokenService struct {
+	repo     repository.PersonalAccessTokenRepository
+	userRepo repository.UserRepository
+	store    tokenstore.Store
+	log      *slog.Logger
+}
+
+func NewPersonalAccessTokenService(repo repository.PersonalAccessTokenRepository, userRepo repository.UserRepository, store tokenstore.Store, log *slog.Logger) PersonalAccessTokenService {
+	return &personalAccessTokenService{
+		repo:     repo,
+		userRepo: userRepo,
+		store:    store,
+		log:      log,
+	}
+}
+
+func (s *personalAccessTokenService) Create(userID uint, req dto.PersonalAccessTokenCreateRequest) (*dto.PersonalAccessTokenCreatedResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > 100 {
+		return nil, dto.ErrPersonalAccessTokenNameRequired
+	}
+
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultPersonalAccessTokenDays
+	}
+	if days < 0 || days > maxPersonalAccessTokenDays {
+		return nil, dto.ErrPersonalAccessTokenInvalidExpiry
+	}
+
+	count, err := s.repo.CountByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxPersonalAccessTokens {
+		return nil, dto.ErrPersonalAccessTokenLimit
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, dto.ErrPersonalAccessTokenFailed
+	}
+	token := models.PersonalAccessTokenPrefix + secret
+
+	record := &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashToken(token),
+		TokenHint: token[len(token)-4:],
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: time.Now().Add(time.Duration(days) * 24 * time.Hour),
+	}
+	if err := s.repo.Create(record); err != nil {
+		return nil, err
+	}
+	s.log.Info("personal access token created", "user_id", userID, "token_id", record.ID, "scopes", record.Scopes)
+
+	return &dto.PersonalAccessTokenCreatedResponse{
+		PersonalAccessTokenResponse: mapPersonalAccessToken(record),
+		Token:                       token,
+	}, nil
+}
+
+func (s *personalAccessTokenService) List(userID uint) ([]dto.PersonalAccessTokenResponse, error) {
+	tokens, err := s.repo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.PersonalAccessTokenResponse, 0, len(tokens))
+	for i := range tokens {
+		res = append(res, mapPersonalAccessToken(&tokens[i]))
+	}
+	return res, nil
+}
+
+func (s *personalAccessTokenService) Revoke(userID, id uint) error {
+	if err := s.repo.Delete(userID, id); err != nil {
+		return err
+	}
+	s.log.Info("personal access token revoked", "user_id", userID, "token_id", id)
+	return nil
+}
+
+// VerifyAccessToken is used by middleware.JWTAuth. It returns the owner, the
+// owner's current role and the scopes of the token. Tokens created before
+// the owner's sessions were revoked, e.g. by a password reset, are rejected.
+func (s *personalAccessTokenService) VerifyAccessToken(ctx context.Context, token string) (uint, string, []string, error) {
+	record, err := s.repo.GetByHash(hashToken(token))
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	now := time.Now()
+	if now.After(record.ExpiresAt) {
+		return 0, "", nil, dto.ErrPersonalAccessTokenInvalid
+	}
+
+	user, err := s.userRepo.GetByID(record.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return 0, "", nil, dto.ErrPersonalAccessTokenInvalid
+		}
+		return 0, "", nil, dto.ErrPersonalAccessTokenFailed
+	}
+
+	revokedAt, err := s.store.UserRevokedAt(ctx, user.ID)
+	if err != nil {
+		s.log.Error("error in VerifyAccessToken function personal_access_token_services.go", "error", err, "user_id", user.ID)
+		return 0, "", nil, dto.ErrPersonalAccessTokenFailed
+	}
+	if !revokedAt.IsZero() && record.CreatedAt.Before(revokedAt) {
+		return 0, "", nil, dto.ErrPersonalAccessTokenInvalid
+	}
+
+	// a failed update must not fail the request
+	_ = s.repo.TouchLastUsed(record.ID, now, now.Add(-lastUsedResolution))
+
+	return user.ID, user.Role, strings.Fields(record.Scopes), nil
+}
+
+func normalizeScopes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, dto.ErrPersonalAccessTokenInvalidScope
+	}
+
+	scopes := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		scope = strings.TrimSpace(scope)
+		if !slices.Contains(models.Scopes, scope) {
+			return nil, dto.ErrPersonalAccessTokenInvalidScope
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	slices.Sort(scopes)
+	return scopes, nil
+}
+
+func mapPersonalAccessToken(t *models.PersonalAccessToken) dto.PersonalAccessTokenResponse {
+	return dto.PersonalAccessTokenResponse{
+		ID:         t.ID,
+		Name:       t.Name,
+		Scopes:     strings.Fields(t.Scopes),
+		TokenHint:  t.TokenHint,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
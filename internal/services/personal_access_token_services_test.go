@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/dasler-fw/bookcrossing/internal/tokenstore"
+	"gorm.io/gorm"
+)
+
+type memoryPersonalAccessTokenRepo struct {
+	repository.PersonalAccessTokenRepository
+	tokens []*models.PersonalAccessToken
+}
+
+func (r *memoryPersonalAccessTokenRepo) Create(token *models.PersonalAccessToken) error {
+	token.ID = uint(len(r.tokens) + 1)
+	token.CreatedAt = time.Now()
+	r.tokens = append(r.tokens, token)
+	return nil
+}
+
+func (r *memoryPersonalAccessTokenRepo) CountByUser(userID uint) (int64, error) {
+	return int64(len(r.tokens)), nil
+}
+
+func (r *memoryPersonalAccessTokenRepo) GetByHash(tokenHash string) (*models.PersonalAccessToken, error) {
+	for _, t := range r.tokens {
+		if t.TokenHash == tokenHash {
+			copied := *t
+			return &copied, nil
+		}
+	}
+	return nil, dto.ErrPersonalAccessTokenInvalid
+}
+
+func (r *memoryPersonalAccessTokenRepo) TouchLastUsed(id uint, at time.Time, olderThan time.Time) error {
+	return nil
+}
+
+func TestPersonalAccessTokenRevokedWithSessions(t *testing.T) {
+	ctx := context.Background()
+	user := &models.User{Model: gorm.Model{ID: 7}, Role: models.RoleUser}
+	store := tokenstore.NewMemoryStore()
+	svc := NewPersonalAccessTokenService(
+		&memoryPersonalAccessTokenRepo{},
+		&memoryUserRepo{users: map[uint]*models.User{user.ID: user}},
+		store,
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+	)
+
+	req := dto.PersonalAccessTokenCreateRequest{Name: "script", Scopes: []string{models.ScopeBooksWrite}}
+	old, err := svc.Create(user.ID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := svc.VerifyAccessToken(ctx, old.Token); err != nil {
+		t.Fatalf("fresh token rejected: %v", err)
+	}
+
+	time.Sleep(time.Millisecond)
+	if err := store.RevokeUser(ctx, user.ID, time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := svc.VerifyAccessToken(ctx, old.Token); !errors.Is(err, dto.ErrPersonalAccessTokenInvalid) {
+		t.Fatalf("got %v, want ErrPersonalAccessTokenInvalid", err)
+	}
+
+	time.Sleep(time.Millisecond)
+	created, err := svc.Create(user.ID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := svc.VerifyAccessToken(ctx, created.Token); err != nil {
+		t.Fatalf("token created after the revocation rejected: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/dasler-fw/bookcrossing/internal/authz"
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/dasler-fw/bookcrossing/internal/repository"
@@ -13,15 +14,16 @@ type ReviewService interface {
 	Create(authorID uint, req dto.CreateReviewRequest) error
 	GetByUserID(userID uint) ([]models.Review, error)
 	GetByBookID(bookID uint) ([]models.Review, error)
-	Delete(reviewID uint, authorID uint) error
+	Delete(reviewID uint, actor authz.Actor) error
 }
 
 type reviewService struct {
-	repo repository.ReviewRepository
+	repo       repository.ReviewRepository
+	authorizer authz.Authorizer
 }
 
-func NewReviewService(repo repository.ReviewRepository) ReviewService {
-	return &reviewService{repo: repo}
+func NewReviewService(repo repository.ReviewRepository, authorizer authz.Authorizer) ReviewService {
+	return &reviewService{repo: repo, authorizer: authorizer}
 }
 
 func (s *reviewService) Create(authorID uint, req dto.CreateReviewRequest) error {
@@ -40,10 +42,6 @@ func (s *reviewService) Create(authorID uint, req dto.CreateReviewRequest) error
 		return dto.ErrInvalidRating
 	}
 
-	if req.TargetUserID == authorID {
-		return dto.ErrSelfReviewForbidden
-	}
-
 	review := models.Review{
 		AuthorID:     authorID,
 		TargetUserID: req.TargetUserID,
@@ -51,6 +49,10 @@ func (s *reviewService) Create(authorID uint, req dto.CreateReviewRequest) error
 		Text:         req.Text,
 		Rating:       req.Rating,
 	}
+
+	if err := s.authorizer.Can(authz.Actor{UserID: authorID}, authz.ActionCreate, &review); err != nil {
+		return err
+	}
 	return s.repo.Create(&review)
 }
 
@@ -62,14 +64,14 @@ func (s *reviewService) GetByBookID(bookID uint) ([]models.Review, error) {
 	return s.repo.GetByTargetBookID(bookID)
 }
 
-func (s *reviewService) Delete(reviewID uint, authorID uint) error {
+func (s *reviewService) Delete(reviewID uint, actor authz.Actor) error {
 	review, err := s.repo.GetByID(reviewID)
 	if err != nil {
 		return err
 	}
 
-	if review.AuthorID != authorID {
-		return dto.ErrReviewDeleteForbidden
+	if err := s.authorizer.Can(actor, authz.ActionDelete, review); err != nil {
+		return err
 	}
 	return s.repo.Delete(reviewID)
 }
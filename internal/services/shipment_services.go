@@ -0,0 +1,186 @@
+package services
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/authz"
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+)
+
+type ShipmentService interface {
+	GetShipping(exchangeID uint, userID uint) (*models.Exchange, *models.User, []models.ExchangeShipment, error)
+	Ship(exchangeID uint, userID uint, req dto.ShipExchangeRequest) (*models.ExchangeShipment, error)
+	Deliver(exchangeID uint, userID uint, requestID string) (*models.Exchange, error)
+}
+
+type shipmentService struct {
+	exchangeRepo repository.ExchangeRepository
+	shipmentRepo repository.ShipmentRepository
+	userRepo     repository.UserRepository
+	authorizer   authz.Authorizer
+	log          *slog.Logger
+}
+
+func NewShipmentService(exchangeRepo repository.ExchangeRepository, shipmentRepo repository.ShipmentRepository, userRepo repository.UserRepository, authorizer authz.Authorizer, log *slog.Logger) ShipmentService {
+	return &shipmentService{
+		exchangeRepo: exchangeRepo,
+		shipmentRepo: shipmentRepo,
+		userRepo:     userRepo,
+		authorizer:   authorizer,
+		log:          log,
+	}
+}
+
+// GetShipping reveals the counterparty's address. Addresses stay hidden
+// until the exchange is accepted.
+func (s *shipmentService) GetShipping(exchangeID uint, userID uint) (*models.Exchange, *models.User, []models.ExchangeShipment, error) {
+	exchange, err := s.getPostalExchange(exchangeID, userID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if exchange.Status != "accepted" && exchange.Status != "completed" {
+		return nil, nil, nil, dto.ErrExchangeNotAccepted
+	}
+
+	counterpartyID := exchange.RecipientID
+	if exchange.RecipientID == userID {
+		counterpartyID = exchange.InitiatorID
+	}
+
+	counterparty, err := s.userRepo.GetByID(counterpartyID)
+	if err != nil {
+		s.log.Error("error in GetShipping function shipment_services.go", "error", err)
+		return nil, nil, nil, dto.ErrUserGetFailed
+	}
+
+	shipments, err := s.shipmentRepo.ListByExchange(exchange.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return exchange, counterparty, shipments, nil
+}
+
+// Ship records the carrier and tracking number of the books the user sends.
+func (s *shipmentService) Ship(exchangeID uint, userID uint, req dto.ShipExchangeRequest) (*models.ExchangeShipment, error) {
+	carrier := strings.TrimSpace(req.Carrier)
+	if carrier == "" || len([]rune(carrier)) > dto.MaxCarrierLength {
+		return nil, dto.ErrShipmentCarrier
+	}
+
+	trackingNumber := strings.TrimSpace(req.TrackingNumber)
+	if trackingNumber == "" || len([]rune(trackingNumber)) > dto.MaxTrackingNumberLength {
+		return nil, dto.ErrShipmentTracking
+	}
+
+	exchange, err := s.getPostalExchange(exchangeID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if exchange.Status != "accepted" {
+		return nil, dto.ErrExchangeNotAccepted
+	}
+
+	shipment, err := s.findShipment(exchange.ID, func(sh models.ExchangeShipment) bool { return sh.SenderID == userID })
+	if err != nil {
+		return nil, err
+	}
+
+	if shipment.Status != "pending" {
+		return nil, dto.ErrShipmentAlreadyShipped
+	}
+
+	// the counterparty must have an address to ship to
+	receiver, err := s.userRepo.GetByID(shipment.ReceiverID)
+	if err != nil {
+		s.log.Error("error in Ship function shipment_services.go", "error", err)
+		return nil, dto.ErrUserGetFailed
+	}
+	if strings.TrimSpace(receiver.Address) == "" {
+		return nil, dto.ErrShippingAddressMissing
+	}
+
+	if err := s.shipmentRepo.Ship(shipment, carrier, trackingNumber, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return shipment, nil
+}
+
+// Deliver confirms that the books sent to the user arrived. The exchange is
+// completed when both shipments are delivered.
+func (s *shipmentService) Deliver(exchangeID uint, userID uint, requestID string) (*models.Exchange, error) {
+	exchange, err := s.getPostalExchange(exchangeID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if exchange.Status != "accepted" {
+		return nil, dto.ErrExchangeNotAccepted
+	}
+
+	shipment, err := s.findShipment(exchange.ID, func(sh models.ExchangeShipment) bool { return sh.ReceiverID == userID })
+	if err != nil {
+		return nil, err
+	}
+
+	switch shipment.Status {
+	case "pending":
+		return nil, dto.ErrShipmentNotShipped
+	case "delivered":
+		return nil, dto.ErrShipmentDelivered
+	}
+
+	meta := dto.ExchangeEventMeta{ActorID: userID, RequestID: requestID}
+	if err := s.shipmentRepo.Deliver(exchange, shipment, time.Now(), meta); err != nil {
+		return nil, err
+	}
+
+	return exchange, nil
+}
+
+func (s *shipmentService) findShipment(exchangeID uint, match func(models.ExchangeShipment) bool) (*models.ExchangeShipment, error) {
+	shipments, err := s.shipmentRepo.ListByExchange(exchangeID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range shipments {
+		if match(shipments[i]) {
+			return &shipments[i], nil
+		}
+	}
+	return nil, dto.ErrShipmentNotFound
+}
+
+func (s *shipmentService) getPostalExchange(exchangeID uint, userID uint) (*models.Exchange, error) {
+	if userID == 0 {
+		return nil, dto.ErrUnauthorized
+	}
+
+	if exchangeID == 0 {
+		return nil, dto.ErrExchangeInvalidID
+	}
+
+	exchange, err := s.exchangeRepo.GetByID(exchangeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authorizer.Can(authz.Actor{UserID: userID}, authz.ActionParticipate, exchange); err != nil {
+		s.log.Error("error in getPostalExchange function shipment_services.go", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	if exchange.DeliveryMethod != "postal" {
+		return nil, dto.ErrExchangeNotPostal
+	}
+
+	return exchange, nil
+}
@@ -0,0 +1,437 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/dasler-fw/bookcrossing/internal/totp"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	mfaChallengePrefix    = "mfa:challenge:"
+	mfaAttemptsPrefix     = "mfa:attempts:"
+	mfaMaxAttempts        = 5
+	recoveryCodeCount     = 10
+	recoveryCodeLength    = 10
+	twoFactorRedisTimeout = 500 * time.Millisecond
+)
+
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijkmnpqrstuvwxyz23456789").WithPadding(base32.NoPadding)
+
+type TwoFactorConfig struct {
+	// Issuer is the account label shown in authenticator apps.
+	Issuer string
+	// EncryptionKey protects the TOTP secrets stored in the database.
+	EncryptionKey []byte
+	// ChallengeTTL is how long the intermediate login token is valid.
+	ChallengeTTL time.Duration
+}
+
+type TwoFactorService interface {
+	Status(userID uint) (*dto.TwoFactorStatusResponse, error)
+	Setup(userID uint) (*dto.TwoFactorSetupResponse, error)
+	Enable(userID uint, code string) (*dto.RecoveryCodesResponse, error)
+	Disable(userID uint, req dto.TwoFactorConfirmRequest) error
+	RegenerateRecoveryCodes(userID uint, req dto.TwoFactorConfirmRequest) (*dto.RecoveryCodesResponse, error)
+	Enabled(userID uint) (bool, error)
+	Challenge(userID uint) (*dto.LoginResponse, error)
+	CompleteLogin(req dto.TwoFactorLoginRequest) (*dto.TokenResponse, error)
+}
+
+type twoFactorService struct {
+	repo       repository.TwoFactorRepository
+	userRepo   repository.UserRepository
+	auth       AuthService
+	loginGuard LoginGuard
+	rdb        *redis.Client
+	cfg        TwoFactorConfig
+	aead       cipher.AEAD
+	log        *slog.Logger
+}
+
+func NewTwoFactorService(repo repository.TwoFactorRepository, userRepo repository.UserRepository, auth AuthService, loginGuard LoginGuard, rdb *redis.Client, cfg TwoFactorConfig, log *slog.Logger) (TwoFactorService, error) {
+	if cfg.Issuer == "" {
+		cfg.Issuer = "Bookcrossing"
+	}
+	if cfg.ChallengeTTL <= 0 {
+		cfg.ChallengeTTL = 5 * time.Minute
+	}
+	if len(cfg.EncryptionKey) == 0 {
+		return nil, errors.New("two-factor encryption key is empty")
+	}
+
+	key := sha256.Sum256(cfg.EncryptionKey)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &twoFactorService{
+		repo:       repo,
+		userRepo:   userRepo,
+		auth:       auth,
+		loginGuard: loginGuard,
+		rdb:        rdb,
+		cfg:        cfg,
+		aead:       aead,
+		log:        log,
+	}, nil
+}
+
+func (s *twoFactorService) Status(userID uint) (*dto.TwoFactorStatusResponse, error) {
+	tf, err := s.repo.Get(userID)
+	if errors.Is(err, repository.ErrTwoFactorNotFound) || (err == nil && tf.EnabledAt == nil) {
+		return &dto.TwoFactorStatusResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	left, err := s.repo.CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.TwoFactorStatusResponse{Enabled: true, RecoveryCodesLeft: left}, nil
+}
+
+// Setup starts enrollment with a new secret. 2FA is not enforced until the
+// user proves the authenticator works by calling Enable.
+func (s *twoFactorService) Setup(userID uint) (*dto.TwoFactorSetupResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, repository.ErrUserNotFound
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, dto.ErrTwoFactorFailed
+	}
+	sealed, err := s.seal(secret)
+	if err != nil {
+		return nil, dto.ErrTwoFactorFailed
+	}
+
+	if err := s.repo.SavePending(&models.TwoFactor{UserID: userID, Secret: sealed}); err != nil {
+		return nil, err
+	}
+
+	return &dto.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURL: totp.ProvisioningURI(s.cfg.Issuer, user.Email, secret),
+	}, nil
+}
+
+// Enable finishes enrollment and returns the recovery codes. They are shown
+// only this once.
+func (s *twoFactorService) Enable(userID uint, code string) (*dto.RecoveryCodesResponse, error) {
+	tf, err := s.repo.Get(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTwoFactorNotFound) {
+			return nil, dto.ErrTwoFactorNotSetUp
+		}
+		return nil, err
+	}
+	if tf.EnabledAt != nil {
+		return nil, dto.ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := s.open(tf.Secret)
+	if err != nil {
+		s.log.Error("error in Enable function two_factor_services.go", "error", err, "user_id", userID)
+		return nil, dto.ErrTwoFactorFailed
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return nil, dto.ErrTwoFactorCodeInvalid
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, dto.ErrTwoFactorFailed
+	}
+
+	if err := s.repo.Enable(userID, step, time.Now(), hashes); err != nil {
+		return nil, err
+	}
+	s.log.Info("two-factor authentication enabled", "user_id", userID)
+
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Disable needs the password and a current code, so a stolen access token
+// alone cannot turn 2FA off.
+func (s *twoFactorService) Disable(userID uint, req dto.TwoFactorConfirmRequest) error {
+	if err := s.reauthenticate(userID, req); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(userID); err != nil {
+		return err
+	}
+	s.log.Info("two-factor authentication disabled", "user_id", userID)
+
+	return nil
+}
+
+func (s *twoFactorService) RegenerateRecoveryCodes(userID uint, req dto.TwoFactorConfirmRequest) (*dto.RecoveryCodesResponse, error) {
+	if err := s.reauthenticate(userID, req); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, dto.ErrTwoFactorFailed
+	}
+	if err := s.repo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *twoFactorService) Enabled(userID uint) (bool, error) {
+	tf, err := s.repo.Get(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTwoFactorNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return tf.EnabledAt != nil, nil
+}
+
+// Challenge is called after the password was checked. It returns the
+// intermediate token that, together with a code, is exchanged for the real
+// tokens by CompleteLogin.
+func (s *twoFactorService) Challenge(userID uint) (*dto.LoginResponse, error) {
+	if s.rdb == nil {
+		return nil, dto.ErrTwoFactorUnavailable
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, dto.ErrTwoFactorFailed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), twoFactorRedisTimeout)
+	defer cancel()
+
+	if err := s.rdb.Set(ctx, mfaChallengePrefix+hashToken(token), userID, s.cfg.ChallengeTTL).Err(); err != nil {
+		s.log.Error("error in Challenge function two_factor_services.go", "error", err)
+		return nil, dto.ErrTwoFactorUnavailable
+	}
+
+	return &dto.LoginResponse{
+		MFARequired:  true,
+		MFAToken:     token,
+		MFAExpiresIn: int64(s.cfg.ChallengeTTL / time.Second),
+	}, nil
+}
+
+// CompleteLogin allows a few attempts per challenge; after that the user has
+// to enter the password again. Wrong codes also count as failed logins of
+// the account, so repeating the password step does not give an attacker
+// unlimited guesses.
+func (s *twoFactorService) CompleteLogin(req dto.TwoFactorLoginRequest) (*dto.TokenResponse, error) {
+	if s.rdb == nil {
+		return nil, dto.ErrTwoFactorUnavailable
+	}
+	if req.MFAToken == "" {
+		return nil, dto.ErrTwoFactorChallengeInvalid
+	}
+
+	hash := hashToken(req.MFAToken)
+	challengeKey := mfaChallengePrefix + hash
+	attemptsKey := mfaAttemptsPrefix + hash
+
+	ctx, cancel := context.WithTimeout(context.Background(), twoFactorRedisTimeout)
+	defer cancel()
+
+	userID, err := s.rdb.Get(ctx, challengeKey).Uint64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, dto.ErrTwoFactorChallengeInvalid
+		}
+		s.log.Error("error in CompleteLogin function two_factor_services.go", "error", err)
+		return nil, dto.ErrTwoFactorUnavailable
+	}
+
+	user, err := s.userRepo.GetByID(uint(userID))
+	if err != nil {
+		return nil, dto.ErrTwoFactorChallengeInvalid
+	}
+	if err := s.loginGuard.Check(req.ClientIP, user.Email); err != nil {
+		return nil, err
+	}
+
+	attempts, err := s.rdb.Incr(ctx, attemptsKey).Result()
+	if err != nil {
+		s.log.Error("error in CompleteLogin function two_factor_services.go", "error", err)
+		return nil, dto.ErrTwoFactorUnavailable
+	}
+	if attempts == 1 {
+		s.rdb.Expire(ctx, attemptsKey, s.cfg.ChallengeTTL)
+	}
+	if attempts > mfaMaxAttempts {
+		s.rdb.Del(ctx, challengeKey, attemptsKey)
+		s.log.Warn("two-factor login attempts exceeded", "user_id", userID)
+		return nil, dto.ErrTwoFactorChallengeInvalid
+	}
+
+	tf, err := s.repo.Get(uint(userID))
+	if err != nil || tf.EnabledAt == nil {
+		return nil, dto.ErrTwoFactorChallengeInvalid
+	}
+
+	if err := s.verifyCode(tf, req.Code); err != nil {
+		s.log.Warn("two-factor login code rejected", "user_id", userID, "attempt", attempts)
+		if errors.Is(err, dto.ErrTwoFactorCodeInvalid) {
+			s.loginGuard.Failed(req.ClientIP, user.Email, user)
+		}
+		return nil, err
+	}
+
+	// the challenge is single-use; a concurrent request with the same token
+	// loses here
+	deleted, err := s.rdb.Del(ctx, challengeKey, attemptsKey).Result()
+	if err != nil || deleted == 0 {
+		return nil, dto.ErrTwoFactorChallengeInvalid
+	}
+	s.loginGuard.Succeeded(req.ClientIP, user.Email)
+
+	return s.auth.IssueTokens(user.ID, user.Role)
+}
+
+func (s *twoFactorService) reauthenticate(userID uint, req dto.TwoFactorConfirmRequest) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return repository.ErrUserNotFound
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return dto.ErrInvalidCredentials
+	}
+
+	tf, err := s.repo.Get(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTwoFactorNotFound) {
+			return dto.ErrTwoFactorNotEnabled
+		}
+		return err
+	}
+	if tf.EnabledAt == nil {
+		return dto.ErrTwoFactorNotEnabled
+	}
+
+	return s.verifyCode(tf, req.Code)
+}
+
+// verifyCode accepts a TOTP code that was not used before or an unused
+// recovery code.
+func (s *twoFactorService) verifyCode(tf *models.TwoFactor, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return dto.ErrTwoFactorCodeInvalid
+	}
+
+	if len(code) == totp.Digits {
+		secret, err := s.open(tf.Secret)
+		if err != nil {
+			s.log.Error("error in verifyCode function two_factor_services.go", "error", err, "user_id", tf.UserID)
+			return dto.ErrTwoFactorFailed
+		}
+
+		step, ok := totp.Validate(secret, code, time.Now())
+		if !ok {
+			return dto.ErrTwoFactorCodeInvalid
+		}
+		fresh, err := s.repo.UseStep(tf.UserID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return dto.ErrTwoFactorCodeInvalid
+		}
+		return nil
+	}
+
+	used, err := s.repo.UseRecoveryCode(tf.UserID, hashToken(normalizeRecoveryCode(code)), time.Now())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return dto.ErrTwoFactorCodeInvalid
+	}
+	s.log.Info("recovery code used", "user_id", tf.UserID)
+
+	return nil
+}
+
+func (s *twoFactorService) seal(plaintext string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *twoFactorService) open(sealed string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	if len(b) < s.aead.NonceSize() {
+		return "", errors.New("sealed secret is too short")
+	}
+	plain, err := s.aead.Open(nil, b[:s.aead.NonceSize()], b[s.aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// newRecoveryCodes returns codes formatted as xxxxx-xxxxx and the hashes of
+// their normalized form.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := recoveryCodeEncoding.EncodeToString(b)[:recoveryCodeLength]
+
+		codes = append(codes, raw[:recoveryCodeLength/2]+"-"+raw[recoveryCodeLength/2:])
+		hashes = append(hashes, hashToken(raw))
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/mailer"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/dasler-fw/bookcrossing/internal/totp"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type memoryTwoFactorRepo struct {
+	tf    *models.TwoFactor
+	codes map[string]bool
+}
+
+func (r *memoryTwoFactorRepo) Get(userID uint) (*models.TwoFactor, error) {
+	if r.tf == nil || r.tf.UserID != userID {
+		return nil, repository.ErrTwoFactorNotFound
+	}
+	copied := *r.tf
+	return &copied, nil
+}
+
+func (r *memoryTwoFactorRepo) SavePending(tf *models.TwoFactor) error {
+	if r.tf != nil && r.tf.EnabledAt != nil {
+		return dto.ErrTwoFactorAlreadyEnabled
+	}
+	copied := *tf
+	r.tf = &copied
+	return nil
+}
+
+func (r *memoryTwoFactorRepo) Enable(userID uint, step int64, at time.Time, codeHashes []string) error {
+	r.tf.EnabledAt = &at
+	r.tf.LastUsedStep = step
+	return r.ReplaceRecoveryCodes(userID, codeHashes)
+}
+
+func (r *memoryTwoFactorRepo) Delete(userID uint) error {
+	r.tf = nil
+	r.codes = nil
+	return nil
+}
+
+func (r *memoryTwoFactorRepo) UseStep(userID uint, step int64) (bool, error) {
+	if step <= r.tf.LastUsedStep {
+		return false, nil
+	}
+	r.tf.LastUsedStep = step
+	return true, nil
+}
+
+func (r *memoryTwoFactorRepo) UseRecoveryCode(userID uint, codeHash string, at time.Time) (bool, error) {
+	unused, ok := r.codes[codeHash]
+	if !ok || !unused {
+		return false, nil
+	}
+	r.codes[codeHash] = false
+	return true, nil
+}
+
+func (r *memoryTwoFactorRepo) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	r.codes = make(map[string]bool, len(codeHashes))
+	for _, h := range codeHashes {
+		r.codes[h] = true
+	}
+	return nil
+}
+
+func (r *memoryTwoFactorRepo) CountRecoveryCodes(userID uint) (int64, error) {
+	var n int64
+	for _, unused := range r.codes {
+		if unused {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func TestTwoFactorLifecycle(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+	user := &models.User{Model: gorm.Model{ID: 3}, Email: "ann@example.com", PasswordHash: string(hash)}
+	repo := &memoryTwoFactorRepo{}
+
+	svc, err := NewTwoFactorService(repo, &memoryUserRepo{users: map[uint]*models.User{user.ID: user}}, nil, NewLoginGuard(nil, mailer.NewMemoryMailer(), LoginGuardConfig{}, log), nil, TwoFactorConfig{
+		EncryptionKey: []byte("test-key"),
+	}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setup, err := svc.Setup(user.ID)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if repo.tf.Secret == setup.Secret {
+		t.Fatal("secret is stored in plain text")
+	}
+
+	if _, err := svc.Enable(user.ID, "000000"); !errors.Is(err, dto.ErrTwoFactorCodeInvalid) {
+		t.Fatalf("enable with a wrong code: got %v", err)
+	}
+
+	code, _ := totp.Code(setup.Secret, totp.Step(time.Now()))
+	codes, err := svc.Enable(user.ID, code)
+	if err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	if len(codes.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes", len(codes.RecoveryCodes))
+	}
+	if enabled, _ := svc.Enabled(user.ID); !enabled {
+		t.Fatal("2FA is not enabled")
+	}
+	if _, err := svc.Setup(user.ID); !errors.Is(err, dto.ErrTwoFactorAlreadyEnabled) {
+		t.Fatalf("setup while enabled: got %v", err)
+	}
+
+	tf, _ := repo.Get(user.ID)
+	impl := svc.(*twoFactorService)
+
+	if err := impl.verifyCode(tf, code); !errors.Is(err, dto.ErrTwoFactorCodeInvalid) {
+		t.Fatalf("replayed code: got %v", err)
+	}
+
+	recovery := codes.RecoveryCodes[0]
+	if err := impl.verifyCode(tf, recovery); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if err := impl.verifyCode(tf, recovery); !errors.Is(err, dto.ErrTwoFactorCodeInvalid) {
+		t.Fatalf("reused recovery code: got %v", err)
+	}
+
+	if err := svc.Disable(user.ID, dto.TwoFactorConfirmRequest{Password: "wrong", Code: codes.RecoveryCodes[1]}); !errors.Is(err, dto.ErrInvalidCredentials) {
+		t.Fatalf("disable with a wrong password: got %v", err)
+	}
+	if err := svc.Disable(user.ID, dto.TwoFactorConfirmRequest{Password: "secret-password", Code: codes.RecoveryCodes[1]}); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if enabled, _ := svc.Enabled(user.ID); enabled {
+		t.Fatal("2FA is still enabled")
+	}
+}
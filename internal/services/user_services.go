@@ -3,12 +3,15 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/mail"
+	"strings"
 	"time"
 
+	"github.com/dasler-fw/bookcrossing/internal/authz"
 	"github.com/dasler-fw/bookcrossing/internal/dto"
-	"github.com/dasler-fw/bookcrossing/internal/jwtutil"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/dasler-fw/bookcrossing/internal/repository"
 	"github.com/redis/go-redis/v9"
@@ -17,32 +20,45 @@ import (
 )
 
 type UserService interface {
-	Register(req dto.UserCreateRequest) (string, error)
-	Login(req dto.LoginRequest) (string, error)
+	Register(req dto.UserCreateRequest) (*dto.TokenResponse, error)
+	Login(req dto.LoginRequest) (*dto.LoginResponse, error)
 	GetUserByID(id uint) (*models.User, error)
 	UpdateUser(id uint, req dto.UserUpdateRequest) (*models.User, error)
 	ListUsers(limit, offset int) ([]models.User, error)
-	DeleteUser(id uint) error
-	GetProfile(userID uint) (*dto.UserProfileResponse, error)
-	UpdateProfile(userID uint, req dto.UserUpdateRequest) error
-	GetUserExchanges(userID uint, status string) ([]models.Exchange, error)
+	DeleteUser(actor authz.Actor, id uint) error
+	SetRole(id uint, role string) (*models.User, error)
+	GetProfile(actor authz.Actor, userID uint) (*dto.UserProfileResponse, error)
+	UpdateProfile(actor authz.Actor, userID uint, req dto.UserUpdateRequest) error
+	GetUserExchanges(actor authz.Actor, userID uint, query *dto.ExchangeListQuery) ([]models.Exchange, string, error)
 }
 
 type userService struct {
-	db       *gorm.DB
-	userRepo repository.UserRepository
-	bookRepo repository.BookRepository
-	log      *slog.Logger
-	rdb      *redis.Client
+	db           *gorm.DB
+	userRepo     repository.UserRepository
+	bookRepo     repository.BookRepository
+	exchangeRepo repository.ExchangeRepository
+	auth         AuthService
+	verification EmailVerificationService
+	twoFactor    TwoFactorService
+	loginGuard   LoginGuard
+	authorizer   authz.Authorizer
+	log          *slog.Logger
+	rdb          *redis.Client
 }
 
-func NewServiceUser(db *gorm.DB, userRepo repository.UserRepository, bookRepo repository.BookRepository, log *slog.Logger, rdb *redis.Client) UserService {
+func NewServiceUser(db *gorm.DB, userRepo repository.UserRepository, bookRepo repository.BookRepository, exchangeRepo repository.ExchangeRepository, auth AuthService, verification EmailVerificationService, twoFactor TwoFactorService, loginGuard LoginGuard, authorizer authz.Authorizer, log *slog.Logger, rdb *redis.Client) UserService {
 	return &userService{
-		db:       db,
-		userRepo: userRepo,
-		bookRepo: bookRepo,
-		log:      log,
-		rdb:      rdb,
+		db:           db,
+		userRepo:     userRepo,
+		bookRepo:     bookRepo,
+		exchangeRepo: exchangeRepo,
+		auth:         auth,
+		verification: verification,
+		twoFactor:    twoFactor,
+		loginGuard:   loginGuard,
+		authorizer:   authorizer,
+		log:          log,
+		rdb:          rdb,
 	}
 }
 
@@ -62,16 +78,21 @@ func (s *userService) InvalidateUserList() {
 	s.log.Info("user cache invalidated", "cache", "users:list", "method", "version bump")
 }
 
-func (s *userService) Register(req dto.UserCreateRequest) (string, error) {
+func (s *userService) Register(req dto.UserCreateRequest) (*dto.TokenResponse, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(req.Email))
+	if err != nil {
+		return nil, dto.ErrInvalidEmail
+	}
+	req.Email = addr.Address
 
-	_, err := s.userRepo.GetByEmail(req.Email)
+	_, err = s.userRepo.GetByEmail(req.Email)
 	if err == nil {
-		return "", dto.ErrEmailAlreadyUsed
+		return nil, dto.ErrEmailAlreadyUsed
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	user := &models.User{
@@ -80,29 +101,55 @@ func (s *userService) Register(req dto.UserCreateRequest) (string, error) {
 		PasswordHash: string(hash),
 		City:         req.City,
 		Address:      req.Address,
+		Role:         models.RoleUser,
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return jwtutil.GenerateToken(user.ID)
+	// a failed email does not fail the registration, the user can resend it
+	_ = s.verification.Send(user)
+
+	return s.auth.IssueTokens(user.ID, user.Role)
 }
 
-func (s *userService) Login(req dto.LoginRequest) (string, error) {
+// Login checks the password. For accounts with two-factor authentication it
+// returns a challenge instead of tokens.
+func (s *userService) Login(req dto.LoginRequest) (*dto.LoginResponse, error) {
+	if err := s.loginGuard.Check(req.ClientIP, req.Email); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetByEmail(req.Email)
 	if err != nil {
-		return "", dto.ErrInvalidCredentials
+		s.loginGuard.Failed(req.ClientIP, req.Email, nil)
+		return nil, dto.ErrInvalidCredentials
 	}
 
 	if err := bcrypt.CompareHashAndPassword(
 		[]byte(user.PasswordHash),
 		[]byte(req.Password),
 	); err != nil {
-		return "", dto.ErrInvalidCredentials
+		s.loginGuard.Failed(req.ClientIP, req.Email, user)
+		return nil, dto.ErrInvalidCredentials
 	}
 
-	return jwtutil.GenerateToken(user.ID)
+	enabled, err := s.twoFactor.Enabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	// the failure counters are reset once the second factor is verified too
+	if enabled {
+		return s.twoFactor.Challenge(user.ID)
+	}
+	s.loginGuard.Succeeded(req.ClientIP, req.Email)
+
+	tokens, err := s.auth.IssueTokens(user.ID, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.LoginResponse{TokenResponse: tokens}, nil
 }
 
 func (s *userService) GetUserByID(id uint) (*models.User, error) {
@@ -210,19 +257,51 @@ func (s *userService) ListUsers(limit, offset int) ([]models.User, error) {
 	return users, nil
 }
 
-func (s *userService) DeleteUser(id uint) error {
+func (s *userService) DeleteUser(actor authz.Actor, id uint) error {
+	if err := s.authorizer.Can(actor, authz.ActionDelete, &models.User{Model: gorm.Model{ID: id}}); err != nil {
+		return err
+	}
+
 	if err := s.userRepo.Delete(id); err != nil {
 		return dto.ErrUserDeleteFailed
 	}
+	s.InvalidateUserList()
 	return nil
 }
 
-func (s *userService) GetProfile(userID uint) (*dto.UserProfileResponse, error) {
+// SetRole changes the role of a user. It takes effect when the user's
+// current access token is refreshed.
+func (s *userService) SetRole(id uint, role string) (*models.User, error) {
+	switch role {
+	case models.RoleUser, models.RoleModerator, models.RoleAdmin:
+	default:
+		return nil, dto.ErrUserInvalidRole
+	}
+
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, repository.ErrUserNotFound
+	}
+
+	user.Role = role
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, dto.ErrUserUpdateFailed
+	}
+
+	s.InvalidateUserList()
+	return user, nil
+}
+
+func (s *userService) GetProfile(actor authz.Actor, userID uint) (*dto.UserProfileResponse, error) {
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return nil, repository.ErrUserNotFound
 	}
 
+	if err := s.authorizer.Can(actor, authz.ActionRead, user); err != nil {
+		return nil, err
+	}
+
 	books, err := s.bookRepo.GetByUserID(userID, "")
 	if err != nil {
 		return nil, dto.ErrUserProfileFailed
@@ -240,15 +319,20 @@ func (s *userService) GetProfile(userID uint) (*dto.UserProfileResponse, error)
 		City:                     user.City,
 		BooksCount:               int64(len(books)),
 		SuccessfulExchangesCount: successfulExchanges,
+		Reputation:               user.Reputation,
 	}, nil
 }
 
-func (s *userService) UpdateProfile(userID uint, req dto.UserUpdateRequest) error {
+func (s *userService) UpdateProfile(actor authz.Actor, userID uint, req dto.UserUpdateRequest) error {
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return repository.ErrUserNotFound
 	}
 
+	if err := s.authorizer.Can(actor, authz.ActionUpdate, user); err != nil {
+		return err
+	}
+
 	if req.Name != nil {
 		user.Name = *req.Name
 	}
@@ -264,19 +348,26 @@ func (s *userService) UpdateProfile(userID uint, req dto.UserUpdateRequest) erro
 	return nil
 }
 
-func (s *userService) GetUserExchanges(userID uint, status string) ([]models.Exchange, error) {
-	var exchanges []models.Exchange
+func (s *userService) GetUserExchanges(actor authz.Actor, userID uint, query *dto.ExchangeListQuery) ([]models.Exchange, string, error) {
+	if err := s.authorizer.Can(actor, authz.ActionReadExchanges, &models.User{Model: gorm.Model{ID: userID}}); err != nil {
+		return nil, "", err
+	}
 
-	q := s.db.Model(&models.Exchange{}).
-		Where("initiator_id = ? OR recipient_id = ?", userID, userID)
+	query.ParticipantID = userID
 
-	if status != "" {
-		q = q.Where("status = ?", status)
+	normalized, err := normalizeExchangeListQuery(*query)
+	if err != nil {
+		return nil, "", err
 	}
+	*query = normalized
 
-	if err := q.Order("created_at desc").Find(&exchanges).Error; err != nil {
-		return nil, dto.ErrUserExchangesFailed
+	exchanges, next, err := s.exchangeRepo.List(normalized)
+	if err != nil {
+		if errors.Is(err, dto.ErrExchangeInvalidCursor) {
+			return nil, "", err
+		}
+		return nil, "", dto.ErrUserExchangesFailed
 	}
 
-	return exchanges, nil
+	return exchanges, next, nil
 }
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+)
+
+type WishlistService interface {
+	Add(userID uint, req dto.AddWishlistItemRequest) (*models.WishlistItem, error)
+	List(userID uint) ([]models.WishlistItem, error)
+	Delete(itemID uint, userID uint) error
+}
+
+type wishlistService struct {
+	wishlistRepo repository.WishlistRepository
+	bookRepo     repository.BookRepository
+}
+
+func NewWishlistService(wishlistRepo repository.WishlistRepository, bookRepo repository.BookRepository) WishlistService {
+	return &wishlistService{wishlistRepo: wishlistRepo, bookRepo: bookRepo}
+}
+
+func (s *wishlistService) Add(userID uint, req dto.AddWishlistItemRequest) (*models.WishlistItem, error) {
+	if userID == 0 {
+		return nil, dto.ErrUnauthorized
+	}
+
+	book, err := s.bookRepo.GetByID(req.BookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if book.UserID == userID {
+		return nil, dto.ErrWishlistOwnBook
+	}
+
+	exists, err := s.wishlistRepo.Exists(userID, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, dto.ErrWishlistDuplicate
+	}
+
+	item := &models.WishlistItem{
+		UserID: userID,
+		BookID: book.ID,
+		Book:   book,
+	}
+	if err := s.wishlistRepo.Add(item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (s *wishlistService) List(userID uint) ([]models.WishlistItem, error) {
+	return s.wishlistRepo.ListByUser(userID)
+}
+
+func (s *wishlistService) Delete(itemID uint, userID uint) error {
+	item, err := s.wishlistRepo.GetByID(itemID)
+	if err != nil {
+		return err
+	}
+
+	if item.UserID != userID {
+		return dto.ErrWishlistItemNotFound
+	}
+
+	return s.wishlistRepo.Delete(itemID)
+}
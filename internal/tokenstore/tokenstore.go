@@ -0,0 +1,395 @@
+// Package tokenstore keeps the server side state of authentication tokens:
+// issued refresh tokens, revoked refresh token families and the denylist of
+// access token IDs. MemoryStore is meant for tests.
+package tokenstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNotFound = errors.New("token not found")
+
+type RefreshRecord struct {
+	UserID    uint      `json:"user_id"`
+	FamilyID  string    `json:"family_id"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type Store interface {
+	SaveRefresh(ctx context.Context, hash string, rec RefreshRecord) error
+	// ConsumeRefresh marks the token as used. It reports false when the token
+	// had already been used before, which means it was stolen and replayed.
+	ConsumeRefresh(ctx context.Context, hash string) (*RefreshRecord, bool, error)
+	RevokeFamily(ctx context.Context, familyID string, until time.Time) error
+	FamilyRevoked(ctx context.Context, familyID string) (bool, error)
+	DenyJTI(ctx context.Context, jti string, until time.Time) error
+	JTIDenied(ctx context.Context, jti string) (bool, error)
+	// RevokeUser invalidates every token issued to the user before at.
+	RevokeUser(ctx context.Context, userID uint, at, until time.Time) error
+	// UserRevokedAt returns the zero time when the user has no cutoff.
+	UserRevokedAt(ctx context.Context, userID uint) (time.Time, error)
+}
+
+const (
+	refreshPrefix = "auth:refresh:"
+	usedSuffix    = ":used"
+	familyPrefix  = "auth:family-revoked:"
+	jtiPrefix     = "auth:jti-denied:"
+	userPrefix    = "auth:user-revoked:"
+)
+
+type RedisStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisStore(rdb *redis.Client) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+func (s *RedisStore) SaveRefresh(ctx context.Context, hash string, rec RefreshRecord) error {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, refreshPrefix+hash, raw, time.Until(rec.ExpiresAt)).Err()
+}
+
+func (s *RedisStore) ConsumeRefresh(ctx context.Context, hash string) (*RefreshRecord, bool, error) {
+	raw, err := s.rdb.Get(ctx, refreshPrefix+hash).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, ErrNotFound
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var rec RefreshRecord
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return nil, false, err
+	}
+
+	first, err := s.rdb.SetNX(ctx, refreshPrefix+hash+usedSuffix, 1, time.Until(rec.ExpiresAt)).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	return &rec, first, nil
+}
+
+func (s *RedisStore) RevokeFamily(ctx context.Context, familyID string, until time.Time) error {
+	return s.rdb.Set(ctx, familyPrefix+familyID, 1, time.Until(until)).Err()
+}
+
+func (s *RedisStore) FamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	n, err := s.rdb.Exists(ctx, familyPrefix+familyID).Result()
+	return n > 0, err
+}
+
+func (s *RedisStore) DenyJTI(ctx context.Context, jti string, until time.Time) error {
+	return s.rdb.Set(ctx, jtiPrefix+jti, 1, time.Until(until)).Err()
+}
+
+func (s *RedisStore) JTIDenied(ctx context.Context, jti string) (bool, error) {
+	n, err := s.rdb.Exists(ctx, jtiPrefix+jti).Result()
+	return n > 0, err
+}
+
+func (s *RedisStore) RevokeUser(ctx context.Context, userID uint, at, until time.Time) error {
+	return s.rdb.Set(ctx, userPrefix+strconv.FormatUint(uint64(userID), 10), at.UnixNano(), time.Until(until)).Err()
+}
+
+func (s *RedisStore) UserRevokedAt(ctx context.Context, userID uint) (time.Time, error) {
+	nanos, err := s.rdb.Get(ctx, userPrefix+strconv.FormatUint(uint64(userID), 10)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nanos), nil
+}
+
+type DBStore struct {
+	db *gorm.DB
+}
+
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) SaveRefresh(ctx context.Context, hash string, rec RefreshRecord) error {
+	return s.db.WithContext(ctx).Create(&models.RefreshToken{
+		TokenHash: hash,
+		UserID:    rec.UserID,
+		FamilyID:  rec.FamilyID,
+		ExpiresAt: rec.ExpiresAt,
+		CreatedAt: rec.IssuedAt,
+	}).Error
+}
+
+func (s *DBStore) ConsumeRefresh(ctx context.Context, hash string) (*RefreshRecord, bool, error) {
+	db := s.db.WithContext(ctx)
+
+	var token models.RefreshToken
+	if err := db.Where("token_hash = ? AND expires_at > ?", hash, time.Now()).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, ErrNotFound
+		}
+		return nil, false, err
+	}
+
+	rec := &RefreshRecord{UserID: token.UserID, FamilyID: token.FamilyID, IssuedAt: token.CreatedAt, ExpiresAt: token.ExpiresAt}
+
+	res := db.Model(&models.RefreshToken{}).Where("id = ? AND used_at IS NULL", token.ID).Update("used_at", time.Now())
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+	return rec, res.RowsAffected == 1, nil
+}
+
+func (s *DBStore) RevokeFamily(ctx context.Context, familyID string, until time.Time) error {
+	return s.revoke(ctx, "family", familyID, until)
+}
+
+func (s *DBStore) FamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	return s.revoked(ctx, "family", familyID)
+}
+
+func (s *DBStore) DenyJTI(ctx context.Context, jti string, until time.Time) error {
+	return s.revoke(ctx, "jti", jti, until)
+}
+
+func (s *DBStore) JTIDenied(ctx context.Context, jti string) (bool, error) {
+	return s.revoked(ctx, "jti", jti)
+}
+
+func (s *DBStore) RevokeUser(ctx context.Context, userID uint, at, until time.Time) error {
+	return s.revokeAt(ctx, "user", strconv.FormatUint(uint64(userID), 10), at, until)
+}
+
+func (s *DBStore) UserRevokedAt(ctx context.Context, userID uint) (time.Time, error) {
+	var token models.RevokedToken
+	err := s.db.WithContext(ctx).
+		Where("kind = ? AND value = ? AND expires_at > ?", "user", strconv.FormatUint(uint64(userID), 10), time.Now()).
+		First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return token.RevokedAt, nil
+}
+
+func (s *DBStore) revoke(ctx context.Context, kind, value string, until time.Time) error {
+	return s.revokeAt(ctx, kind, value, time.Now(), until)
+}
+
+func (s *DBStore) revokeAt(ctx context.Context, kind, value string, at, until time.Time) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kind"}, {Name: "value"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_at", "expires_at"}),
+	}).Create(&models.RevokedToken{Kind: kind, Value: value, RevokedAt: at, ExpiresAt: until}).Error
+}
+
+func (s *DBStore) revoked(ctx context.Context, kind, value string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.RevokedToken{}).
+		Where("kind = ? AND value = ? AND expires_at > ?", kind, value, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// FallbackStore writes to both stores so that either one can answer alone.
+// Reads go to the primary and only fall back when it fails. Revocations
+// therefore have to reach the primary and fail while it is down.
+type FallbackStore struct {
+	primary  Store
+	fallback Store
+	log      *slog.Logger
+}
+
+func NewFallbackStore(primary, fallback Store, log *slog.Logger) *FallbackStore {
+	return &FallbackStore{primary: primary, fallback: fallback, log: log}
+}
+
+func (s *FallbackStore) SaveRefresh(ctx context.Context, hash string, rec RefreshRecord) error {
+	return s.write(func(st Store) error { return st.SaveRefresh(ctx, hash, rec) })
+}
+
+func (s *FallbackStore) ConsumeRefresh(ctx context.Context, hash string) (*RefreshRecord, bool, error) {
+	// both copies are consumed so a replay is detected whichever store
+	// answers next time
+	rec, first, err := s.primary.ConsumeRefresh(ctx, hash)
+	fbRec, fbFirst, fbErr := s.fallback.ConsumeRefresh(ctx, hash)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		s.log.Warn("token store primary failed, using fallback", "error", err)
+		return fbRec, fbFirst, fbErr
+	}
+	if errors.Is(err, ErrNotFound) {
+		return fbRec, fbFirst, fbErr
+	}
+	if fbErr == nil && !fbFirst {
+		first = false
+	}
+	return rec, first, nil
+}
+
+func (s *FallbackStore) RevokeFamily(ctx context.Context, familyID string, until time.Time) error {
+	return s.revoke(func(st Store) error { return st.RevokeFamily(ctx, familyID, until) })
+}
+
+func (s *FallbackStore) FamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	return s.read(func(st Store) (bool, error) { return st.FamilyRevoked(ctx, familyID) })
+}
+
+func (s *FallbackStore) DenyJTI(ctx context.Context, jti string, until time.Time) error {
+	return s.revoke(func(st Store) error { return st.DenyJTI(ctx, jti, until) })
+}
+
+func (s *FallbackStore) JTIDenied(ctx context.Context, jti string) (bool, error) {
+	return s.read(func(st Store) (bool, error) { return st.JTIDenied(ctx, jti) })
+}
+
+func (s *FallbackStore) RevokeUser(ctx context.Context, userID uint, at, until time.Time) error {
+	return s.revoke(func(st Store) error { return st.RevokeUser(ctx, userID, at, until) })
+}
+
+func (s *FallbackStore) UserRevokedAt(ctx context.Context, userID uint) (time.Time, error) {
+	at, err := s.primary.UserRevokedAt(ctx, userID)
+	if err == nil {
+		return at, nil
+	}
+	s.log.Warn("token store primary failed, using fallback", "error", err)
+	return s.fallback.UserRevokedAt(ctx, userID)
+}
+
+// read asks the fallback only when the primary fails, so a healthy primary
+// keeps the database off the path of every authenticated request.
+func (s *FallbackStore) read(fn func(Store) (bool, error)) (bool, error) {
+	yes, err := fn(s.primary)
+	if err == nil {
+		return yes, nil
+	}
+	s.log.Warn("token store primary failed, using fallback", "error", err)
+	return fn(s.fallback)
+}
+
+// write applies fn to both stores and only fails when both fail.
+func (s *FallbackStore) write(fn func(Store) error) error {
+	primaryErr := fn(s.primary)
+	if primaryErr != nil {
+		s.log.Warn("token store primary failed, using fallback", "error", primaryErr)
+	}
+	if err := fn(s.fallback); err != nil {
+		if primaryErr != nil {
+			return err
+		}
+		s.log.Warn("token store fallback write failed", "error", err)
+	}
+	return nil
+}
+
+// revoke applies fn to both stores but fails when the primary fails. Reads
+// trust a healthy primary, so a revocation that only reached the fallback
+// would be forgotten once the primary is back.
+func (s *FallbackStore) revoke(fn func(Store) error) error {
+	primaryErr := fn(s.primary)
+	if err := fn(s.fallback); err != nil {
+		s.log.Warn("token store fallback write failed", "error", err)
+	}
+	if primaryErr != nil {
+		s.log.Error("token store revocation failed", "error", primaryErr)
+		return primaryErr
+	}
+	return nil
+}
+
+// MemoryStore keeps everything in memory. It is meant for tests.
+type MemoryStore struct {
+	mu       sync.Mutex
+	refresh  map[string]RefreshRecord
+	used     map[string]bool
+	families map[string]time.Time
+	jtis     map[string]time.Time
+	users    map[uint]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		refresh:  map[string]RefreshRecord{},
+		used:     map[string]bool{},
+		families: map[string]time.Time{},
+		jtis:     map[string]time.Time{},
+		users:    map[uint]time.Time{},
+	}
+}
+
+func (s *MemoryStore) SaveRefresh(ctx context.Context, hash string, rec RefreshRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh[hash] = rec
+	return nil
+}
+
+func (s *MemoryStore) ConsumeRefresh(ctx context.Context, hash string) (*RefreshRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.refresh[hash]
+	if !ok {
+		return nil, false, ErrNotFound
+	}
+	first := !s.used[hash]
+	s.used[hash] = true
+	return &rec, first, nil
+}
+
+func (s *MemoryStore) RevokeFamily(ctx context.Context, familyID string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.families[familyID] = until
+	return nil
+}
+
+func (s *MemoryStore) FamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Now().Before(s.families[familyID]), nil
+}
+
+func (s *MemoryStore) DenyJTI(ctx context.Context, jti string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jtis[jti] = until
+	return nil
+}
+
+func (s *MemoryStore) JTIDenied(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Now().Before(s.jtis[jti]), nil
+}
+
+func (s *MemoryStore) RevokeUser(ctx context.Context, userID uint, at, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[userID] = at
+	return nil
+}
+
+func (s *MemoryStore) UserRevokedAt(ctx context.Context, userID uint) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.users[userID], nil
+}
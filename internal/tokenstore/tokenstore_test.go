@@ -0,0 +1,88 @@
+package tokenstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// downStore fails every call, like Redis during an outage.
+type downStore struct{ Store }
+
+var errDown = errors.New("connection refused")
+
+func (downStore) RevokeFamily(context.Context, string, time.Time) error { return errDown }
+func (downStore) DenyJTI(context.Context, string, time.Time) error      { return errDown }
+func (downStore) RevokeUser(context.Context, uint, time.Time, time.Time) error {
+	return errDown
+}
+func (downStore) FamilyRevoked(context.Context, string) (bool, error) { return false, errDown }
+func (downStore) JTIDenied(context.Context, string) (bool, error)     { return false, errDown }
+func (downStore) UserRevokedAt(context.Context, uint) (time.Time, error) {
+	return time.Time{}, errDown
+}
+
+// countingStore counts the revocation checks that reach it.
+type countingStore struct {
+	Store
+	reads int
+}
+
+func (s *countingStore) JTIDenied(ctx context.Context, jti string) (bool, error) {
+	s.reads++
+	return s.Store.JTIDenied(ctx, jti)
+}
+
+func (s *countingStore) UserRevokedAt(ctx context.Context, userID uint) (time.Time, error) {
+	s.reads++
+	return s.Store.UserRevokedAt(ctx, userID)
+}
+
+func TestFallbackStoreRevocationsNeedPrimary(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	primary, fallback := NewMemoryStore(), NewMemoryStore()
+	until := time.Now().Add(time.Hour)
+
+	// a revocation the primary did not get is reported, it would be lost
+	// once the primary is back
+	during := NewFallbackStore(downStore{primary}, fallback, log)
+	if err := during.DenyJTI(ctx, "jti", until); err == nil {
+		t.Fatal("revocation succeeded while the primary is down")
+	}
+	if err := during.RevokeUser(ctx, 7, time.Now(), until); err == nil {
+		t.Fatal("revocation succeeded while the primary is down")
+	}
+
+	// while the primary is down, the fallback answers
+	if denied, err := during.JTIDenied(ctx, "jti"); err != nil || !denied {
+		t.Fatalf("jti denied = %v, %v", denied, err)
+	}
+	if at, err := during.UserRevokedAt(ctx, 7); err != nil || at.IsZero() {
+		t.Fatalf("user revoked at = %v, %v", at, err)
+	}
+}
+
+func TestFallbackStoreReadsPrimaryOnly(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	fallback := &countingStore{Store: NewMemoryStore()}
+	store := NewFallbackStore(NewMemoryStore(), fallback, log)
+
+	cutoff := time.Now()
+	if err := store.RevokeUser(ctx, 7, cutoff, cutoff.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if denied, err := store.JTIDenied(ctx, "jti"); err != nil || denied {
+		t.Fatalf("jti denied = %v, %v", denied, err)
+	}
+	if at, err := store.UserRevokedAt(ctx, 7); err != nil || !at.Equal(cutoff) {
+		t.Fatalf("user revoked at = %v, %v", at, err)
+	}
+	if fallback.reads != 0 {
+		t.Fatalf("fallback read %d times while the primary is healthy", fallback.reads)
+	}
+}
@@ -0,0 +1,11 @@
+package transport
+
+import (
+	"github.com/dasler-fw/bookcrossing/internal/authz"
+	"github.com/gin-gonic/gin"
+)
+
+// actorFromContext returns the user set by middleware.JWTAuth.
+func actorFromContext(c *gin.Context) authz.Actor {
+	return authz.Actor{UserID: c.GetUint("user_id"), Role: c.GetString("role")}
+}
@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	service  services.AdminService
+	userServ services.UserService
+}
+
+func NewAdminHandler(service services.AdminService, userServ services.UserService) *AdminHandler {
+	return &AdminHandler{service: service, userServ: userServ}
+}
+
+func (h *AdminHandler) RegisterAdminRoutes(router *gin.Engine) {
+	admin := router.Group("/admin", middleware.JWTAuth(), middleware.RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/stats", h.Stats)
+		admin.PUT("/users/:id/role", h.SetRole)
+	}
+}
+
+func (h *AdminHandler) Stats(c *gin.Context) {
+	stats, err := h.service.GetStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+func (h *AdminHandler) SetRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный идентификатор пользователя"})
+		return
+	}
+
+	var req dto.UserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	user, err := h.userServ.SetRole(uint(id), req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, dto.ErrUserInvalidRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": user.ID, "role": user.Role})
+}
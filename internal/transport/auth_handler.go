@@ -0,0 +1,103 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type AuthHandler struct {
+	service       services.AuthService
+	passwordReset services.PasswordResetService
+}
+
+func NewAuthHandler(service services.AuthService, passwordReset services.PasswordResetService) *AuthHandler {
+	return &AuthHandler{service: service, passwordReset: passwordReset}
+}
+
+func (h *AuthHandler) RegisterAuthRoutes(router *gin.Engine) {
+	auth := router.Group("/auth")
+	{
+		auth.POST("/refresh", h.Refresh)
+		auth.POST("/logout", middleware.JWTAuth(), h.Logout)
+		auth.POST("/password/forgot", h.ForgotPassword)
+		auth.POST("/password/reset", h.ResetPassword)
+	}
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req dto.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	tokens, err := h.service.Refresh(req.RefreshToken)
+	if err != nil {
+		c.JSON(authErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req dto.LogoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+	}
+
+	expiresAt := c.GetTime("token_expires_at")
+	if err := h.service.Logout(c.GetUint("user_id"), c.GetString("jti"), expiresAt, req.RefreshToken); err != nil {
+		c.JSON(authErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ForgotPassword answers 202 whether or not the email belongs to an account.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	_ = h.passwordReset.Forgot(req.Email)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the account exists, a reset link was sent to its email"})
+}
+
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req dto.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if err := h.passwordReset.Reset(req.Token, req.Password); err != nil {
+		c.JSON(authErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password changed, please log in again"})
+}
+
+func authErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dto.ErrRefreshTokenInvalid), errors.Is(err, dto.ErrRefreshTokenReused):
+		return http.StatusUnauthorized
+	case errors.Is(err, dto.ErrPasswordResetTokenInvalid), errors.Is(err, dto.ErrPasswordTooShort):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
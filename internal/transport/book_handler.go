@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"errors"
 	"math"
 	"net/http"
 	"strconv"
@@ -14,23 +15,24 @@ import (
 )
 
 type BookHandler struct {
-	service services.BookService
+	service     services.BookService
+	idempotency gin.HandlerFunc
 }
 
-func NewBookHandler(service services.BookService) *BookHandler {
-	return &BookHandler{service: service}
+func NewBookHandler(service services.BookService, idempotency gin.HandlerFunc) *BookHandler {
+	return &BookHandler{service: service, idempotency: idempotency}
 }
 
 func (h *BookHandler) RegisterRoutes(r *gin.Engine) {
 	books := r.Group("/books")
 	{
-		books.POST("", middleware.JWTAuth(), h.CreateBook)
+		books.POST("", middleware.JWTAuth(models.ScopeBooksWrite), h.idempotency, h.CreateBook)
 		books.GET("", h.Search)
 		books.GET("/available", h.GetAvailable)
 		books.GET("/list", h.GetBookList)
 		books.GET("/:id", h.GetBookByID)
-		books.PATCH("/:id", middleware.JWTAuth(), h.UpdateBook)
-		books.DELETE("/:id", middleware.JWTAuth(), h.DeleteBook)
+		books.PATCH("/:id", middleware.JWTAuth(models.ScopeBooksWrite), h.UpdateBook)
+		books.DELETE("/:id", middleware.JWTAuth(models.ScopeBooksWrite), h.DeleteBook)
 	}
 	r.GET("/users/:id/books", h.GetByUserID)
 }
@@ -114,17 +116,15 @@ func (h *BookHandler) UpdateBook(ctx *gin.Context) {
 		return
 	}
 
-	userID := ctx.GetUint("user_id") // 🔥 из JWT
-
 	var req dto.UpdateBookRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	book, err := h.service.Update(uint(bookID), userID, req)
+	book, err := h.service.Update(uint(bookID), actorFromContext(ctx), req)
 	if err != nil {
-		ctx.IndentedJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		ctx.IndentedJSON(bookErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -138,16 +138,30 @@ func (h *BookHandler) DeleteBook(ctx *gin.Context) {
 		return
 	}
 
-	userID := ctx.GetUint("user_id")
-
-	if err := h.service.Delete(uint(bookID), userID); err != nil {
-		ctx.IndentedJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	if err := h.service.Delete(uint(bookID), actorFromContext(ctx)); err != nil {
+		ctx.IndentedJSON(bookErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	ctx.IndentedJSON(http.StatusOK, gin.H{"deleted": true})
 }
 
+func bookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dto.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, dto.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, dto.ErrBookGetFailed):
+		return http.StatusNotFound
+	case errors.Is(err, dto.ErrBookConflict),
+		errors.Is(err, dto.ErrBookInExchange):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func (h *BookHandler) Search(ctx *gin.Context) {
 	var query dto.BookListQuery
 
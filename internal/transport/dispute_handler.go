@@ -0,0 +1,294 @@
+package transport
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type DisputeHandler struct {
+	service services.DisputeService
+}
+
+func NewDisputeHandler(service services.DisputeService) *DisputeHandler {
+	return &DisputeHandler{service: service}
+}
+
+func (h *DisputeHandler) RegisterDisputeRoutes(router *gin.Engine) {
+	disputes := router.Group("/disputes", middleware.JWTAuth())
+	{
+		disputes.POST("", h.Open)
+		disputes.GET("/:id", h.Get)
+		disputes.POST("/:id/evidence", h.AddEvidence)
+		disputes.GET("/:id/evidence/:evidence_id", h.DownloadEvidence)
+	}
+
+	moderation := router.Group("/moderation/disputes", middleware.JWTAuth(), middleware.RequireRole(models.RoleModerator, models.RoleAdmin))
+	{
+		moderation.GET("", h.Queue)
+		moderation.GET("/:id", h.GetForModeration)
+		moderation.GET("/:id/evidence/:evidence_id", h.DownloadEvidence)
+		moderation.PUT("/:id/claim", h.Claim)
+		moderation.PUT("/:id/resolve", h.Resolve)
+	}
+}
+
+func (h *DisputeHandler) Open(c *gin.Context) {
+	var req dto.OpenDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	dispute, err := h.service.Open(c.GetUint("user_id"), req)
+	if err != nil {
+		c.JSON(disputeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, mapDisputeToResponse(*dispute))
+}
+
+func (h *DisputeHandler) Get(c *gin.Context) {
+	disputeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dispute, err := h.service.Get(uint(disputeID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(disputeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapDisputeToResponse(*dispute))
+}
+
+func (h *DisputeHandler) AddEvidence(c *gin.Context) {
+	disputeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	defer file.Close()
+
+	evidence, err := h.service.AddEvidence(uint(disputeID), c.GetUint("user_id"), header.Filename,
+		header.Header.Get("Content-Type"), header.Size, file)
+	if err != nil {
+		c.JSON(disputeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, mapDisputeEvidenceToResponse(*evidence))
+}
+
+// DownloadEvidence serves both the participant and the moderator routes;
+// the route group tells which access check applies.
+func (h *DisputeHandler) DownloadEvidence(c *gin.Context) {
+	disputeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	evidenceID, err := strconv.Atoi(c.Param("evidence_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	moderator := c.FullPath() == "/moderation/disputes/:id/evidence/:evidence_id"
+	evidence, err := h.service.GetEvidence(uint(disputeID), uint(evidenceID), c.GetUint("user_id"), moderator)
+	if err != nil {
+		c.JSON(disputeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", evidence.ContentType)
+	c.FileAttachment(evidence.Path, evidence.FileName)
+}
+
+func (h *DisputeHandler) Queue(c *gin.Context) {
+	var query dto.DisputeQueueQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	disputes, total, err := h.service.Queue(query)
+	if err != nil {
+		c.JSON(disputeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	page, limit := query.Page, query.Limit
+	if page <= 0 {
+		page = dto.DefaultPage
+	}
+	if limit <= 0 {
+		limit = dto.DefaultDisputeLimit
+	}
+	if limit > dto.MaxDisputeLimit {
+		limit = dto.MaxDisputeLimit
+	}
+
+	data := make([]dto.DisputeResponse, 0, len(disputes))
+	for _, d := range disputes {
+		data = append(data, mapDisputeToResponse(d))
+	}
+
+	c.JSON(http.StatusOK, dto.DisputeListResponse{
+		Data:       data,
+		Page:       page,
+		Limit:      limit,
+		Total:      int(total),
+		TotalPages: int(math.Ceil(float64(total) / float64(limit))),
+	})
+}
+
+func (h *DisputeHandler) GetForModeration(c *gin.Context) {
+	disputeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dispute, err := h.service.GetForModeration(uint(disputeID))
+	if err != nil {
+		c.JSON(disputeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapDisputeToResponse(*dispute))
+}
+
+func (h *DisputeHandler) Claim(c *gin.Context) {
+	disputeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dispute, err := h.service.Claim(uint(disputeID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(disputeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapDisputeToResponse(*dispute))
+}
+
+func (h *DisputeHandler) Resolve(c *gin.Context) {
+	disputeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req dto.ResolveDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	dispute, err := h.service.Resolve(uint(disputeID), c.GetUint("user_id"), req, c.GetString("request_id"))
+	if err != nil {
+		c.JSON(disputeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapDisputeToResponse(*dispute))
+}
+
+func mapDisputeToResponse(d models.Dispute) dto.DisputeResponse {
+	evidence := make([]dto.DisputeEvidenceResponse, 0, len(d.Evidence))
+	for _, e := range d.Evidence {
+		evidence = append(evidence, mapDisputeEvidenceToResponse(e))
+	}
+
+	events := make([]dto.DisputeEventResponse, 0, len(d.Events))
+	for _, e := range d.Events {
+		events = append(events, dto.DisputeEventResponse{
+			ID:        e.ID,
+			ActorID:   e.ActorID,
+			Action:    e.Action,
+			Note:      e.Note,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+
+	return dto.DisputeResponse{
+		ID:             d.ID,
+		ExchangeID:     d.ExchangeID,
+		OpenedByID:     d.OpenedByID,
+		Reason:         d.Reason,
+		Description:    d.Description,
+		Status:         d.Status,
+		ModeratorID:    d.ModeratorID,
+		Resolution:     d.Resolution,
+		ResolutionNote: d.ResolutionNote,
+		ResolvedAt:     d.ResolvedAt,
+		Evidence:       evidence,
+		Events:         events,
+		CreatedAt:      d.CreatedAt,
+	}
+}
+
+func mapDisputeEvidenceToResponse(e models.DisputeEvidence) dto.DisputeEvidenceResponse {
+	return dto.DisputeEvidenceResponse{
+		ID:          e.ID,
+		UploaderID:  e.UploaderID,
+		FileName:    e.FileName,
+		ContentType: e.ContentType,
+		Size:        e.Size,
+		CreatedAt:   e.CreatedAt,
+	}
+}
+
+func disputeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dto.ErrDisputeInvalidReason),
+		errors.Is(err, dto.ErrDisputeDescription),
+		errors.Is(err, dto.ErrDisputeInvalidResolution),
+		errors.Is(err, dto.ErrDisputeReputationDelta),
+		errors.Is(err, dto.ErrDisputeEvidenceType),
+		errors.Is(err, dto.ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, dto.ErrDisputeEvidenceSize):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, dto.ErrDisputeNotFound),
+		errors.Is(err, dto.ErrDisputeEvidenceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dto.ErrDisputeAlreadyOpen),
+		errors.Is(err, dto.ErrDisputeExchangeStatus),
+		errors.Is(err, dto.ErrDisputeClosed),
+		errors.Is(err, dto.ErrDisputeNotClaimed),
+		errors.Is(err, dto.ErrDisputeAlreadyClaimed),
+		errors.Is(err, dto.ErrDisputeNotReversible),
+		errors.Is(err, dto.ErrDisputeBooksMoved),
+		errors.Is(err, dto.ErrDisputeTooManyEvidence):
+		return http.StatusConflict
+	default:
+		return exchangeErrorStatus(err)
+	}
+}
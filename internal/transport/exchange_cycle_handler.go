@@ -0,0 +1,161 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ExchangeCycleHandler struct {
+	service services.ExchangeCycleService
+}
+
+func NewExchangeCycleHandler(service services.ExchangeCycleService) *ExchangeCycleHandler {
+	return &ExchangeCycleHandler{service: service}
+}
+
+func (h *ExchangeCycleHandler) RegisterExchangeCycleRoutes(router *gin.Engine) {
+	suggestions := router.Group("/exchanges/suggestions", middleware.JWTAuth())
+	{
+		suggestions.GET("", h.GetSuggestions)
+		suggestions.PUT("/:id/accept", h.AcceptCycle)
+		suggestions.PUT("/:id/decline", h.DeclineCycle)
+		suggestions.PUT("/:id/cancel", h.CancelCycle)
+		suggestions.PUT("/:id/legs/:leg_id/complete", h.CompleteLeg)
+	}
+}
+
+func (h *ExchangeCycleHandler) GetSuggestions(c *gin.Context) {
+	cycles, err := h.service.GetSuggestions(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(exchangeCycleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]dto.ExchangeCycleResponse, 0, len(cycles))
+	for _, cycle := range cycles {
+		response = append(response, mapExchangeCycleToResponse(cycle))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *ExchangeCycleHandler) AcceptCycle(c *gin.Context) {
+	cycleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cycle, err := h.service.AcceptCycle(uint(cycleID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(exchangeCycleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapExchangeCycleToResponse(*cycle))
+}
+
+func (h *ExchangeCycleHandler) DeclineCycle(c *gin.Context) {
+	cycleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.DeclineCycle(uint(cycleID), c.GetUint("user_id")); err != nil {
+		c.JSON(exchangeCycleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange cycle declined successfully"})
+}
+
+// CancelCycle gives up an accepted cycle and releases the books that were
+// not handed over yet.
+func (h *ExchangeCycleHandler) CancelCycle(c *gin.Context) {
+	cycleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.CancelCycle(uint(cycleID), c.GetUint("user_id")); err != nil {
+		c.JSON(exchangeCycleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange cycle cancelled successfully"})
+}
+
+func (h *ExchangeCycleHandler) CompleteLeg(c *gin.Context) {
+	cycleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	legID, err := strconv.Atoi(c.Param("leg_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cycle, err := h.service.CompleteLeg(uint(cycleID), uint(legID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(exchangeCycleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapExchangeCycleToResponse(*cycle))
+}
+
+func mapExchangeCycleToResponse(cycle models.ExchangeCycle) dto.ExchangeCycleResponse {
+	legs := make([]dto.ExchangeCycleLegResponse, 0, len(cycle.Legs))
+	for _, leg := range cycle.Legs {
+		legs = append(legs, dto.ExchangeCycleLegResponse{
+			ID:          leg.ID,
+			Position:    leg.Position,
+			GiverID:     leg.GiverID,
+			ReceiverID:  leg.ReceiverID,
+			BookID:      leg.BookID,
+			AcceptedAt:  leg.AcceptedAt,
+			CompletedAt: leg.CompletedAt,
+		})
+	}
+
+	return dto.ExchangeCycleResponse{
+		ID:          cycle.ID,
+		Status:      cycle.Status,
+		Legs:        legs,
+		CompletedAt: cycle.CompletedAt,
+		CreatedAt:   cycle.CreatedAt,
+	}
+}
+
+// exchangeCycleErrorStatus maps exchange cycle errors to HTTP status codes.
+func exchangeCycleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dto.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, dto.ErrCycleNotParticipant),
+		errors.Is(err, dto.ErrCycleNotReceiver):
+		return http.StatusForbidden
+	case errors.Is(err, dto.ErrCycleNotFound),
+		errors.Is(err, dto.ErrCycleLegNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dto.ErrCycleNotProposed),
+		errors.Is(err, dto.ErrCycleNotAccepted),
+		errors.Is(err, dto.ErrCycleAlreadyDone),
+		errors.Is(err, dto.ErrCycleBookTaken):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -1,10 +1,12 @@
 package transport
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/dasler-fw/bookcrossing/internal/services"
 	"github.com/gin-gonic/gin"
@@ -12,17 +14,29 @@ import (
 
 type ExchangeHandler struct {
 	exchangeService services.ExchangeService
+	idempotency     gin.HandlerFunc
 }
 
-func NewExchangeHandler(exchangeService services.ExchangeService) *ExchangeHandler {
-	return &ExchangeHandler{exchangeService: exchangeService}
+func NewExchangeHandler(exchangeService services.ExchangeService, idempotency gin.HandlerFunc) *ExchangeHandler {
+	return &ExchangeHandler{exchangeService: exchangeService, idempotency: idempotency}
 }
 
 func (h *ExchangeHandler) RegisterExchangeRoutes(router *gin.Engine) {
-	router.POST("/exchanges", h.CreateExchange)
-	router.PUT("/exchanges/:id/accept", h.AcceptExchange)
-	router.PUT("/exchanges/:id/complete", h.CompleteExchange)
-	router.PUT("/exchanges/:id/cancel", h.CancelExchange)
+	write := middleware.RequireScope(models.ScopeExchangesWrite)
+
+	exchanges := router.Group("/exchanges", middleware.JWTAuth(models.ScopeExchangesRead))
+	{
+		exchanges.POST("", write, h.idempotency, h.CreateExchange)
+		exchanges.GET("/:id", h.GetByID)
+		exchanges.GET("/:id/events", h.GetEvents)
+		exchanges.POST("/:id/counter", write, h.CounterExchange)
+		exchanges.PUT("/:id/accept", write, h.AcceptExchange)
+		exchanges.PUT("/:id/complete", write, h.CompleteExchange)
+		exchanges.PUT("/:id/cancel", write, h.CancelExchange)
+		exchanges.PUT("/:id/decline", write, h.DeclineExchange)
+	}
+
+	router.GET("/admin/exchanges", middleware.JWTAuth(), middleware.RequireRole(models.RoleAdmin), h.GetAll)
 }
 
 func (h *ExchangeHandler) CancelExchange(c *gin.Context) {
@@ -32,13 +46,37 @@ func (h *ExchangeHandler) CancelExchange(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.exchangeService.CancelExchange(uint(exchangeIDInt)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.exchangeService.CancelExchange(uint(exchangeIDInt), c.GetUint("user_id"), c.GetString("request_id")); err != nil {
+		c.JSON(exchangeErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Exchange cancelled successfully"})
 }
 
+func (h *ExchangeHandler) DeclineExchange(c *gin.Context) {
+	exchangeIDInt, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the body is optional: declining without a reason is allowed
+	var req dto.DeclineExchangeRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.exchangeService.DeclineExchange(uint(exchangeIDInt), c.GetUint("user_id"), req.Reason, c.GetString("request_id")); err != nil {
+		c.JSON(exchangeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange declined successfully"})
+}
+
 func (h *ExchangeHandler) CompleteExchange(c *gin.Context) {
 	exchangeID := c.Param("id")
 	exchangeIDInt, err := strconv.Atoi(exchangeID)
@@ -48,12 +86,18 @@ func (h *ExchangeHandler) CompleteExchange(c *gin.Context) {
 		return
 	}
 
-	if err := h.exchangeService.CompleteExchange(uint(exchangeIDInt)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	exchange, err := h.exchangeService.CompleteExchange(uint(exchangeIDInt), c.GetUint("user_id"), c.GetString("request_id"))
+	if err != nil {
+		c.JSON(exchangeErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Exchange completed successfully"})
+	message := "Handover confirmed, waiting for the other participant"
+	if exchange.Status == "completed" {
+		message = "Exchange completed successfully"
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "exchange": mapExchangeToResponse(*exchange)})
 }
 
 func (h *ExchangeHandler) CreateExchange(c *gin.Context) {
@@ -62,9 +106,9 @@ func (h *ExchangeHandler) CreateExchange(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	exchange, err := h.exchangeService.CreateExchange(&req)
+	exchange, err := h.exchangeService.CreateExchange(c.GetUint("user_id"), &req, c.GetString("request_id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondExchangeError(c, err)
 		return
 	}
 
@@ -79,8 +123,8 @@ func (h *ExchangeHandler) AcceptExchange(c *gin.Context) {
 		return
 	}
 
-	if err := h.exchangeService.AcceptExchange(uint(exchangeIDInt)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.exchangeService.AcceptExchange(uint(exchangeIDInt), c.GetUint("user_id"), c.GetString("request_id")); err != nil {
+		c.JSON(exchangeErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Exchange accepted successfully"})
@@ -93,40 +137,179 @@ func (h *ExchangeHandler) GetByID(c *gin.Context) {
 		return
 	}
 
-	exchange, err := h.exchangeService.GetByID(uint(exchangeID))
+	exchange, err := h.exchangeService.GetByID(uint(exchangeID), actorFromContext(c))
+	if err != nil {
+		c.JSON(exchangeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapExchangeToDetailResponse(*exchange))
+}
+
+func (h *ExchangeHandler) GetEvents(c *gin.Context) {
+	exchangeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный id"})
+		return
+	}
+
+	events, err := h.exchangeService.GetEvents(uint(exchangeID), actorFromContext(c))
+	if err != nil {
+		c.JSON(exchangeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]dto.ExchangeEventResponse, 0, len(events))
+	for _, event := range events {
+		response = append(response, dto.ExchangeEventResponse{
+			ID:         event.ID,
+			FromStatus: event.FromStatus,
+			ToStatus:   event.ToStatus,
+			ActorID:    event.ActorID,
+			Reason:     event.Reason,
+			RequestID:  event.RequestID,
+			CreatedAt:  event.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *ExchangeHandler) CounterExchange(c *gin.Context) {
+	exchangeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req dto.CounterExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exchange, err := h.exchangeService.CounterExchange(uint(exchangeID), c.GetUint("user_id"), &req)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondExchangeError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, exchange)
+	c.JSON(http.StatusCreated, mapExchangeToResponse(*exchange))
 }
 
 func (h *ExchangeHandler) GetAll(c *gin.Context) {
-	exchanges, err := h.exchangeService.GetAll()
+	var query dto.ExchangeListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exchanges, next, err := h.exchangeService.ListExchanges(&query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(exchangeErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	c.JSON(http.StatusOK, mapExchangeListResponse(exchanges, next, query.Limit))
+}
+
+func mapExchangeListResponse(exchanges []models.Exchange, next string, limit int) dto.ExchangeListResponse {
 	response := make([]dto.ExchangeResponse, 0, len(exchanges))
 	for _, exchange := range exchanges {
 		response = append(response, mapExchangeToResponse(exchange))
 	}
 
-	c.JSON(http.StatusOK, response)
+	return dto.ExchangeListResponse{Data: response, Limit: limit, NextCursor: next}
 }
 
 func mapExchangeToResponse(e models.Exchange) dto.ExchangeResponse {
+	initiatorBooks, recipientBooks := e.BookIDs()
+
 	return dto.ExchangeResponse{
-		ID:              e.ID,
-		InitiatorID:     e.InitiatorID,
-		RecipientID:     e.RecipientID,
-		InitiatorBookID: e.InitiatorBookID,
-		RecipientBookID: e.RecipientBookID,
-		Status:          e.Status,
-		CompletedAt:     e.CompletedAt,
-		CreatedAt:       e.CreatedAt,
-		UpdatedAt:       e.UpdatedAt,
+		ID:                   e.ID,
+		InitiatorID:          e.InitiatorID,
+		RecipientID:          e.RecipientID,
+		InitiatorBookID:      e.InitiatorBookID,
+		RecipientBookID:      e.RecipientBookID,
+		InitiatorBooks:       initiatorBooks,
+		RecipientBooks:       recipientBooks,
+		ProposerID:           e.ProposerID,
+		DeliveryMethod:       e.DeliveryMethod,
+		Status:               e.Status,
+		DeclineReason:        e.DeclineReason,
+		CancelReason:         e.CancelReason,
+		FollowUpAt:           e.FollowUpAt,
+		CompletedAt:          e.CompletedAt,
+		InitiatorConfirmedAt: e.InitiatorConfirmedAt,
+		RecipientConfirmedAt: e.RecipientConfirmedAt,
+		CreatedAt:            e.CreatedAt,
+		UpdatedAt:            e.UpdatedAt,
+	}
+}
+
+func mapExchangeToDetailResponse(e models.Exchange) dto.ExchangeDetailResponse {
+	offers := make([]dto.ExchangeOfferResponse, 0, len(e.Offers))
+	for _, o := range e.Offers {
+		offers = append(offers, dto.ExchangeOfferResponse{
+			ID:              o.ID,
+			ProposerID:      o.ProposerID,
+			InitiatorBookID: o.InitiatorBookID,
+			RecipientBookID: o.RecipientBookID,
+			PreviousOfferID: o.PreviousOfferID,
+			Status:          o.Status,
+			CreatedAt:       o.CreatedAt,
+		})
+	}
+
+	return dto.ExchangeDetailResponse{
+		ExchangeResponse: mapExchangeToResponse(e),
+		Offers:           offers,
+	}
+}
+
+// respondExchangeError writes err like exchangeErrorStatus does and lists the
+// contested books when the request lost a reservation race.
+func respondExchangeError(c *gin.Context, err error) {
+	var conflict *dto.BookConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "book_ids": conflict.BookIDs})
+		return
+	}
+
+	c.JSON(exchangeErrorStatus(err), gin.H{"error": err.Error()})
+}
+
+// exchangeErrorStatus maps service errors to HTTP status codes.
+func exchangeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dto.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, dto.ErrForbidden),
+		errors.Is(err, dto.ErrInitiatorNotOwner),
+		errors.Is(err, dto.ErrRecipientNotOwner):
+		return http.StatusForbidden
+	case errors.Is(err, dto.ErrExchangeNotPending),
+		errors.Is(err, dto.ErrExchangeNotAccepted),
+		errors.Is(err, dto.ErrExchangeAlreadyConfirmed),
+		errors.Is(err, dto.ErrExchangePostalComplete),
+		errors.Is(err, dto.ErrBookConflict),
+		errors.Is(err, dto.ErrUnavailable),
+		errors.Is(err, dto.ErrRUnavailable):
+		return http.StatusConflict
+	case errors.Is(err, dto.ErrExchangeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dto.ErrExchangeInvalidID),
+		errors.Is(err, dto.ErrExchangeSameUser),
+		errors.Is(err, dto.ErrDeclineReasonLength),
+		errors.Is(err, dto.ErrExchangeCounterSame),
+		errors.Is(err, dto.ErrExchangeCounterBundle),
+		errors.Is(err, dto.ErrExchangeNoBooks),
+		errors.Is(err, dto.ErrExchangeTooManyItems),
+		errors.Is(err, dto.ErrExchangeDeliveryMethod),
+		errors.Is(err, dto.ErrExchangeInvalidFilter),
+		errors.Is(err, dto.ErrExchangeInvalidCursor):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
 	}
 }
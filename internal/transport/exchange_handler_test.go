@@ -0,0 +1,157 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/authz"
+	"github.com/dasler-fw/bookcrossing/internal/jwtutil"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// openTestDB connects to the database in TEST_DATABASE_DSN and skips the
+// test when it is not set. The database should be a disposable one.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.Book{},
+		&models.Genre{},
+		&models.Exchange{},
+		&models.ExchangeOffer{},
+		&models.ExchangeItem{},
+		&models.ExchangeEvent{},
+	); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return db
+}
+
+func TestCreateExchangeConcurrentReservation(t *testing.T) {
+	db := openTestDB(t)
+	t.Setenv("SUPER_SECRET_KEY", "test-secret")
+	gin.SetMode(gin.TestMode)
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	exchangeService := services.NewExchangeService(
+		repository.NewExchangeRepository(db, log),
+		repository.NewBookRepository(db, log),
+		repository.NewUserRepository(db, log),
+		authz.NewAuthorizer(),
+		log,
+	)
+	router := gin.New()
+	NewExchangeHandler(exchangeService, middleware.Idempotency(nil, 0, log)).RegisterExchangeRoutes(router)
+
+	suffix := time.Now().UnixNano()
+	newUser := func(i int) models.User {
+		user := models.User{Name: fmt.Sprintf("user %d", i), Email: fmt.Sprintf("race-%d-%d@example.com", suffix, i), EmailVerified: true}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		return user
+	}
+	newBook := func(owner models.User) models.Book {
+		book := models.Book{Title: "Race", Author: "Test", Status: "available", UserID: owner.ID}
+		if err := db.Create(&book).Error; err != nil {
+			t.Fatalf("create book: %v", err)
+		}
+		return book
+	}
+
+	recipient := newUser(0)
+	contested := newBook(recipient)
+
+	const workers = 20
+	type attempt struct {
+		token  string
+		bookID uint
+	}
+	attempts := make([]attempt, workers)
+	for i := range attempts {
+		initiator := newUser(i + 1)
+		token, err := jwtutil.GenerateToken(initiator.ID, models.RoleUser)
+		if err != nil {
+			t.Fatalf("token: %v", err)
+		}
+		attempts[i] = attempt{token: token, bookID: newBook(initiator).ID}
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		codes = map[int]int{}
+		start = make(chan struct{})
+	)
+	for _, a := range attempts {
+		wg.Add(1)
+		go func(a attempt) {
+			defer wg.Done()
+
+			body, _ := json.Marshal(map[string]uint{
+				"recipient_id":      recipient.ID,
+				"initiator_book_id": a.bookID,
+				"recipient_book_id": contested.ID,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/exchanges", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+a.token)
+
+			<-start
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			mu.Lock()
+			codes[rec.Code]++
+			mu.Unlock()
+		}(a)
+	}
+	close(start)
+	wg.Wait()
+
+	if codes[http.StatusCreated] != 1 || codes[http.StatusConflict] != workers-1 {
+		t.Fatalf("want 1 created and %d conflicts, got %v", workers-1, codes)
+	}
+
+	var book models.Book
+	if err := db.First(&book, contested.ID).Error; err != nil {
+		t.Fatalf("reload book: %v", err)
+	}
+	if book.Status != "reserved" || book.Version != 1 {
+		t.Fatalf("want reserved book at version 1, got %q at version %d", book.Status, book.Version)
+	}
+
+	var exchanges int64
+	db.Model(&models.Exchange{}).Where("recipient_book_id = ?", contested.ID).Count(&exchanges)
+	if exchanges != 1 {
+		t.Fatalf("want 1 exchange for the contested book, got %d", exchanges)
+	}
+}
@@ -0,0 +1,107 @@
+package transport
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ExchangeMessageHandler struct {
+	service services.ExchangeMessageService
+}
+
+func NewExchangeMessageHandler(service services.ExchangeMessageService) *ExchangeMessageHandler {
+	return &ExchangeMessageHandler{service: service}
+}
+
+func (h *ExchangeMessageHandler) RegisterExchangeMessageRoutes(router *gin.Engine) {
+	messages := router.Group("/exchanges/:id/messages", middleware.JWTAuth())
+	{
+		messages.GET("", h.List)
+		messages.POST("", h.Send)
+	}
+}
+
+func (h *ExchangeMessageHandler) Send(c *gin.Context) {
+	exchangeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req dto.CreateExchangeMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	message, err := h.service.Send(uint(exchangeID), c.GetUint("user_id"), req)
+	if err != nil {
+		c.JSON(exchangeMessageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, mapExchangeMessageToResponse(*message))
+}
+
+func (h *ExchangeMessageHandler) List(c *gin.Context) {
+	exchangeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var query dto.ExchangeMessageListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	messages, total, err := h.service.List(uint(exchangeID), c.GetUint("user_id"), &query)
+	if err != nil {
+		c.JSON(exchangeMessageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	data := make([]dto.ExchangeMessageResponse, 0, len(messages))
+	for _, m := range messages {
+		data = append(data, mapExchangeMessageToResponse(m))
+	}
+
+	c.JSON(http.StatusOK, dto.ExchangeMessageListResponse{
+		Data:       data,
+		Page:       query.Page,
+		Limit:      query.Limit,
+		Total:      int(total),
+		TotalPages: int(math.Ceil(float64(total) / float64(query.Limit))),
+	})
+}
+
+func mapExchangeMessageToResponse(m models.ExchangeMessage) dto.ExchangeMessageResponse {
+	return dto.ExchangeMessageResponse{
+		ID:        m.ID,
+		SenderID:  m.SenderID,
+		Text:      m.Text,
+		ReadAt:    m.ReadAt,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+func exchangeMessageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dto.ErrMessageTextRequired),
+		errors.Is(err, dto.ErrMessageTextLength):
+		return http.StatusBadRequest
+	case errors.Is(err, dto.ErrMessageThreadFrozen):
+		return http.StatusConflict
+	default:
+		return exchangeErrorStatus(err)
+	}
+}
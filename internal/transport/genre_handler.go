@@ -6,6 +6,8 @@ import (
 	"strconv"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/dasler-fw/bookcrossing/internal/services"
 	"github.com/gin-gonic/gin"
 )
@@ -19,10 +21,10 @@ func NewGenreHandler(service services.GenreService) *GenreHandler {
 }
 
 func (h *GenreHandler) RegisterGenreRoutes(r *gin.Engine) {
-	r.POST("/genres", h.Create)
+	r.POST("/genres", middleware.JWTAuth(), middleware.RequireRole(models.RoleAdmin), h.Create)
 	r.GET("/genres", h.List)
 	r.GET("/genres/:id", h.GetByID)
-	r.DELETE("/genres/:id", h.Delete)
+	r.DELETE("/genres/:id", middleware.JWTAuth(), middleware.RequireRole(models.RoleAdmin), h.Delete)
 }
 
 func (h *GenreHandler) Create(c *gin.Context) {
@@ -0,0 +1,31 @@
+package transport
+
+import (
+	"net/http"
+
+	"github.com/dasler-fw/bookcrossing/internal/jwtutil"
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct{}
+
+func NewJWKSHandler() *JWKSHandler {
+	return &JWKSHandler{}
+}
+
+func (h *JWKSHandler) RegisterJWKSRoutes(router *gin.Engine) {
+	router.GET("/.well-known/jwks.json", h.Get)
+}
+
+// Get publishes the public keys other services verify our access tokens
+// with. Retired keys stay listed until they are removed from JWT_VERIFY_KEYS.
+func (h *JWKSHandler) Get(c *gin.Context) {
+	set, err := jwtutil.PublicJWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "keys are not available"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
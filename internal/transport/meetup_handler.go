@@ -0,0 +1,162 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type MeetupHandler struct {
+	service services.MeetupService
+}
+
+func NewMeetupHandler(service services.MeetupService) *MeetupHandler {
+	return &MeetupHandler{service: service}
+}
+
+func (h *MeetupHandler) RegisterMeetupRoutes(router *gin.Engine) {
+	exchanges := router.Group("/exchanges/:id", middleware.JWTAuth())
+	{
+		exchanges.GET("/meetups", h.List)
+		exchanges.POST("/meetups", h.Propose)
+		exchanges.PUT("/meetups/:meetup_id/confirm", h.Confirm)
+		exchanges.GET("/meetup.ics", h.ExportICS)
+	}
+}
+
+func (h *MeetupHandler) Propose(c *gin.Context) {
+	exchangeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req dto.CreateMeetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	meetup, err := h.service.Propose(uint(exchangeID), c.GetUint("user_id"), req)
+	if err != nil {
+		c.JSON(meetupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, mapMeetupToResponse(*meetup))
+}
+
+func (h *MeetupHandler) List(c *gin.Context) {
+	exchangeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	meetups, err := h.service.List(uint(exchangeID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(meetupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]dto.MeetupResponse, 0, len(meetups))
+	for _, m := range meetups {
+		response = append(response, mapMeetupToResponse(m))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *MeetupHandler) Confirm(c *gin.Context) {
+	exchangeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	meetupID, err := strconv.Atoi(c.Param("meetup_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req dto.ConfirmMeetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	meetup, err := h.service.Confirm(uint(exchangeID), uint(meetupID), c.GetUint("user_id"), req)
+	if err != nil {
+		c.JSON(meetupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapMeetupToResponse(*meetup))
+}
+
+func (h *MeetupHandler) ExportICS(c *gin.Context) {
+	exchangeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ics, err := h.service.ExportICS(uint(exchangeID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(meetupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="exchange-%d-meetup.ics"`, exchangeID))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", ics)
+}
+
+func mapMeetupToResponse(m models.Meetup) dto.MeetupResponse {
+	slots := make([]dto.MeetupSlotResponse, 0, len(m.Slots))
+	for _, slot := range m.Slots {
+		slots = append(slots, dto.MeetupSlotResponse{
+			ID:       slot.ID,
+			StartsAt: slot.StartsAt,
+			EndsAt:   slot.EndsAt,
+		})
+	}
+
+	return dto.MeetupResponse{
+		ID:              m.ID,
+		ExchangeID:      m.ExchangeID,
+		ProposerID:      m.ProposerID,
+		Place:           m.Place,
+		Status:          m.Status,
+		ConfirmedSlotID: m.ConfirmedSlotID,
+		Slots:           slots,
+		CreatedAt:       m.CreatedAt,
+	}
+}
+
+func meetupErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dto.ErrMeetupPlaceRequired),
+		errors.Is(err, dto.ErrMeetupPlaceLength),
+		errors.Is(err, dto.ErrMeetupSlotsCount),
+		errors.Is(err, dto.ErrMeetupSlotInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, dto.ErrMeetupSelfConfirm):
+		return http.StatusForbidden
+	case errors.Is(err, dto.ErrMeetupNotFound),
+		errors.Is(err, dto.ErrMeetupSlotNotFound),
+		errors.Is(err, dto.ErrMeetupNotConfirmed):
+		return http.StatusNotFound
+	case errors.Is(err, dto.ErrMeetupNotProposed):
+		return http.StatusConflict
+	default:
+		return exchangeErrorStatus(err)
+	}
+}
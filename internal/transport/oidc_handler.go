@@ -0,0 +1,78 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCHandler struct {
+	service services.OIDCService
+}
+
+func NewOIDCHandler(service services.OIDCService) *OIDCHandler {
+	return &OIDCHandler{service: service}
+}
+
+func (h *OIDCHandler) RegisterOIDCRoutes(router *gin.Engine) {
+	oidc := router.Group("/auth/oidc")
+	{
+		oidc.GET("/providers", h.Providers)
+		oidc.GET("/:provider/login", h.Login)
+		oidc.GET("/:provider/callback", h.Callback)
+	}
+}
+
+func (h *OIDCHandler) Providers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.service.Providers()})
+}
+
+// Login redirects the browser to the provider.
+func (h *OIDCHandler) Login(c *gin.Context) {
+	url, err := h.service.AuthURL(c.Param("provider"))
+	if err != nil {
+		c.JSON(oidcErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, url)
+}
+
+// Callback is the redirect URL registered at the provider. It answers like
+// a password login: tokens, or a challenge for two-factor accounts.
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": providerErr, "error_description": c.Query("error_description")})
+		return
+	}
+
+	resp, err := h.service.Callback(c.Param("provider"), c.Query("state"), c.Query("code"))
+	if err != nil {
+		c.JSON(oidcErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+func oidcErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dto.ErrOIDCProviderUnknown):
+		return http.StatusNotFound
+	case errors.Is(err, dto.ErrOIDCStateInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, dto.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, dto.ErrOIDCAccountUnverified):
+		return http.StatusConflict
+	case errors.Is(err, dto.ErrOIDCLoginFailed):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
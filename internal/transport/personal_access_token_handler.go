@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PersonalAccessTokenHandler struct {
+	service services.PersonalAccessTokenService
+}
+
+func NewPersonalAccessTokenHandler(service services.PersonalAccessTokenService) *PersonalAccessTokenHandler {
+	return &PersonalAccessTokenHandler{service: service}
+}
+
+// RegisterPersonalAccessTokenRoutes only accepts JWTs, a personal access
+// token cannot create or revoke tokens.
+func (h *PersonalAccessTokenHandler) RegisterPersonalAccessTokenRoutes(router *gin.Engine) {
+	tokens := router.Group("/users/me/tokens", middleware.JWTAuth())
+	{
+		tokens.GET("", h.List)
+		tokens.POST("", h.Create)
+		tokens.DELETE("/:id", h.Revoke)
+	}
+}
+
+func (h *PersonalAccessTokenHandler) List(c *gin.Context) {
+	tokens, err := h.service.List(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(personalAccessTokenErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Create returns the token once; only its hash is kept.
+func (h *PersonalAccessTokenHandler) Create(c *gin.Context) {
+	var req dto.PersonalAccessTokenCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	token, err := h.service.Create(c.GetUint("user_id"), req)
+	if err != nil {
+		c.JSON(personalAccessTokenErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, token)
+}
+
+func (h *PersonalAccessTokenHandler) Revoke(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid token id"})
+		return
+	}
+
+	if err := h.service.Revoke(c.GetUint("user_id"), uint(id)); err != nil {
+		c.JSON(personalAccessTokenErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func personalAccessTokenErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dto.ErrPersonalAccessTokenNameRequired),
+		errors.Is(err, dto.ErrPersonalAccessTokenInvalidScope),
+		errors.Is(err, dto.ErrPersonalAccessTokenInvalidExpiry):
+		return http.StatusBadRequest
+	case errors.Is(err, dto.ErrPersonalAccessTokenLimit):
+		return http.StatusConflict
+	case errors.Is(err, dto.ErrPersonalAccessTokenNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -1,27 +1,30 @@
 package transport
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/dasler-fw/bookcrossing/internal/services"
 	"github.com/gin-gonic/gin"
 	
 )
 
 type ReviewHandler struct {
-	service services.ReviewService
+	service     services.ReviewService
+	idempotency gin.HandlerFunc
 }
 
-func NewReviewHandler(service services.ReviewService) *ReviewHandler {
-	return &ReviewHandler{service: service}
+func NewReviewHandler(service services.ReviewService, idempotency gin.HandlerFunc) *ReviewHandler {
+	return &ReviewHandler{service: service, idempotency: idempotency}
 }
 
 func (h *ReviewHandler) RegisterReviewRoutes(r *gin.Engine) {
-	r.POST("/review",middleware.JWTAuth(), h.Create)
-	r.DELETE("/review/:id",middleware.JWTAuth(), h.Delete)
+	r.POST("/review",middleware.JWTAuth(models.ScopeReviewsWrite), h.idempotency, h.Create)
+	r.DELETE("/review/:id",middleware.JWTAuth(models.ScopeReviewsWrite), h.Delete)
 	r.GET("/users/:id/review", h.GetByUser)
 	r.GET("/book/:id/review", h.GetByBook)
 }
@@ -45,7 +48,11 @@ func (h *ReviewHandler) Create(c *gin.Context) {
 	}
 
 	if err := h.service.Create(authorID.(uint), req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		status := http.StatusBadRequest
+		if errors.Is(err, dto.ErrForbidden) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -111,16 +118,15 @@ func (h *ReviewHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	authorID, ok := c.Get("user_id")
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "unauthorized",
-		})
-		return
-	}
-
-	if err := h.service.Delete(uint(reviewID), authorID.(uint)); err != nil {
-		c.JSON(http.StatusForbidden, gin.H{
+	if err := h.service.Delete(uint(reviewID), actorFromContext(c)); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, dto.ErrForbidden):
+			status = http.StatusForbidden
+		case errors.Is(err, dto.ErrReviewNotFound):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -12,19 +12,58 @@ func RegisterRoutes(
 	log *slog.Logger,
 	bookService services.BookService,
 	exchangeService services.ExchangeService,
+	exchangeCycleService services.ExchangeCycleService,
+	disputeService services.DisputeService,
+	exchangeMessageService services.ExchangeMessageService,
+	meetupService services.MeetupService,
+	shipmentService services.ShipmentService,
 	genreService services.GenreService,
 	reviewService services.ReviewService,
 	userService services.UserService,
+	emailVerificationService services.EmailVerificationService,
+	loginGuard services.LoginGuard,
+	authService services.AuthService,
+	passwordResetService services.PasswordResetService,
+	oidcService services.OIDCService,
+	twoFactorService services.TwoFactorService,
+	personalAccessTokenService services.PersonalAccessTokenService,
+	adminService services.AdminService,
+	wishlistService services.WishlistService,
+	idempotency gin.HandlerFunc,
 ) {
-	bookHandler := NewBookHandler(bookService)
-	exchangeHandler := NewExchangeHandler(exchangeService)
+	bookHandler := NewBookHandler(bookService, idempotency)
+	exchangeHandler := NewExchangeHandler(exchangeService, idempotency)
+	exchangeCycleHandler := NewExchangeCycleHandler(exchangeCycleService)
+	disputeHandler := NewDisputeHandler(disputeService)
+	exchangeMessageHandler := NewExchangeMessageHandler(exchangeMessageService)
+	meetupHandler := NewMeetupHandler(meetupService)
+	shipmentHandler := NewShipmentHandler(shipmentService)
 	genreHandler := NewGenreHandler(genreService)
-	reviewHandler := NewReviewHandler(reviewService)
-	userHandler := NewUserHandler(userService)
+	reviewHandler := NewReviewHandler(reviewService, idempotency)
+	userHandler := NewUserHandler(userService, emailVerificationService, loginGuard, idempotency)
+	authHandler := NewAuthHandler(authService, passwordResetService)
+	oidcHandler := NewOIDCHandler(oidcService)
+	twoFactorHandler := NewTwoFactorHandler(twoFactorService)
+	personalAccessTokenHandler := NewPersonalAccessTokenHandler(personalAccessTokenService)
+	adminHandler := NewAdminHandler(adminService, userService)
+	wishlistHandler := NewWishlistHandler(wishlistService)
+	jwksHandler := NewJWKSHandler()
 
 	bookHandler.RegisterRoutes(router)
 	exchangeHandler.RegisterExchangeRoutes(router)
+	exchangeCycleHandler.RegisterExchangeCycleRoutes(router)
+	disputeHandler.RegisterDisputeRoutes(router)
+	exchangeMessageHandler.RegisterExchangeMessageRoutes(router)
+	meetupHandler.RegisterMeetupRoutes(router)
+	shipmentHandler.RegisterShipmentRoutes(router)
 	genreHandler.RegisterGenreRoutes(router)
 	reviewHandler.RegisterReviewRoutes(router)
 	userHandler.RegisterRoutes(router)
+	authHandler.RegisterAuthRoutes(router)
+	oidcHandler.RegisterOIDCRoutes(router)
+	twoFactorHandler.RegisterTwoFactorRoutes(router)
+	personalAccessTokenHandler.RegisterPersonalAccessTokenRoutes(router)
+	adminHandler.RegisterAdminRoutes(router)
+	wishlistHandler.RegisterWishlistRoutes(router)
+	jwksHandler.RegisterJWKSRoutes(router)
 }
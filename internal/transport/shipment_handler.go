@@ -0,0 +1,135 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ShipmentHandler struct {
+	service services.ShipmentService
+}
+
+func NewShipmentHandler(service services.ShipmentService) *ShipmentHandler {
+	return &ShipmentHandler{service: service}
+}
+
+func (h *ShipmentHandler) RegisterShipmentRoutes(router *gin.Engine) {
+	shipping := router.Group("/exchanges/:id/shipping", middleware.JWTAuth())
+	{
+		shipping.GET("", h.Get)
+		shipping.PUT("/ship", h.Ship)
+		shipping.PUT("/deliver", h.Deliver)
+	}
+}
+
+func (h *ShipmentHandler) Get(c *gin.Context) {
+	exchangeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exchange, counterparty, shipments, err := h.service.GetShipping(uint(exchangeID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := dto.ShippingResponse{
+		ExchangeID: exchange.ID,
+		Address: dto.ShippingAddressResponse{
+			UserID:  counterparty.ID,
+			Name:    counterparty.Name,
+			City:    counterparty.City,
+			Address: counterparty.Address,
+		},
+		Shipments: make([]dto.ShipmentResponse, 0, len(shipments)),
+	}
+	for _, s := range shipments {
+		response.Shipments = append(response.Shipments, mapShipmentToResponse(s))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *ShipmentHandler) Ship(c *gin.Context) {
+	exchangeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req dto.ShipExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	shipment, err := h.service.Ship(uint(exchangeID), c.GetUint("user_id"), req)
+	if err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapShipmentToResponse(*shipment))
+}
+
+func (h *ShipmentHandler) Deliver(c *gin.Context) {
+	exchangeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exchange, err := h.service.Deliver(uint(exchangeID), c.GetUint("user_id"), c.GetString("request_id"))
+	if err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "Delivery confirmed, waiting for the other shipment"
+	if exchange.Status == "completed" {
+		message = "Exchange completed successfully"
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "exchange": mapExchangeToResponse(*exchange)})
+}
+
+func mapShipmentToResponse(s models.ExchangeShipment) dto.ShipmentResponse {
+	return dto.ShipmentResponse{
+		ID:             s.ID,
+		Side:           s.Side,
+		SenderID:       s.SenderID,
+		ReceiverID:     s.ReceiverID,
+		Status:         s.Status,
+		Carrier:        s.Carrier,
+		TrackingNumber: s.TrackingNumber,
+		ShippedAt:      s.ShippedAt,
+		DeliveredAt:    s.DeliveredAt,
+	}
+}
+
+func shipmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dto.ErrShipmentCarrier),
+		errors.Is(err, dto.ErrShipmentTracking):
+		return http.StatusBadRequest
+	case errors.Is(err, dto.ErrShipmentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dto.ErrExchangeNotPostal),
+		errors.Is(err, dto.ErrShipmentAlreadyShipped),
+		errors.Is(err, dto.ErrShipmentNotShipped),
+		errors.Is(err, dto.ErrShipmentDelivered),
+		errors.Is(err, dto.ErrShippingAddressMissing):
+		return http.StatusConflict
+	default:
+		return exchangeErrorStatus(err)
+	}
+}
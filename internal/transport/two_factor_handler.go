@@ -0,0 +1,152 @@
+package transport
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorHandler struct {
+	service services.TwoFactorService
+}
+
+func NewTwoFactorHandler(service services.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{service: service}
+}
+
+func (h *TwoFactorHandler) RegisterTwoFactorRoutes(router *gin.Engine) {
+	users := router.Group("/users")
+	{
+		users.POST("/login/2fa", h.Login)
+	}
+
+	twoFactor := router.Group("/users/me/2fa", middleware.JWTAuth())
+	{
+		twoFactor.GET("", h.Status)
+		twoFactor.POST("/setup", h.Setup)
+		twoFactor.POST("/enable", h.Enable)
+		twoFactor.POST("/disable", h.Disable)
+		twoFactor.POST("/recovery-codes", h.RegenerateRecoveryCodes)
+	}
+}
+
+// Login is the second step of a login for accounts with 2FA.
+func (h *TwoFactorHandler) Login(c *gin.Context) {
+	var req dto.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	req.ClientIP = c.ClientIP()
+
+	tokens, err := h.service.CompleteLogin(req)
+	if err != nil {
+		var throttled *dto.LoginThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *TwoFactorHandler) Status(c *gin.Context) {
+	status, err := h.service.Status(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Setup returns the secret and the otpauth:// URI to render as a QR code.
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	setup, err := h.service.Setup(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, setup)
+}
+
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	var req dto.TwoFactorEnableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	codes, err := h.service.Enable(c.GetUint("user_id"), req.Code)
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, codes)
+}
+
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var req dto.TwoFactorConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if err := h.service.Disable(c.GetUint("user_id"), req); err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req dto.TwoFactorConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(c.GetUint("user_id"), req)
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, codes)
+}
+
+func twoFactorErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dto.ErrTwoFactorCodeInvalid),
+		errors.Is(err, dto.ErrTwoFactorChallengeInvalid),
+		errors.Is(err, dto.ErrInvalidCredentials):
+		return http.StatusUnauthorized
+	case errors.Is(err, dto.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, dto.ErrTwoFactorNotEnabled),
+		errors.Is(err, dto.ErrTwoFactorNotSetUp):
+		return http.StatusConflict
+	case errors.Is(err, repository.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dto.ErrTwoFactorUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
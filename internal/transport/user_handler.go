@@ -1,32 +1,43 @@
 package transport
 
 import (
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
 	"github.com/dasler-fw/bookcrossing/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
 type UserHandler struct {
-	userServ services.UserService
+	userServ     services.UserService
+	verification services.EmailVerificationService
+	loginGuard   services.LoginGuard
+	idempotency  gin.HandlerFunc
 }
 
-func NewUserHandler(userServ services.UserService) *UserHandler {
-	return &UserHandler{userServ: userServ}
+func NewUserHandler(userServ services.UserService, verification services.EmailVerificationService, loginGuard services.LoginGuard, idempotency gin.HandlerFunc) *UserHandler {
+	return &UserHandler{userServ: userServ, verification: verification, loginGuard: loginGuard, idempotency: idempotency}
 }
 
 func (h *UserHandler) RegisterRoutes(r *gin.Engine) {
 	users := r.Group("/users")
 	{
-		users.POST("/register", h.Register)
+		users.POST("/register", h.idempotency, h.Register)
 		users.GET("", h.GetList)
 		users.POST("/login", h.Login)
+		users.GET("/login/unlock", h.UnlockLogin)
+		users.GET("/verify", h.VerifyEmail)
+		users.POST("/verify/resend", middleware.JWTAuth(), h.ResendVerification)
 		users.GET("/:id", middleware.JWTAuth(), h.GetProfile)
 		users.PATCH("/:id", middleware.JWTAuth(), h.UpdateProfile)
-		users.GET("/:id/exchanges", middleware.JWTAuth(), h.GetUserExchanges)
+		users.GET("/:id/exchanges", middleware.JWTAuth(models.ScopeExchangesRead), h.GetUserExchanges)
+		users.DELETE("/:id", middleware.JWTAuth(), middleware.RequireRole(models.RoleAdmin), h.Delete)
 
 	}
 
@@ -39,14 +50,18 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
-	token, err := h.userServ.Register(req)
+	tokens, err := h.userServ.Register(req)
 	if err != nil {
-		if err.Error() == "email уже используется" {
+		if errors.Is(err, dto.ErrEmailAlreadyUsed) {
 			c.JSON(http.StatusConflict, gin.H{
 				"error": "email уже используется",
 			})
 			return
 		}
+		if errors.Is(err, dto.ErrInvalidEmail) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "не удалось зарегистрировать пользователя",
@@ -54,10 +69,39 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"token": token,
-	})
+	c.JSON(http.StatusCreated, tokens)
+
+}
+
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	if err := h.verification.Verify(c.Query("token")); err != nil {
+		if errors.Is(err, dto.ErrVerificationTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось подтвердить email"})
+		return
+	}
 
+	c.JSON(http.StatusOK, gin.H{"message": "email подтверждён"})
+}
+
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	if err := h.verification.Resend(c.GetUint("user_id")); err != nil {
+		switch {
+		case errors.Is(err, dto.ErrVerificationRateLimited):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case errors.Is(err, dto.ErrEmailAlreadyVerified):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "письмо отправлено"})
 }
 
 func (h *UserHandler) Login(c *gin.Context) {
@@ -66,14 +110,35 @@ func (h *UserHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 		return
 	}
+	req.ClientIP = c.ClientIP()
 
-	token, err := h.userServ.Login(req)
+	tokens, err := h.userServ.Login(req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		var throttled *dto.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case errors.Is(err, dto.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		case errors.Is(err, dto.ErrTwoFactorUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *UserHandler) UnlockLogin(c *gin.Context) {
+	if err := h.loginGuard.Unlock(c.Query("token")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "аккаунт разблокирован"})
 }
 
 func (h *UserHandler) GetProfile(c *gin.Context) {
@@ -84,8 +149,12 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	profile, err := h.userServ.GetProfile(uint(id))
+	profile, err := h.userServ.GetProfile(actorFromContext(c), uint(id))
 	if err != nil {
+		if errors.Is(err, dto.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден"})
 		return
 	}
@@ -94,19 +163,12 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 }
 
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
-	authUserID := c.GetUint("user_id")
-
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный идентификатор пользователя"})
 		return
 	}
 
-	if authUserID != uint(id) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "доступ запрещён: нельзя редактировать чужой профиль"})
-		return
-	}
-
 	var req dto.UserUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректное тело запроса"})
@@ -119,7 +181,11 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	if err := h.userServ.UpdateProfile(uint(id), req); err != nil {
+	if err := h.userServ.UpdateProfile(actorFromContext(c), uint(id), req); err != nil {
+		if errors.Is(err, dto.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "не удалось обновить профиль пользователя",
 		})
@@ -132,6 +198,30 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 
 }
 
+func (h *UserHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный идентификатор пользователя"})
+		return
+	}
+
+	if _, err := h.userServ.GetUserByID(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден"})
+		return
+	}
+
+	if err := h.userServ.DeleteUser(actorFromContext(c), uint(id)); err != nil {
+		if errors.Is(err, dto.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось удалить пользователя"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "пользователь удалён"})
+}
+
 func (h *UserHandler) GetUserExchanges(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
@@ -139,15 +229,27 @@ func (h *UserHandler) GetUserExchanges(c *gin.Context) {
 		return
 	}
 
-	status := c.Query("status")
+	var query dto.ExchangeListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректные параметры"})
+		return
+	}
 
-	exchanges, err := h.userServ.GetUserExchanges(uint(id), status)
+	exchanges, next, err := h.userServ.GetUserExchanges(actorFromContext(c), uint(id), &query)
 	if err != nil {
+		if errors.Is(err, dto.ErrExchangeInvalidFilter) || errors.Is(err, dto.ErrExchangeInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, dto.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось получить историю обменов"})
 		return
 	}
 
-	c.JSON(http.StatusOK, exchanges)
+	c.JSON(http.StatusOK, mapExchangeListResponse(exchanges, next, query.Limit))
 }
 
 func (h *UserHandler) GetList(c *gin.Context) {
@@ -0,0 +1,104 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type WishlistHandler struct {
+	service services.WishlistService
+}
+
+func NewWishlistHandler(service services.WishlistService) *WishlistHandler {
+	return &WishlistHandler{service: service}
+}
+
+func (h *WishlistHandler) RegisterWishlistRoutes(r *gin.Engine) {
+	write := middleware.RequireScope(models.ScopeWishlistWrite)
+
+	wishlist := r.Group("/wishlist", middleware.JWTAuth(models.ScopeWishlistRead))
+	{
+		wishlist.GET("", h.List)
+		wishlist.POST("", write, h.Add)
+		wishlist.DELETE("/:id", write, h.Delete)
+	}
+}
+
+func (h *WishlistHandler) Add(c *gin.Context) {
+	var req dto.AddWishlistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	item, err := h.service.Add(c.GetUint("user_id"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, dto.ErrUnauthorized):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, dto.ErrBookGetFailed):
+			c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		case errors.Is(err, dto.ErrWishlistOwnBook):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, dto.ErrWishlistDuplicate):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add book to wishlist"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, mapWishlistItemToResponse(*item))
+}
+
+func (h *WishlistHandler) List(c *gin.Context) {
+	items, err := h.service.List(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get wishlist"})
+		return
+	}
+
+	response := make([]dto.WishlistItemResponse, 0, len(items))
+	for _, item := range items {
+		response = append(response, mapWishlistItemToResponse(item))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *WishlistHandler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid wishlist item id"})
+		return
+	}
+
+	if err := h.service.Delete(uint(id), c.GetUint("user_id")); err != nil {
+		if errors.Is(err, dto.ErrWishlistItemNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete wishlist item"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "wishlist item deleted"})
+}
+
+func mapWishlistItemToResponse(item models.WishlistItem) dto.WishlistItemResponse {
+	response := dto.WishlistItemResponse{
+		ID:        item.ID,
+		CreatedAt: item.CreatedAt,
+	}
+	if item.Book != nil {
+		response.Book = mapBookToResponse(*item.Book)
+	}
+	return response
+}